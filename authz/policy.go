@@ -0,0 +1,124 @@
+package authz
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Role is a caller's role, taken from the roles claim of their token
+type Role string
+
+const (
+	RoleCustomer Role = "customer" // books services and manages their own bookings
+	RoleSupport  Role = "support"  // helps customers with any booking
+	RoleAdmin    Role = "admin"    // changes the configuration and runs the service
+)
+
+// Permission names an action a role may perform
+type Permission string
+
+const (
+	PermissionCreateBookings         Permission = "bookings:create"
+	PermissionReadOwnBookings        Permission = "bookings:read:own"
+	PermissionReadAnyBooking         Permission = "bookings:read:any"
+	PermissionCancelOwnBookings      Permission = "bookings:cancel:own"
+	PermissionCancelAnyBooking       Permission = "bookings:cancel:any"
+	PermissionCancelConfirmedBooking Permission = "bookings:cancel:confirmed"
+	PermissionManageServices         Permission = "services:manage"
+	PermissionManageWebhooks         Permission = "webhooks:manage"
+	PermissionManageJobs             Permission = "jobs:manage"
+	PermissionManageConfig           Permission = "config:manage"
+)
+
+// ErrForbidden matches every *ForbiddenError with errors.Is
+var ErrForbidden = errors.New("forbidden")
+
+// ForbiddenError is returned when none of the caller's roles grants Permission
+type ForbiddenError struct {
+	Permission Permission
+}
+
+func (e *ForbiddenError) Error() string {
+	return fmt.Sprintf("missing permission %s", e.Permission)
+}
+
+// Is makes errors.Is(err, ErrForbidden) hold for every *ForbiddenError
+func (e *ForbiddenError) Is(target error) bool {
+	return target == ErrForbidden
+}
+
+// Principal is an authenticated caller
+type Principal struct {
+	UserID string // the token's sub claim
+	Roles  []Role
+}
+
+// Policy declares which permissions each role grants
+type Policy struct {
+	grants map[Role]map[Permission]bool
+}
+
+// NewPolicy builds a policy from a role -> permissions table. Roles missing
+// from the table grant nothing.
+func NewPolicy(roles map[Role][]Permission) *Policy {
+	policy := &Policy{grants: make(map[Role]map[Permission]bool)}
+	for role, permissions := range roles {
+		granted := make(map[Permission]bool, len(permissions))
+		for _, permission := range permissions {
+			granted[permission] = true
+		}
+		policy.grants[role] = granted
+	}
+	return policy
+}
+
+// DefaultPolicy is the role mapping the service runs with:
+//
+//	customer: create bookings, read and cancel their own pending bookings
+//	support:  read any booking and cancel any booking, confirmed ones included
+//	admin:    everything, including the runtime configuration, the catalog,
+//	          every webhook subscription and the job queue
+var DefaultPolicy = NewPolicy(map[Role][]Permission{
+	RoleCustomer: {
+		PermissionCreateBookings,
+		PermissionReadOwnBookings,
+		PermissionCancelOwnBookings,
+	},
+	RoleSupport: {
+		PermissionReadOwnBookings,
+		PermissionReadAnyBooking,
+		PermissionCancelOwnBookings,
+		PermissionCancelAnyBooking,
+		PermissionCancelConfirmedBooking,
+	},
+	RoleAdmin: {
+		PermissionCreateBookings,
+		PermissionReadOwnBookings,
+		PermissionReadAnyBooking,
+		PermissionCancelOwnBookings,
+		PermissionCancelAnyBooking,
+		PermissionCancelConfirmedBooking,
+		PermissionManageServices,
+		PermissionManageWebhooks,
+		PermissionManageJobs,
+		PermissionManageConfig,
+	},
+})
+
+// Allows reports whether any of the principal's roles grants permission
+func (p *Policy) Allows(principal Principal, permission Permission) bool {
+	for _, role := range principal.Roles {
+		if p.grants[role][permission] {
+			return true
+		}
+	}
+	return false
+}
+
+// Require returns a *ForbiddenError naming permission unless the principal holds it
+func (p *Policy) Require(principal Principal, permission Permission) error {
+	if !p.Allows(principal, permission) {
+		return &ForbiddenError{Permission: permission}
+	}
+	return nil
+}
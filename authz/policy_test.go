@@ -0,0 +1,53 @@
+package authz
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultPolicy(t *testing.T) {
+	customer := Principal{UserID: "user1", Roles: []Role{RoleCustomer}}
+	support := Principal{UserID: "agent1", Roles: []Role{RoleSupport}}
+	admin := Principal{UserID: "root", Roles: []Role{RoleAdmin}}
+
+	tests := []struct {
+		principal  Principal
+		permission Permission
+		allowed    bool
+	}{
+		{customer, PermissionCreateBookings, true},
+		{customer, PermissionReadOwnBookings, true},
+		{customer, PermissionReadAnyBooking, false},
+		{customer, PermissionCancelConfirmedBooking, false},
+		{support, PermissionReadAnyBooking, true},
+		{support, PermissionCancelConfirmedBooking, true},
+		{support, PermissionManageServices, false},
+		{admin, PermissionManageServices, true},
+		{admin, PermissionManageWebhooks, true},
+		{admin, PermissionManageJobs, true},
+		{admin, PermissionManageConfig, true},
+		{support, PermissionManageConfig, false},
+		{Principal{UserID: "user1"}, PermissionReadOwnBookings, false},
+		{Principal{UserID: "user1", Roles: []Role{"auditor"}}, PermissionReadOwnBookings, false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.allowed, DefaultPolicy.Allows(tt.principal, tt.permission), "%v %s", tt.principal.Roles, tt.permission)
+	}
+}
+
+func TestRequireNamesTheMissingPermission(t *testing.T) {
+	policy := NewPolicy(map[Role][]Permission{RoleSupport: {PermissionReadAnyBooking}})
+	principal := Principal{UserID: "agent1", Roles: []Role{RoleCustomer, RoleSupport}}
+
+	assert.NoError(t, policy.Require(principal, PermissionReadAnyBooking))
+
+	err := policy.Require(principal, PermissionManageJobs)
+	assert.ErrorIs(t, err, ErrForbidden)
+	var forbidden *ForbiddenError
+	if assert.True(t, errors.As(err, &forbidden)) {
+		assert.Equal(t, PermissionManageJobs, forbidden.Permission)
+	}
+	assert.EqualError(t, err, "missing permission jobs:manage")
+}
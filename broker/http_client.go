@@ -0,0 +1,60 @@
+package broker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// HeaderMessageKey carries the message key on HTTP requests
+const HeaderMessageKey = "Message-Key"
+
+// DefaultHTTPTimeout bounds a single send
+const DefaultHTTPTimeout = 5 * time.Second
+
+// Ensure HTTPClient satisfies Client
+var _ Client = (*HTTPClient)(nil)
+
+// HTTPClient sends each message as POST {baseURL}/topics/{topic} with the body
+// as is, the key in the Message-Key header and the message headers as HTTP headers
+type HTTPClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+func NewHTTPClient(baseURL string, httpClient *http.Client) *HTTPClient {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: DefaultHTTPTimeout}
+	}
+	return &HTTPClient{baseURL: strings.TrimRight(baseURL, "/"), httpClient: httpClient}
+}
+
+func (c *HTTPClient) Send(ctx context.Context, message Message) error {
+	endpoint := c.baseURL + "/topics/" + url.PathEscape(message.Topic)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(message.Body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderMessageKey, message.Key)
+	for name, value := range message.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("sending to topic %s: broker responded with status %d", message.Topic, resp.StatusCode)
+	}
+	return nil
+}
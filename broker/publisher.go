@@ -0,0 +1,62 @@
+// Package broker publishes booking domain events to a message broker. The
+// Publisher adapts any broker Client; HTTPClient talks to brokers through an
+// HTTP ingestion endpoint such as a REST proxy.
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/touchsung/spd-fiber-booking-system/models"
+)
+
+// Headers set on every published message
+const (
+	HeaderEventID   = "event-id"
+	HeaderEventType = "event-type"
+)
+
+// Message is what a broker carries: a body routed by topic and partitioned by key
+type Message struct {
+	Topic   string
+	Key     string
+	Body    []byte
+	Headers map[string]string
+}
+
+// Client sends messages to a specific broker
+type Client interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// Publisher publishes domain events through a Client. Each event goes to the
+// topic named by its type, keyed by booking ID so a booking's events stay in order.
+type Publisher struct {
+	client      Client
+	topicPrefix string
+}
+
+func NewPublisher(client Client, topicPrefix string) *Publisher {
+	return &Publisher{client: client, topicPrefix: topicPrefix}
+}
+
+func (p *Publisher) Publish(ctx context.Context, event models.DomainEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	message := Message{
+		Topic: p.topicPrefix + string(event.Type),
+		Key:   event.BookingID,
+		Body:  body,
+		Headers: map[string]string{
+			HeaderEventID:   event.ID,
+			HeaderEventType: string(event.Type),
+		},
+	}
+	if err := p.client.Send(ctx, message); err != nil {
+		return fmt.Errorf("broker: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/touchsung/spd-fiber-booking-system/models"
+	"github.com/touchsung/spd-fiber-booking-system/repository"
+	"github.com/touchsung/spd-fiber-booking-system/usecase"
+)
+
+// Ensure Publisher satisfies usecase.Publisher
+var _ usecase.Publisher = (*Publisher)(nil)
+
+// fakeBroker is a local stand-in for a broker's HTTP ingestion endpoint
+type fakeBroker struct {
+	mutex    sync.Mutex
+	messages []Message
+	fail     bool
+}
+
+func (b *fakeBroker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	topic, found := strings.CutPrefix(r.URL.Path, "/topics/")
+	if r.Method != http.MethodPost || !found {
+		http.NotFound(w, r)
+		return
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.fail {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	b.messages = append(b.messages, Message{
+		Topic: topic,
+		Key:   r.Header.Get(HeaderMessageKey),
+		Body:  body,
+		Headers: map[string]string{
+			HeaderEventID:   r.Header.Get(HeaderEventID),
+			HeaderEventType: r.Header.Get(HeaderEventType),
+		},
+	})
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (b *fakeBroker) received() []Message {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return append([]Message{}, b.messages...)
+}
+
+func newFakeBroker(t *testing.T) (*fakeBroker, *Publisher) {
+	fake := &fakeBroker{}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, NewPublisher(NewHTTPClient(server.URL, server.Client()), "bookings.")
+}
+
+func testEvent() models.DomainEvent {
+	return models.DomainEvent{
+		ID:         "event-1",
+		Type:       models.DomainEventBookingConfirmed,
+		BookingID:  "booking-1",
+		Actor:      models.ActorCreditCheck,
+		Reason:     "credit check approved",
+		OccurredAt: time.Now().UTC().Truncate(time.Millisecond),
+		Booking:    models.Booking{ID: "booking-1", Status: models.StatusConfirmed, Version: 2},
+	}
+}
+
+func TestPublisherSendsEventToTopic(t *testing.T) {
+	fake, publisher := newFakeBroker(t)
+
+	event := testEvent()
+	require.NoError(t, publisher.Publish(context.Background(), event))
+
+	messages := fake.received()
+	require.Len(t, messages, 1)
+	assert.Equal(t, "bookings.booking.confirmed", messages[0].Topic)
+	assert.Equal(t, "booking-1", messages[0].Key)
+	assert.Equal(t, "event-1", messages[0].Headers[HeaderEventID])
+	assert.Equal(t, "booking.confirmed", messages[0].Headers[HeaderEventType])
+
+	var decoded models.DomainEvent
+	require.NoError(t, json.Unmarshal(messages[0].Body, &decoded))
+	assert.Equal(t, event.ID, decoded.ID)
+	assert.Equal(t, models.StatusConfirmed, decoded.Booking.Status)
+	assert.True(t, event.OccurredAt.Equal(decoded.OccurredAt))
+}
+
+func TestPublisherReportsBrokerFailures(t *testing.T) {
+	fake, publisher := newFakeBroker(t)
+	fake.fail = true
+
+	err := publisher.Publish(context.Background(), testEvent())
+	assert.ErrorContains(t, err, "status 503")
+	assert.Empty(t, fake.received())
+}
+
+func TestPublisherBehindOutboxRelay(t *testing.T) {
+	fake, publisher := newFakeBroker(t)
+	fake.fail = true
+
+	bus := usecase.NewEventBus()
+	bus.Subscribe(publisher.Publish)
+	outbox := repository.NewMockRepository()
+	event := testEvent()
+	require.NoError(t, outbox.UpdateBookingStatus(models.StatusChange{
+		BookingID: "1", To: models.StatusCanceled, Actor: models.ActorUser,
+	}, 1, event))
+	config := usecase.DefaultRelayConfig()
+	config.InitialBackoff = 0
+	relay := usecase.NewOutboxRelay(outbox, bus, config)
+
+	// The broker is down: the event stays in the outbox
+	published, err := relay.PublishPending(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, published)
+	pending, err := outbox.PendingEvents(time.Now(), 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, 1, pending[0].Attempts)
+	assert.Contains(t, pending[0].LastError, "status 503")
+
+	fake.mutex.Lock()
+	fake.fail = false
+	fake.mutex.Unlock()
+	published, err = relay.PublishPending(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, published)
+	pending, err = outbox.PendingEvents(time.Now(), 10)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+	assert.Len(t, fake.received(), 1)
+}
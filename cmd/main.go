@@ -1,15 +1,34 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	_ "github.com/lib/pq"
+	"github.com/touchsung/spd-fiber-booking-system/authz"
+	"github.com/touchsung/spd-fiber-booking-system/broker"
+	"github.com/touchsung/spd-fiber-booking-system/config"
+	"github.com/touchsung/spd-fiber-booking-system/creditbureau"
 	_ "github.com/touchsung/spd-fiber-booking-system/docs" // This will be generated
 	"github.com/touchsung/spd-fiber-booking-system/handler"
+	"github.com/touchsung/spd-fiber-booking-system/jobs"
+	"github.com/touchsung/spd-fiber-booking-system/middleware"
+	"github.com/touchsung/spd-fiber-booking-system/models"
+	"github.com/touchsung/spd-fiber-booking-system/pricing"
 	"github.com/touchsung/spd-fiber-booking-system/repository"
 	"github.com/touchsung/spd-fiber-booking-system/router"
 	"github.com/touchsung/spd-fiber-booking-system/usecase"
 	"github.com/touchsung/spd-fiber-booking-system/utils"
+	"github.com/touchsung/spd-fiber-booking-system/webhook"
+	_ "modernc.org/sqlite"
 )
 
 // @title Booking API
@@ -19,31 +38,259 @@ import (
 
 // @host localhost:3000
 // @BasePath /
+
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description "Bearer " followed by a JWT; required when authentication is configured
 func main() {
-	app := fiber.New()
+	cfg, err := config.Load(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		log.Fatalf("invalid configuration: %v", err)
+	}
+
+	app := fiber.New(fiber.Config{
+		ErrorHandler: handler.ErrorHandler,
+	})
 
 	// Initialize dependencies
-	cache := utils.NewInMemoryCache()
-	mockRepo := repository.NewMockRepository()
-	bookingService := usecase.NewBookingService(cache, mockRepo)
+	cache := utils.NewInMemoryCache(
+		utils.WithTTL(cfg.Cache.TTL),
+		utils.WithMaxEntries(cfg.Cache.MaxEntries),
+	)
+	repos, err := newRepositories(cfg.Database)
+	if err != nil {
+		log.Fatalf("failed to initialize repository: %v", err)
+	}
+	jobQueue := jobs.NewQueue(repos.jobs, jobs.DefaultConfig())
+	catalogService := usecase.NewCatalogService(repos.services, cfg.Booking.Currency)
+	policy := authz.DefaultPolicy
+	bookingService := usecase.NewBookingService(cache, repos.bookings,
+		usecase.WithPolicy(policy),
+		usecase.WithServiceCatalog(repos.services),
+		usecase.WithPricingEngine(newPricingEngine(cfg.Pricing)),
+		newCreditCheckerOption(cfg.CreditCheck),
+		usecase.WithCurrency(cfg.Booking.Currency),
+		usecase.WithHighValueThreshold(models.FromMajorUnits(cfg.Booking.HighValueThreshold, cfg.Booking.Currency)),
+		usecase.WithExpiryWindow(cfg.Booking.ExpiryWindow),
+		usecase.WithJobQueue(jobQueue),
+	)
+	// Subscriptions are checked against the same addresses deliveries may reach
+	webhookAddresses := webhook.DefaultAddressPolicy
+	webhookService := usecase.NewWebhookService(repos.webhooks, webhook.NewClient(cfg.Webhooks.Timeout, webhookAddresses), jobQueue,
+		usecase.WithAddressPolicy(webhookAddresses))
+	jobQueue.Register(models.JobTypeCreditCheck, bookingService.HandleCreditCheckJob)
+	jobQueue.Register(models.JobTypeWebhookDelivery, webhookService.HandleDeliveryJob)
+
+	// Other parts of the process subscribe to the bus; the relay feeds it from the outbox
+	eventBus := usecase.NewEventBus()
+	eventBus.Subscribe(webhookService.HandleEvent)
+	if cfg.Events.BrokerURL != "" {
+		eventBus.Subscribe(broker.NewPublisher(broker.NewHTTPClient(cfg.Events.BrokerURL, nil), cfg.Events.TopicPrefix).Publish)
+	}
+	relay := usecase.NewOutboxRelay(repos.bookings, eventBus, usecase.DefaultRelayConfig())
+	if err := jobQueue.Start(); err != nil {
+		log.Fatalf("failed to start job queue: %v", err)
+	}
+
 	bookingHandler := handler.NewBookingHandler(bookingService)
+	jobHandler := handler.NewJobHandler(jobQueue)
+	webhookHandler := handler.NewWebhookHandler(webhookService, policy)
+	serviceHandler := handler.NewServiceHandler(catalogService)
+	configHandler := handler.NewConfigHandler(bookingService)
 
 	// Setup routes
-	router.SetupRoutes(app, bookingHandler)
+	idempotencyStore := middleware.NewMemoryIdempotencyStore(cfg.Idempotency.Retention)
+	authenticate, err := newAuthenticator(cfg.Auth)
+	if err != nil {
+		log.Fatalf("failed to initialize authentication: %v", err)
+	}
+	router.SetupRoutes(app, bookingHandler, jobHandler, webhookHandler, serviceHandler, configHandler, idempotencyStore, authenticate, policy)
+
+	// Stop on SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	// Start background task
-	runBackgroundTask(bookingService)
+	var background sync.WaitGroup
+	runBackgroundTask(ctx, &background, bookingService, cfg.Booking.SweepInterval)
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		relay.Run(ctx, cfg.Events.RelayInterval)
+	}()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- app.Listen(":" + strconv.Itoa(cfg.Server.Port))
+	}()
+
+	select {
+	case err := <-serverErr:
+		log.Printf("server stopped: %v", err)
+	case <-ctx.Done():
+		log.Println("shutting down")
+	}
+	stop()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	// Stop accepting requests and drain the in-flight ones
+	if err := app.ShutdownWithContext(shutdownCtx); err != nil {
+		log.Printf("http shutdown: %v", err)
+	}
+	background.Wait()
+	// Running jobs finish, or are persisted as queued when the timeout hits
+	if err := jobQueue.Shutdown(shutdownCtx); err != nil {
+		log.Printf("job queue shutdown: %v", err)
+	}
+	if err := bookingService.Wait(shutdownCtx); err != nil {
+		log.Printf("credit checks still running at shutdown: %v", err)
+	}
+	if err := repos.close(); err != nil {
+		log.Printf("closing repository: %v", err)
+	}
+}
+
+// bookingStore is a booking repository that keeps the outbox of the events it stores
+type bookingStore interface {
+	repository.BookingRepository
+	repository.Outbox
+}
+
+type repositories struct {
+	bookings bookingStore
+	services repository.ServiceRepository
+	jobs     repository.JobRepository
+	webhooks repository.WebhookRepository
+	close    func() error
+}
+
+// newRepositories opens the configured database ("postgres" or "sqlite"),
+// falling back to in-memory mock repositories when no driver is set
+func newRepositories(cfg config.DatabaseConfig) (*repositories, error) {
+	if cfg.Driver == "" {
+		var bookings bookingStore = repository.NewMockRepository()
+		if cfg.EventSourced {
+			bookings = repository.NewEventSourcedRepository(repository.NewMemoryEventStore())
+		}
+		return &repositories{
+			bookings: bookings,
+			services: repository.NewMockServiceRepository(),
+			jobs:     repository.NewMockJobRepository(),
+			webhooks: repository.NewMockWebhookRepository(),
+			close:    func() error { return nil },
+		}, nil
+	}
+
+	db, err := sql.Open(cfg.Driver, cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	bookings, err := newSQLBookingRepository(db, cfg.EventSourced)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	serviceRepo, err := repository.NewSQLServiceRepository(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	jobRepo, err := repository.NewSQLJobRepository(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	webhookRepo, err := repository.NewSQLWebhookRepository(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &repositories{
+		bookings: bookings,
+		services: serviceRepo,
+		jobs:     jobRepo,
+		webhooks: webhookRepo,
+		close:    db.Close,
+	}, nil
+}
+
+func newSQLBookingRepository(db *sql.DB, eventSourced bool) (bookingStore, error) {
+	if !eventSourced {
+		return repository.NewSQLRepository(db)
+	}
+	store, err := repository.NewSQLEventStore(db)
+	if err != nil {
+		return nil, err
+	}
+	return repository.NewEventSourcedRepository(store), nil
+}
+
+// newCreditCheckerOption uses the real credit bureau when a bureau URL is
+// configured and the simulator otherwise
+func newCreditCheckerOption(cfg config.CreditCheckConfig) usecase.ServiceOption {
+	if cfg.BureauURL == "" {
+		return usecase.WithCreditChecker(usecase.NewSimulatedCreditChecker(cfg.SimulatedDelay))
+	}
+
+	bureauConfig := creditbureau.DefaultConfig(cfg.BureauURL)
+	bureauConfig.APIKey = cfg.BureauAPIKey
+	return usecase.WithCreditChecker(creditbureau.NewClient(bureauConfig, nil))
+}
+
+// newPricingEngine applies the configured volume discounts, then the tax
+func newPricingEngine(cfg config.PricingConfig) *pricing.Engine {
+	tiers := make([]pricing.VolumeTier, len(cfg.VolumeDiscounts))
+	for i, discount := range cfg.VolumeDiscounts {
+		tiers[i] = pricing.VolumeTier{MinQuantity: discount.MinQuantity, Percent: discount.Percent}
+	}
+	return pricing.NewEngine(
+		pricing.VolumeDiscount{Tiers: tiers},
+		pricing.Tax{Name: cfg.TaxName, Percent: cfg.TaxPercent},
+	)
+}
 
-	app.Listen(":3000")
+// newAuthenticator returns the bearer token middleware, or nil when
+// authentication is explicitly disabled
+func newAuthenticator(cfg config.AuthConfig) (fiber.Handler, error) {
+	if cfg.Disabled {
+		log.Println("WARNING: authentication is disabled (auth.disabled); every route is open and no permissions are checked")
+		return nil, nil
+	}
+	authenticator, err := middleware.NewAuthenticator(middleware.AuthConfig{
+		HMACSecret: cfg.HMACSecret,
+		JWKSFile:   cfg.JWKSFile,
+		Issuer:     cfg.Issuer,
+		Audience:   cfg.Audience,
+		Leeway:     cfg.Leeway,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return authenticator.Authenticate(), nil
 }
 
-// Function to run background task for checking expired bookings
-func runBackgroundTask(bookingService *usecase.BookingService) {
+// Function to run background task for checking expired bookings until ctx is canceled
+func runBackgroundTask(ctx context.Context, wg *sync.WaitGroup, bookingService *usecase.BookingService, interval time.Duration) {
+	wg.Add(1)
 	go func() {
-		ticker := time.NewTicker(1 * time.Minute)
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
 		defer ticker.Stop()
-		for range ticker.C {
-			bookingService.CancelExpiredBookings()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				bookingService.CancelExpiredBookings()
+			}
 		}
 	}()
 }
@@ -0,0 +1,252 @@
+// Package config loads service settings from an optional YAML file and
+// environment variables, in that order of precedence (environment wins).
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/touchsung/spd-fiber-booking-system/models"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds every tunable setting of the service
+type Config struct {
+	Server      ServerConfig      `yaml:"server"`
+	Booking     BookingConfig     `yaml:"booking"`
+	CreditCheck CreditCheckConfig `yaml:"credit_check"`
+	Database    DatabaseConfig    `yaml:"database"`
+	Cache       CacheConfig       `yaml:"cache"`
+	Idempotency IdempotencyConfig `yaml:"idempotency"`
+	Events      EventsConfig      `yaml:"events"`
+	Webhooks    WebhooksConfig    `yaml:"webhooks"`
+	Pricing     PricingConfig     `yaml:"pricing"`
+	Auth        AuthConfig        `yaml:"auth"`
+}
+
+type ServerConfig struct {
+	Port            int           `yaml:"port"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+type BookingConfig struct {
+	Currency           string        `yaml:"currency"`             // ISO 4217 code every price is in
+	HighValueThreshold float64       `yaml:"high_value_threshold"` // price in Currency above which a credit check runs
+	ExpiryWindow       time.Duration `yaml:"expiry_window"`        // pending bookings older than this are canceled
+	SweepInterval      time.Duration `yaml:"sweep_interval"`       // how often expired bookings are swept
+}
+
+type CreditCheckConfig struct {
+	SimulatedDelay time.Duration `yaml:"simulated_delay"` // used when no bureau URL is set
+	BureauURL      string        `yaml:"bureau_url"`
+	BureauAPIKey   string        `yaml:"bureau_api_key"`
+}
+
+type DatabaseConfig struct {
+	Driver string `yaml:"driver"` // "postgres", "sqlite" or empty for in-memory storage
+	URL    string `yaml:"url"`
+	// EventSourced stores bookings as event streams instead of rows
+	EventSourced bool `yaml:"event_sourced"`
+}
+
+type CacheConfig struct {
+	TTL        time.Duration `yaml:"ttl"`
+	MaxEntries int           `yaml:"max_entries"`
+}
+
+type IdempotencyConfig struct {
+	Retention time.Duration `yaml:"retention"` // how long Idempotency-Key responses are kept
+}
+
+type EventsConfig struct {
+	RelayInterval time.Duration `yaml:"relay_interval"` // how often the outbox is published
+	BrokerURL     string        `yaml:"broker_url"`     // HTTP ingestion endpoint; events stay in-process when empty
+	TopicPrefix   string        `yaml:"topic_prefix"`   // prepended to the event type to name the topic
+}
+
+type WebhooksConfig struct {
+	Timeout time.Duration `yaml:"timeout"` // per delivery attempt
+}
+
+type PricingConfig struct {
+	VolumeDiscounts []VolumeDiscountConfig `yaml:"volume_discounts"` // the highest tier reached applies
+	TaxName         string                 `yaml:"tax_name"`         // label of the tax in price breakdowns
+	TaxPercent      float64                `yaml:"tax_percent"`      // added after discounts; 0 disables it
+}
+
+// VolumeDiscountConfig takes Percent off bookings of at least MinQuantity units
+type VolumeDiscountConfig struct {
+	MinQuantity int     `yaml:"min_quantity"`
+	Percent     float64 `yaml:"percent"`
+}
+
+// AuthConfig enables bearer token authentication when a secret or JWKS file
+// is set. One of them is required unless Disabled opts out explicitly.
+type AuthConfig struct {
+	HMACSecret string        `yaml:"hmac_secret"` // verifies HS256 tokens
+	JWKSFile   string        `yaml:"jwks_file"`   // RSA public keys that verify RS256 tokens
+	Issuer     string        `yaml:"issuer"`      // required iss claim when set
+	Audience   string        `yaml:"audience"`    // required aud claim when set
+	Leeway     time.Duration `yaml:"leeway"`      // tolerated clock skew
+	Disabled   bool          `yaml:"disabled"`    // serve every route without authentication, e.g. for local development
+}
+
+// Enabled reports whether requests must carry a bearer token
+func (a AuthConfig) Enabled() bool {
+	return a.HMACSecret != "" || a.JWKSFile != ""
+}
+
+// Default returns the settings used when nothing is configured
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Port:            3000,
+			ShutdownTimeout: 30 * time.Second,
+		},
+		Booking: BookingConfig{
+			Currency:           models.DefaultCurrency,
+			HighValueThreshold: 50000,
+			ExpiryWindow:       5 * time.Minute,
+			SweepInterval:      time.Minute,
+		},
+		CreditCheck: CreditCheckConfig{
+			SimulatedDelay: 2 * time.Second,
+		},
+		Cache: CacheConfig{
+			TTL:        10 * time.Minute,
+			MaxEntries: 10000,
+		},
+		Idempotency: IdempotencyConfig{
+			Retention: 24 * time.Hour,
+		},
+		Events: EventsConfig{
+			RelayInterval: time.Second,
+		},
+		Webhooks: WebhooksConfig{
+			Timeout: 10 * time.Second,
+		},
+		Auth: AuthConfig{
+			Leeway: 30 * time.Second,
+		},
+	}
+}
+
+// Load starts from Default, applies the YAML file at path when path is not
+// empty, then applies environment variables and validates the result
+func Load(path string) (Config, error) {
+	config := Default()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return config, fmt.Errorf("reading config file: %w", err)
+		}
+		if err := yaml.Unmarshal(data, &config); err != nil {
+			return config, fmt.Errorf("parsing config file %s: %w", path, err)
+		}
+	}
+	// Unparsable variables leave the previous value in place, so both kinds of
+	// problem can be reported together
+	envErr := config.applyEnv(os.LookupEnv)
+	return config, errors.Join(envErr, config.Validate())
+}
+
+// applyEnv overrides settings with the environment variables that are set
+func (c *Config) applyEnv(lookup func(string) (string, bool)) error {
+	var errs []error
+	parse := func(key string, target any) {
+		raw, ok := lookup(key)
+		if !ok || raw == "" {
+			return
+		}
+		var err error
+		switch target := target.(type) {
+		case *string:
+			*target = raw
+		case *bool:
+			*target, err = strconv.ParseBool(raw)
+		case *int:
+			*target, err = strconv.Atoi(raw)
+		case *float64:
+			*target, err = strconv.ParseFloat(raw, 64)
+		case *time.Duration:
+			*target, err = time.ParseDuration(raw)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid value %q", key, raw))
+		}
+	}
+
+	parse("PORT", &c.Server.Port)
+	parse("SHUTDOWN_TIMEOUT", &c.Server.ShutdownTimeout)
+	parse("CURRENCY", &c.Booking.Currency)
+	parse("HIGH_VALUE_THRESHOLD", &c.Booking.HighValueThreshold)
+	parse("BOOKING_EXPIRY_WINDOW", &c.Booking.ExpiryWindow)
+	parse("EXPIRY_SWEEP_INTERVAL", &c.Booking.SweepInterval)
+	parse("CREDIT_CHECK_DELAY", &c.CreditCheck.SimulatedDelay)
+	parse("CREDIT_BUREAU_URL", &c.CreditCheck.BureauURL)
+	parse("CREDIT_BUREAU_API_KEY", &c.CreditCheck.BureauAPIKey)
+	parse("DB_DRIVER", &c.Database.Driver)
+	parse("DATABASE_URL", &c.Database.URL)
+	parse("EVENT_SOURCING", &c.Database.EventSourced)
+	parse("CACHE_TTL", &c.Cache.TTL)
+	parse("CACHE_MAX_ENTRIES", &c.Cache.MaxEntries)
+	parse("IDEMPOTENCY_RETENTION", &c.Idempotency.Retention)
+	parse("OUTBOX_RELAY_INTERVAL", &c.Events.RelayInterval)
+	parse("EVENT_BROKER_URL", &c.Events.BrokerURL)
+	parse("EVENT_TOPIC_PREFIX", &c.Events.TopicPrefix)
+	parse("WEBHOOK_TIMEOUT", &c.Webhooks.Timeout)
+	parse("PRICING_TAX_NAME", &c.Pricing.TaxName)
+	parse("PRICING_TAX_PERCENT", &c.Pricing.TaxPercent)
+	parse("AUTH_HMAC_SECRET", &c.Auth.HMACSecret)
+	parse("AUTH_JWKS_FILE", &c.Auth.JWKSFile)
+	parse("AUTH_ISSUER", &c.Auth.Issuer)
+	parse("AUTH_AUDIENCE", &c.Auth.Audience)
+	parse("AUTH_LEEWAY", &c.Auth.Leeway)
+	parse("AUTH_DISABLED", &c.Auth.Disabled)
+	return errors.Join(errs...)
+}
+
+// Validate reports every invalid setting at once
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Server.Port > 0 && c.Server.Port <= 65535, "server.port must be between 1 and 65535, got %d", c.Server.Port)
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout must be positive")
+	check(models.ValidCurrency(c.Booking.Currency), "booking.currency must be an ISO 4217 code such as USD, got %q", c.Booking.Currency)
+	check(c.Booking.HighValueThreshold >= 0, "booking.high_value_threshold must not be negative")
+	check(c.Booking.ExpiryWindow > 0, "booking.expiry_window must be positive")
+	check(c.Booking.SweepInterval > 0, "booking.sweep_interval must be positive")
+	check(c.CreditCheck.SimulatedDelay >= 0, "credit_check.simulated_delay must not be negative")
+	switch c.Database.Driver {
+	case "":
+	case "postgres", "sqlite":
+		check(c.Database.URL != "", "database.url is required when database.driver is set")
+	default:
+		check(false, "database.driver must be postgres or sqlite, got %q", c.Database.Driver)
+	}
+	check(c.Cache.TTL >= 0, "cache.ttl must not be negative")
+	check(c.Cache.MaxEntries >= 0, "cache.max_entries must not be negative")
+	check(c.Idempotency.Retention > 0, "idempotency.retention must be positive")
+	check(c.Events.RelayInterval > 0, "events.relay_interval must be positive")
+	check(c.Webhooks.Timeout > 0, "webhooks.timeout must be positive")
+	for i, discount := range c.Pricing.VolumeDiscounts {
+		check(discount.MinQuantity >= 1, "pricing.volume_discounts[%d].min_quantity must be at least 1", i)
+		check(discount.Percent > 0 && discount.Percent <= 100, "pricing.volume_discounts[%d].percent must be above 0 and at most 100", i)
+	}
+	check(c.Pricing.TaxPercent >= 0, "pricing.tax_percent must not be negative")
+	// HS256 keys shorter than the hash are easy to brute-force
+	check(c.Auth.HMACSecret == "" || len(c.Auth.HMACSecret) >= 32, "auth.hmac_secret must be at least 32 bytes")
+	check(c.Auth.Leeway >= 0, "auth.leeway must not be negative")
+	// Running without authentication has to be asked for, never the result of a missing setting
+	check(c.Auth.Enabled() || c.Auth.Disabled, "auth.hmac_secret or auth.jwks_file is required unless auth.disabled is set")
+	check(!c.Auth.Enabled() || !c.Auth.Disabled, "auth.disabled cannot be combined with auth.hmac_secret or auth.jwks_file")
+	return errors.Join(errs...)
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadDefaults(t *testing.T) {
+	t.Setenv("AUTH_DISABLED", "true")
+	config, err := Load("")
+	require.NoError(t, err)
+	expected := Default()
+	expected.Auth.Disabled = true
+	assert.Equal(t, expected, config)
+}
+
+func TestLoadRequiresAuthentication(t *testing.T) {
+	_, err := Load("")
+	assert.ErrorContains(t, err, "auth.hmac_secret or auth.jwks_file is required")
+
+	t.Setenv("AUTH_HMAC_SECRET", "a-test-secret-of-at-least-32-bytes!")
+	_, err = Load("")
+	assert.NoError(t, err)
+
+	t.Setenv("AUTH_DISABLED", "true")
+	_, err = Load("")
+	assert.ErrorContains(t, err, "auth.disabled cannot be combined")
+}
+
+func TestLoadFileThenEnvironment(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+server:
+  port: 8080
+booking:
+  currency: JPY
+  high_value_threshold: 75000
+  expiry_window: 10m
+credit_check:
+  simulated_delay: 500ms
+pricing:
+  volume_discounts:
+    - min_quantity: 5
+      percent: 10
+`), 0o600))
+	t.Setenv("PORT", "9090")
+	t.Setenv("EXPIRY_SWEEP_INTERVAL", "30s")
+	t.Setenv("EVENT_SOURCING", "true")
+	t.Setenv("PRICING_TAX_PERCENT", "7")
+	t.Setenv("AUTH_JWKS_FILE", "/etc/booking/jwks.json")
+
+	config, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, 9090, config.Server.Port, "Expected the environment to override the file")
+	assert.Equal(t, "JPY", config.Booking.Currency)
+	assert.Equal(t, 75000.0, config.Booking.HighValueThreshold)
+	assert.Equal(t, 10*time.Minute, config.Booking.ExpiryWindow)
+	assert.Equal(t, 30*time.Second, config.Booking.SweepInterval)
+	assert.True(t, config.Database.EventSourced)
+	assert.Equal(t, 500*time.Millisecond, config.CreditCheck.SimulatedDelay)
+	assert.Equal(t, []VolumeDiscountConfig{{MinQuantity: 5, Percent: 10}}, config.Pricing.VolumeDiscounts)
+	assert.Equal(t, 7.0, config.Pricing.TaxPercent)
+	assert.True(t, config.Auth.Enabled())
+	assert.Equal(t, 30*time.Second, config.Server.ShutdownTimeout, "Expected unset values to keep their defaults")
+}
+
+func TestLoadRejectsInvalidSettings(t *testing.T) {
+	t.Setenv("PORT", "abc")
+	_, err := Load("")
+	assert.ErrorContains(t, err, "PORT")
+
+	_, err = Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	config := Default()
+	config.Server.Port = 70000
+	config.Booking.ExpiryWindow = 0
+	config.Database.Driver = "mysql"
+	config.Events.RelayInterval = 0
+	config.Booking.Currency = "usd"
+	config.Auth.HMACSecret = "short"
+	config.Pricing.VolumeDiscounts = []VolumeDiscountConfig{{MinQuantity: 5, Percent: 150}}
+
+	err := config.Validate()
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "server.port")
+	assert.ErrorContains(t, err, "booking.expiry_window")
+	assert.ErrorContains(t, err, "booking.currency")
+	assert.ErrorContains(t, err, "database.driver")
+	assert.ErrorContains(t, err, "events.relay_interval")
+	assert.ErrorContains(t, err, "pricing.volume_discounts[0].percent")
+	assert.ErrorContains(t, err, "auth.hmac_secret")
+
+	config = Default()
+	config.Auth.Disabled = true
+	config.Database.Driver = "sqlite"
+	assert.ErrorContains(t, config.Validate(), "database.url")
+}
@@ -0,0 +1,77 @@
+package creditbureau
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without calling the bureau while the breaker is open
+var ErrCircuitOpen = errors.New("credit bureau circuit breaker is open")
+
+type breakerState int
+
+const (
+	stateClosed breakerState = iota
+	stateOpen
+	stateHalfOpen
+)
+
+// circuitBreaker opens after failureThreshold consecutive failures and lets a
+// single trial request through once openTimeout has elapsed. Only failures of
+// the bureau itself are recorded; see isBreakerFailure.
+type circuitBreaker struct {
+	mutex            sync.Mutex
+	state            breakerState
+	failures         int
+	openedAt         time.Time
+	failureThreshold int
+	openTimeout      time.Duration
+	now              func() time.Time
+}
+
+func newCircuitBreaker(failureThreshold int, openTimeout time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		failureThreshold: failureThreshold,
+		openTimeout:      openTimeout,
+		now:              time.Now,
+	}
+}
+
+// allow reports whether a request may be attempted
+func (b *circuitBreaker) allow() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	switch b.state {
+	case stateOpen:
+		if b.now().Sub(b.openedAt) < b.openTimeout {
+			return ErrCircuitOpen
+		}
+		b.state = stateHalfOpen
+		return nil
+	case stateHalfOpen:
+		// Only the trial request may run until it reports back
+		return ErrCircuitOpen
+	default:
+		return nil
+	}
+}
+
+func (b *circuitBreaker) recordSuccess() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.state = stateClosed
+	b.failures = 0
+}
+
+func (b *circuitBreaker) recordFailure() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.failures++
+	if b.state == stateHalfOpen || b.failures >= b.failureThreshold {
+		b.state = stateOpen
+		b.openedAt = b.now()
+	}
+}
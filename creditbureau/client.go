@@ -0,0 +1,219 @@
+// Package creditbureau is an HTTP client for the external credit bureau used
+// to approve high-value bookings.
+package creditbureau
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/touchsung/spd-fiber-booking-system/models"
+)
+
+// Decisions returned by the bureau
+const (
+	DecisionApproved = "approved"
+	DecisionDeclined = "declined"
+)
+
+// Config controls timeouts, retries and the circuit breaker
+type Config struct {
+	BaseURL          string
+	APIKey           string
+	Timeout          time.Duration // per attempt
+	MaxRetries       int           // attempts after the first one
+	InitialBackoff   time.Duration
+	MaxBackoff       time.Duration
+	FailureThreshold int           // consecutive failures that open the breaker
+	OpenTimeout      time.Duration // how long the breaker stays open
+}
+
+// DefaultConfig returns sensible production defaults for baseURL
+func DefaultConfig(baseURL string) Config {
+	return Config{
+		BaseURL:          baseURL,
+		Timeout:          5 * time.Second,
+		MaxRetries:       3,
+		InitialBackoff:   200 * time.Millisecond,
+		MaxBackoff:       5 * time.Second,
+		FailureThreshold: 5,
+		OpenTimeout:      30 * time.Second,
+	}
+}
+
+// checkRequest is the body sent to POST {BaseURL}/credit-checks
+type checkRequest struct {
+	BookingID string `json:"booking_id"`
+	UserID    string `json:"user_id"`
+	Amount    string `json:"amount"`   // decimal in major units, e.g. "600.00"
+	Currency  string `json:"currency"` // ISO 4217 code
+}
+
+// checkResponse is the bureau's answer
+type checkResponse struct {
+	Decision string `json:"decision"`
+}
+
+// statusError is a non-2xx response from the bureau
+type statusError struct {
+	StatusCode int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("credit bureau responded with status %d", e.StatusCode)
+}
+
+// retryable reports whether another attempt could succeed
+func (e *statusError) retryable() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests
+}
+
+// isServerError reports whether the bureau itself failed, as opposed to
+// rejecting the request
+func (e *statusError) isServerError() bool {
+	return e.StatusCode >= 500
+}
+
+type Client struct {
+	config     Config
+	httpClient *http.Client
+	breaker    *circuitBreaker
+	sleep      func(ctx context.Context, d time.Duration) error
+}
+
+func NewClient(config Config, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+	return &Client{
+		config:     config,
+		httpClient: httpClient,
+		breaker:    newCircuitBreaker(config.FailureThreshold, config.OpenTimeout),
+		sleep:      sleepContext,
+	}
+}
+
+// Check asks the bureau to approve booking, retrying transient failures with
+// exponential backoff
+func (c *Client) Check(ctx context.Context, booking *models.Booking) (models.CreditCheckResult, error) {
+	body, err := json.Marshal(checkRequest{
+		BookingID: booking.ID,
+		UserID:    booking.UserID,
+		Amount:    booking.Price.Decimal(),
+		Currency:  booking.Price.Currency,
+	})
+	if err != nil {
+		return models.CreditCheckResult{}, err
+	}
+
+	backoff := c.config.InitialBackoff
+	for attempt := 0; ; attempt++ {
+		if err := c.breaker.allow(); err != nil {
+			return models.CreditCheckResult{}, err
+		}
+
+		decision, err := c.attempt(ctx, body)
+		if isBreakerFailure(err) {
+			c.breaker.recordFailure()
+		} else {
+			// The bureau answered, even when it rejected the request
+			c.breaker.recordSuccess()
+		}
+		if err == nil {
+			return resultFor(booking.ID, decision)
+		}
+
+		if !isRetryable(err) || attempt >= c.config.MaxRetries || ctx.Err() != nil {
+			return models.CreditCheckResult{}, fmt.Errorf("credit check for booking %s: %w", booking.ID, err)
+		}
+		if err := c.sleep(ctx, backoff); err != nil {
+			return models.CreditCheckResult{}, err
+		}
+		backoff = min(backoff*2, c.config.MaxBackoff)
+	}
+}
+
+func (c *Client) attempt(ctx context.Context, body []byte) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+	defer cancel()
+
+	url := strings.TrimRight(c.config.BaseURL, "/") + "/credit-checks"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.config.APIKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		io.Copy(io.Discard, resp.Body)
+		return "", &statusError{StatusCode: resp.StatusCode}
+	}
+
+	var decoded checkResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return "", fmt.Errorf("decode credit bureau response: %w", err)
+	}
+	return decoded.Decision, nil
+}
+
+func resultFor(bookingID, decision string) (models.CreditCheckResult, error) {
+	result := models.CreditCheckResult{BookingID: bookingID}
+	switch decision {
+	case DecisionApproved:
+		result.Status = models.StatusConfirmed
+	case DecisionDeclined:
+		result.Status = models.StatusRejected
+	default:
+		return models.CreditCheckResult{}, fmt.Errorf("unknown credit bureau decision %q", decision)
+	}
+	return result, nil
+}
+
+func isRetryable(err error) bool {
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.retryable()
+	}
+	// Network errors and per-attempt timeouts are worth retrying
+	return true
+}
+
+// isBreakerFailure reports whether err means the bureau is unavailable: a
+// transport error, a timeout or a 5xx response. 4xx responses, 429 included,
+// do not count towards opening the breaker.
+func isBreakerFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.isServerError()
+	}
+	return true
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
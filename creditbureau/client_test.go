@@ -0,0 +1,156 @@
+package creditbureau
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/touchsung/spd-fiber-booking-system/models"
+	"github.com/touchsung/spd-fiber-booking-system/usecase"
+)
+
+// Ensure Client satisfies usecase.CreditChecker
+var _ usecase.CreditChecker = (*Client)(nil)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	config := DefaultConfig(server.URL)
+	config.Timeout = 100 * time.Millisecond
+	config.InitialBackoff = time.Millisecond
+	config.MaxBackoff = 5 * time.Millisecond
+	return NewClient(config, server.Client())
+}
+
+func testBooking() *models.Booking {
+	return &models.Booking{ID: "booking-1", UserID: "user1", Price: models.NewMoney(6000000, "USD")}
+}
+
+func TestClientCheckDecisions(t *testing.T) {
+	tests := []struct {
+		decision string
+		status   models.BookingStatus
+	}{
+		{DecisionApproved, models.StatusConfirmed},
+		{DecisionDeclined, models.StatusRejected},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.decision, func(t *testing.T) {
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/credit-checks", r.URL.Path)
+
+				var body checkRequest
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+				assert.Equal(t, "booking-1", body.BookingID)
+				assert.Equal(t, "60000.00", body.Amount)
+				assert.Equal(t, "USD", body.Currency)
+
+				json.NewEncoder(w).Encode(checkResponse{Decision: tt.decision})
+			})
+
+			result, err := client.Check(context.Background(), testBooking())
+			require.NoError(t, err)
+			assert.Equal(t, "booking-1", result.BookingID)
+			assert.Equal(t, tt.status, result.Status)
+		})
+	}
+}
+
+func TestClientRetriesTransientFailures(t *testing.T) {
+	var calls atomic.Int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(checkResponse{Decision: DecisionApproved})
+	})
+
+	result, err := client.Check(context.Background(), testBooking())
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusConfirmed, result.Status)
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestClientDoesNotRetryClientErrors(t *testing.T) {
+	var calls atomic.Int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	})
+
+	_, err := client.Check(context.Background(), testBooking())
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestClientTimesOutSlowResponses(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	})
+	client.config.MaxRetries = 1
+
+	start := time.Now()
+	_, err := client.Check(context.Background(), testBooking())
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), time.Second, "Expected per-attempt timeout to cut the request short")
+}
+
+func TestClientCircuitBreaker(t *testing.T) {
+	var calls atomic.Int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	client.config.MaxRetries = 0
+	client.breaker.failureThreshold = 2
+
+	now := time.Now()
+	client.breaker.now = func() time.Time { return now }
+
+	client.Check(context.Background(), testBooking())
+	client.Check(context.Background(), testBooking())
+
+	// The breaker is now open and short-circuits without calling the bureau
+	_, err := client.Check(context.Background(), testBooking())
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, int32(2), calls.Load())
+
+	// After the open timeout a trial request is let through
+	now = now.Add(client.config.OpenTimeout)
+	_, err = client.Check(context.Background(), testBooking())
+	assert.NotErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestClientCircuitBreakerIgnoresClientErrors(t *testing.T) {
+	var calls atomic.Int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if calls.Load()%2 == 0 {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	client.config.MaxRetries = 0
+	client.breaker.failureThreshold = 2
+
+	for range 4 {
+		_, err := client.Check(context.Background(), testBooking())
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, ErrCircuitOpen)
+	}
+	assert.Equal(t, int32(4), calls.Load(), "Expected 4xx responses to leave the breaker closed")
+}
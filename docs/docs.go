@@ -18,7 +18,12 @@ const docTemplate = `{
     "paths": {
         "/bookings": {
             "get": {
-                "description": "Get a list of all bookings with optional sorting and filtering. Sort by price or date, or default to ID. Filter high-value bookings (price \u003e 50,000).",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get a page of bookings with optional sorting and filtering. Sort by one or more fields, or default to ID; ties are always broken by ID. Filters combine with AND. Page with limit/offset, or pass the previous page's next_cursor as cursor.",
                 "consumes": [
                     "application/json"
                 ],
@@ -28,35 +33,131 @@ const docTemplate = `{
                 "tags": [
                     "bookings"
                 ],
-                "summary": "List all bookings",
+                "summary": "List bookings",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Sort by field (price or date)",
+                        "description": "Comma-separated sort fields (id, price, created_at or date, status, user_id, service_id); prefix with - for descending, e.g. -price,created_at",
                         "name": "sort",
                         "in": "query"
                     },
                     {
                         "type": "boolean",
-                        "description": "Filter high-value bookings (price \u003e 50,000)",
+                        "description": "Filter high-value bookings (price above the high-value threshold, 50,000 by default)",
                         "name": "high-value",
                         "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only bookings of this user; callers without bookings:read:any only see their own",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only bookings of this service",
+                        "name": "service_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "enum": [
+                                "pending",
+                                "confirmed",
+                                "rejected",
+                                "canceled"
+                            ],
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Only bookings in any of these statuses (repeat or comma-separate)",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Minimum price in the service's currency, inclusive, e.g. 100.50",
+                        "name": "min_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Maximum price in the service's currency, inclusive",
+                        "name": "max_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Created at or after this RFC 3339 timestamp or YYYY-MM-DD date",
+                        "name": "created_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Created before this RFC 3339 timestamp; a YYYY-MM-DD date includes the whole day",
+                        "name": "created_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size (default 20, max 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of bookings to skip; cannot be combined with cursor",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Opaque cursor from a previous page's next_cursor",
+                        "name": "cursor",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/models.Booking"
-                            }
+                            "$ref": "#/definitions/models.BookingPage"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "user_id is another user and the caller lacks bookings:read:any",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
                         }
                     }
                 }
             },
             "post": {
-                "description": "Create a new booking with the provided details. A credit check is performed for bookings with a price greater than 50,000.",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create a new booking with the provided details. The service must exist in the catalog and be active. The price is computed by the server from the service's base price, the quantity and the pricing rules, and returned with a breakdown; a price sent by the client must match it. A credit check is performed according to the service's credit check policy, by default for bookings with a price above the high-value threshold (50,000 by default).",
                 "consumes": [
                     "application/json"
                 ],
@@ -76,6 +177,12 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/models.BookingRequest"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Client-chosen key; retries with the same key and payload replay the first response",
+                        "name": "Idempotency-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -83,18 +190,52 @@ const docTemplate = `{
                         "description": "Created",
                         "schema": {
                             "$ref": "#/definitions/models.Booking"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Booking version"
+                            },
+                            "Location": {
+                                "type": "string",
+                                "description": "URL of the new booking"
+                            }
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/handler.ErrorResponse"
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "user_id is not the authenticated user, or the caller may not create bookings",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Idempotency-Key reused with a different payload, or still in progress",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed, the service is unknown or inactive, or the price does not match",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/handler.ErrorResponse"
+                            "$ref": "#/definitions/handler.Problem"
                         }
                     }
                 }
@@ -102,7 +243,12 @@ const docTemplate = `{
         },
         "/bookings/{id}": {
             "get": {
-                "description": "Get a booking's details by its ID. The booking is retrieved from cache first, then from the mock repository if not found.",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get a booking's details by its ID. The booking is retrieved from cache first, then from the repository if not found. The ETag header carries the booking version for use with If-Match. With at, the booking is rebuilt as it was at that time; this needs event-sourced storage.",
                 "consumes": [
                     "application/json"
                 ],
@@ -120,6 +266,12 @@ const docTemplate = `{
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Return the booking as it was at this RFC 3339 timestamp",
+                        "name": "at",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -127,24 +279,59 @@ const docTemplate = `{
                         "description": "OK",
                         "schema": {
                             "$ref": "#/definitions/models.Booking"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Booking version"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "The caller lacks the permission named in missing_permission",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
                         }
                     },
                     "404": {
-                        "description": "Not Found",
+                        "description": "The booking does not exist or belongs to another user",
                         "schema": {
-                            "$ref": "#/definitions/handler.ErrorResponse"
+                            "$ref": "#/definitions/handler.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/handler.ErrorResponse"
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
                         }
                     }
                 }
             },
             "delete": {
-                "description": "Cancel a booking by its ID. Cannot cancel confirmed bookings. The booking is checked in both cache and repository.",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Cancel a booking by its ID. Pending bookings can be canceled by their owner or by support staff; confirmed bookings only by callers with the bookings:cancel:confirmed permission. Rejected and canceled bookings are final.",
                 "consumes": [
                     "application/json"
                 ],
@@ -162,6 +349,12 @@ const docTemplate = `{
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag from GET /bookings/{id}; the cancel only applies if the booking is unchanged",
+                        "name": "If-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -174,108 +367,1712 @@ const docTemplate = `{
                             }
                         }
                     },
-                    "400": {
-                        "description": "Cannot cancel confirmed booking",
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
-                            "$ref": "#/definitions/handler.ErrorResponse"
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "The caller lacks the permission named in missing_permission",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
                         }
                     },
                     "404": {
-                        "description": "Booking not found",
+                        "description": "The booking does not exist or belongs to another user",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Booking status does not allow cancellation",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Booking changed since the If-Match ETag was issued",
                         "schema": {
-                            "$ref": "#/definitions/handler.ErrorResponse"
+                            "$ref": "#/definitions/handler.Problem"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "handler.ErrorResponse": {
-            "type": "object",
-            "properties": {
-                "error": {
-                    "type": "string"
-                }
-            }
         },
-        "models.Booking": {
-            "description": "Booking information",
-            "type": "object",
-            "properties": {
-                "created_at": {
-                    "type": "string"
-                },
-                "id": {
-                    "type": "string",
-                    "example": "20240319123456"
-                },
-                "price": {
-                    "type": "number",
-                    "example": 60000
-                },
-                "service_id": {
-                    "type": "string",
-                    "example": "service456"
-                },
-                "status": {
-                    "allOf": [
-                        {
-                            "$ref": "#/definitions/models.BookingStatus"
+        "/bookings/{id}/history": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List every status change of a booking, oldest first, with who made it and why.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "bookings"
+                ],
+                "summary": "Get a booking's status history",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Booking ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.StatusChange"
+                            }
                         }
-                    ],
-                    "example": "pending"
-                },
-                "user_id": {
-                    "type": "string",
-                    "example": "user123"
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "The caller lacks the permission named in missing_permission",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "The booking does not exist or belongs to another user",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
                 }
             }
         },
-        "models.BookingRequest": {
-            "description": "Booking creation request",
-            "type": "object",
-            "required": [
-                "price",
-                "service_id",
-                "user_id"
-            ],
-            "properties": {
-                "price": {
-                    "type": "number",
-                    "example": 60000
-                },
-                "service_id": {
-                    "type": "string",
-                    "example": "service456"
-                },
-                "user_id": {
-                    "type": "string",
-                    "example": "user123"
+        "/config": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the booking rules in effect: the high-value threshold above which bookings need a credit check, and how long a booking may stay pending.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "config"
+                ],
+                "summary": "Get the booking settings",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.BookingSettings"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Requires the config:manage permission",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
                 }
-            }
-        },
-        "models.BookingStatus": {
-            "description": "Booking status enum",
-            "type": "string",
-            "enum": [
-                "pending",
-                "confirmed",
-                "rejected",
-                "canceled"
-            ],
-            "x-enum-comments": {
-                "StatusCanceled": "Booking is canceled",
-                "StatusConfirmed": "Booking is confirmed",
-                "StatusPending": "Initial status",
-                "StatusRejected": "Booking is rejected"
             },
-            "x-enum-varnames": [
-                "StatusPending",
-                "StatusConfirmed",
-                "StatusRejected",
+            "patch": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Change the booking rules while the service runs; omitted fields keep their value. Changes apply to new bookings and the next expiry sweep, and last until the service restarts.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "config"
+                ],
+                "summary": "Change the booking settings",
+                "parameters": [
+                    {
+                        "description": "Settings to change",
+                        "name": "settings",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.BookingSettingsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.BookingSettings"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Requires the config:manage permission",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "The threshold is negative or in another currency, or the window is not a positive duration",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/jobs": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List persisted background jobs, optionally filtered by a comma-separated list of statuses (queued, running, succeeded, dead).",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "List background jobs",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Comma-separated job statuses",
+                        "name": "status",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Job"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Requires the jobs:manage permission",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/jobs/stats": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the number of queued and in-flight jobs along with succeeded, retried and dead-lettered counters since startup.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "Get job queue statistics",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/jobs.Stats"
+                        }
+                    },
+                    "403": {
+                        "description": "Requires the jobs:manage permission",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/jobs/{id}/retry": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Move a dead job back onto the queue with a fresh set of attempts.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "Retry a dead-lettered job",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/models.Job"
+                        }
+                    },
+                    "403": {
+                        "description": "Requires the jobs:manage permission",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Job is not dead",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "Queue is full",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/services": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List the service catalog ordered by ID.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "services"
+                ],
+                "summary": "List services",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Only services that can be booked",
+                        "name": "active",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Service"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Add a bookable service. The ID is generated unless one is given.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "services"
+                ],
+                "summary": "Add a service to the catalog",
+                "parameters": [
+                    {
+                        "description": "Service",
+                        "name": "service",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.ServiceRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.Service"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Requires the services:manage permission",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "A service with this ID already exists",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed; see errors",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/services/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "services"
+                ],
+                "summary": "Get a service",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Service ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Service"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Replace a service's name, base price, active flag and credit check policy. Existing bookings are not changed.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "services"
+                ],
+                "summary": "Update a service",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Service ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Service",
+                        "name": "service",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.ServiceRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Service"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Requires the services:manage permission",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed; see errors",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Remove a service from the catalog. Existing bookings keep their service_id; set active to false instead to stop new bookings but keep the service.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "services"
+                ],
+                "summary": "Delete a service",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Service ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "403": {
+                        "description": "Requires the services:manage permission",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List webhook subscriptions. With user_id, only that user's subscriptions and the global ones are returned. Callers without the webhooks:manage permission only see their own subscriptions.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "List webhooks",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only subscriptions that receive this user's events",
+                        "name": "user_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.WebhookSubscription"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "user_id is another user and the caller lacks webhooks:manage",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Register a URL that receives signed JSON callbacks when bookings are confirmed, rejected or canceled. Subscriptions with a user_id only receive that user's bookings. Authenticated callers subscribe to their own bookings; global subscriptions (no user_id) and other users' need the webhooks:manage permission. URLs pointing at internal addresses are rejected. The response is the only place the signing secret is shown.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Register a webhook",
+                "parameters": [
+                    {
+                        "description": "Webhook subscription",
+                        "name": "subscription",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.WebhookSubscriptionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.WebhookSubscription"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "user_id is another user and the caller lacks webhooks:manage",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed, or the URL points at a loopback, private, link-local or unspecified address",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/deliveries/{id}/replay": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Send a failed delivery again with a fresh set of attempts.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Replay a failed delivery",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Delivery ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/models.WebhookDelivery"
+                        }
+                    },
+                    "404": {
+                        "description": "The delivery does not exist or is not for one of the caller's subscriptions",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Delivery has not failed",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "Queue is full",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Get a webhook",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Subscription ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.WebhookSubscription"
+                        }
+                    },
+                    "404": {
+                        "description": "The subscription does not exist or is not the caller's",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Stop sending callbacks to a webhook. Its delivery log is kept.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Delete a webhook",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Subscription ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "The subscription does not exist or is not the caller's",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{id}/deliveries": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List the delivery log of a webhook, oldest first, optionally filtered by a comma-separated list of statuses (pending, succeeded, failed).",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "List a webhook's deliveries",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Subscription ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated delivery statuses",
+                        "name": "status",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.WebhookDelivery"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "The subscription does not exist or is not the caller's",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "authz.Permission": {
+            "type": "string",
+            "enum": [
+                "bookings:create",
+                "bookings:read:own",
+                "bookings:read:any",
+                "bookings:cancel:own",
+                "bookings:cancel:any",
+                "bookings:cancel:confirmed",
+                "services:manage",
+                "webhooks:manage",
+                "jobs:manage",
+                "config:manage"
+            ],
+            "x-enum-varnames": [
+                "PermissionCreateBookings",
+                "PermissionReadOwnBookings",
+                "PermissionReadAnyBooking",
+                "PermissionCancelOwnBookings",
+                "PermissionCancelAnyBooking",
+                "PermissionCancelConfirmedBooking",
+                "PermissionManageServices",
+                "PermissionManageWebhooks",
+                "PermissionManageJobs",
+                "PermissionManageConfig"
+            ]
+        },
+        "handler.Problem": {
+            "description": "RFC 7807 problem details",
+            "type": "object",
+            "properties": {
+                "detail": {
+                    "type": "string",
+                    "example": "booking not found"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/validation.FieldError"
+                    }
+                },
+                "instance": {
+                    "type": "string",
+                    "example": "/bookings/42"
+                },
+                "missing_permission": {
+                    "description": "MissingPermission names the permission a 403 response lacked",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/authz.Permission"
+                        }
+                    ],
+                    "example": "bookings:read:any"
+                },
+                "status": {
+                    "type": "integer",
+                    "example": 404
+                },
+                "title": {
+                    "type": "string",
+                    "example": "Not Found"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "/problems/not-found"
+                }
+            }
+        },
+        "jobs.Stats": {
+            "type": "object",
+            "properties": {
+                "dead": {
+                    "type": "integer"
+                },
+                "in_flight": {
+                    "type": "integer"
+                },
+                "queued": {
+                    "type": "integer"
+                },
+                "retried": {
+                    "type": "integer"
+                },
+                "succeeded": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.Actor": {
+            "type": "string",
+            "enum": [
+                "user",
+                "staff",
+                "credit_check",
+                "expiry_sweeper"
+            ],
+            "x-enum-comments": {
+                "ActorCreditCheck": "the result of a credit check",
+                "ActorExpirySweeper": "the background job canceling stale pending bookings",
+                "ActorStaff": "support or admin staff acting on another user's booking",
+                "ActorUser": "the booking's owner, e.g. through DELETE /bookings/:id"
+            },
+            "x-enum-varnames": [
+                "ActorUser",
+                "ActorStaff",
+                "ActorCreditCheck",
+                "ActorExpirySweeper"
+            ]
+        },
+        "models.Booking": {
+            "description": "Booking information",
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "01HS8ZQX3N8F6D9K2M4P7R1T5V"
+                },
+                "price": {
+                    "description": "total computed by the server",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Money"
+                        }
+                    ]
+                },
+                "price_breakdown": {
+                    "description": "PriceBreakdown explains Price; it is missing on bookings priced before the catalog existed",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.PriceBreakdown"
+                        }
+                    ]
+                },
+                "quantity": {
+                    "type": "integer",
+                    "example": 5
+                },
+                "service_id": {
+                    "type": "string",
+                    "example": "service456"
+                },
+                "status": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.BookingStatus"
+                        }
+                    ],
+                    "example": "pending"
+                },
+                "user_id": {
+                    "type": "string",
+                    "example": "user123"
+                },
+                "version": {
+                    "description": "incremented on every change",
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "models.BookingPage": {
+            "description": "Paginated list of bookings",
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Booking"
+                    }
+                },
+                "limit": {
+                    "type": "integer",
+                    "example": 20
+                },
+                "next_cursor": {
+                    "type": "string",
+                    "example": "eyJzIjoiaWQiLCJ2IjpbIjAxSFM4WlFYM04iXX0"
+                },
+                "offset": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "total": {
+                    "type": "integer",
+                    "example": 42
+                }
+            }
+        },
+        "models.BookingRequest": {
+            "description": "Booking creation request. The price is computed by the server; a price sent by the client is only compared with it.",
+            "type": "object",
+            "required": [
+                "service_id",
+                "user_id"
+            ],
+            "properties": {
+                "price": {
+                    "description": "Price is the total the client expects to pay; the booking is rejected when it differs from the computed price",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Money"
+                        }
+                    ]
+                },
+                "quantity": {
+                    "description": "defaults to 1",
+                    "type": "integer",
+                    "maximum": 1000,
+                    "minimum": 1,
+                    "example": 5
+                },
+                "service_id": {
+                    "type": "string",
+                    "maxLength": 64,
+                    "example": "service456"
+                },
+                "user_id": {
+                    "description": "taken from the bearer token when authentication is on",
+                    "type": "string",
+                    "maxLength": 64,
+                    "example": "user123"
+                }
+            }
+        },
+        "models.BookingSettings": {
+            "description": "Booking rules in effect",
+            "type": "object",
+            "properties": {
+                "expiry_window": {
+                    "description": "how long a booking may stay pending",
+                    "type": "string",
+                    "example": "5m0s"
+                },
+                "high_value_threshold": {
+                    "description": "bookings priced above it need a credit check",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Money"
+                        }
+                    ]
+                }
+            }
+        },
+        "models.BookingSettingsRequest": {
+            "description": "Booking rule changes",
+            "type": "object",
+            "properties": {
+                "expiry_window": {
+                    "description": "a Go duration",
+                    "type": "string",
+                    "example": "10m"
+                },
+                "high_value_threshold": {
+                    "$ref": "#/definitions/models.Money"
+                }
+            }
+        },
+        "models.BookingStatus": {
+            "description": "Booking status enum",
+            "type": "string",
+            "enum": [
+                "pending",
+                "confirmed",
+                "rejected",
+                "canceled"
+            ],
+            "x-enum-comments": {
+                "StatusCanceled": "Booking is canceled",
+                "StatusConfirmed": "Booking is confirmed",
+                "StatusPending": "Initial status",
+                "StatusRejected": "Booking is rejected"
+            },
+            "x-enum-varnames": [
+                "StatusPending",
+                "StatusConfirmed",
+                "StatusRejected",
                 "StatusCanceled"
             ]
+        },
+        "models.CreditCheckPolicy": {
+            "description": "Credit check policy of a service",
+            "type": "string",
+            "enum": [
+                "above_threshold",
+                "always",
+                "never"
+            ],
+            "x-enum-comments": {
+                "CreditCheckAboveThreshold": "Only bookings above the high-value threshold",
+                "CreditCheckAlways": "Every booking",
+                "CreditCheckNever": "No booking"
+            },
+            "x-enum-varnames": [
+                "CreditCheckAboveThreshold",
+                "CreditCheckAlways",
+                "CreditCheckNever"
+            ]
+        },
+        "models.DeliveryStatus": {
+            "type": "string",
+            "enum": [
+                "pending",
+                "succeeded",
+                "failed"
+            ],
+            "x-enum-comments": {
+                "DeliveryFailed": "Gave up after exhausting all attempts",
+                "DeliveryPending": "Waiting for its first attempt or a retry",
+                "DeliverySucceeded": "The endpoint answered with a 2xx status"
+            },
+            "x-enum-varnames": [
+                "DeliveryPending",
+                "DeliverySucceeded",
+                "DeliveryFailed"
+            ]
+        },
+        "models.DomainEventType": {
+            "type": "string",
+            "enum": [
+                "booking.confirmed",
+                "booking.rejected",
+                "booking.canceled"
+            ],
+            "x-enum-varnames": [
+                "DomainEventBookingConfirmed",
+                "DomainEventBookingRejected",
+                "DomainEventBookingCanceled"
+            ]
+        },
+        "models.Job": {
+            "description": "Background job information",
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "01HS8ZQX3N8F6D9K2M4P7R1T5V"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "max_attempts": {
+                    "type": "integer",
+                    "example": 5
+                },
+                "payload": {
+                    "type": "string",
+                    "example": "01HS8ZQX3N8F6D9K2M4P7R1T5V"
+                },
+                "run_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.JobStatus"
+                        }
+                    ],
+                    "example": "queued"
+                },
+                "type": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.JobType"
+                        }
+                    ],
+                    "example": "credit_check"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.JobStatus": {
+            "description": "Job status enum",
+            "type": "string",
+            "enum": [
+                "queued",
+                "running",
+                "succeeded",
+                "dead"
+            ],
+            "x-enum-comments": {
+                "JobStatusDead": "Gave up after exhausting all attempts",
+                "JobStatusQueued": "Waiting for a worker, possibly after a failed attempt",
+                "JobStatusRunning": "Picked up by a worker",
+                "JobStatusSucceeded": "Finished successfully"
+            },
+            "x-enum-varnames": [
+                "JobStatusQueued",
+                "JobStatusRunning",
+                "JobStatusSucceeded",
+                "JobStatusDead"
+            ]
+        },
+        "models.JobType": {
+            "type": "string",
+            "enum": [
+                "credit_check",
+                "webhook_delivery"
+            ],
+            "x-enum-varnames": [
+                "JobTypeCreditCheck",
+                "JobTypeWebhookDelivery"
+            ]
+        },
+        "models.Money": {
+            "description": "Amount of money; amount is a decimal string in the currency's major unit",
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "description": "minor units",
+                    "type": "string",
+                    "example": "600.00"
+                },
+                "currency": {
+                    "description": "ISO 4217 code",
+                    "type": "string",
+                    "example": "USD"
+                }
+            }
+        },
+        "models.PriceAdjustment": {
+            "description": "Price adjustment; discounts are negative",
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "$ref": "#/definitions/models.Money"
+                },
+                "description": {
+                    "type": "string",
+                    "example": "10% off 5 or more"
+                },
+                "rule": {
+                    "type": "string",
+                    "example": "volume_discount"
+                }
+            }
+        },
+        "models.PriceBreakdown": {
+            "description": "How a booking's price was computed",
+            "type": "object",
+            "properties": {
+                "adjustments": {
+                    "description": "in the order they were applied",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.PriceAdjustment"
+                    }
+                },
+                "quantity": {
+                    "type": "integer",
+                    "example": 5
+                },
+                "subtotal": {
+                    "$ref": "#/definitions/models.Money"
+                },
+                "total": {
+                    "$ref": "#/definitions/models.Money"
+                },
+                "unit_price": {
+                    "description": "the service's base price",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Money"
+                        }
+                    ]
+                }
+            }
+        },
+        "models.Service": {
+            "description": "Bookable service",
+            "type": "object",
+            "properties": {
+                "active": {
+                    "description": "inactive services cannot be booked",
+                    "type": "boolean",
+                    "example": true
+                },
+                "base_price": {
+                    "$ref": "#/definitions/models.Money"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "credit_check": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.CreditCheckPolicy"
+                        }
+                    ],
+                    "example": "above_threshold"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "service456"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Airport transfer"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ServiceRequest": {
+            "description": "Service request. Active defaults to true and credit_check to above_threshold.",
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "active": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "base_price": {
+                    "description": "in the service's currency",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Money"
+                        }
+                    ]
+                },
+                "credit_check": {
+                    "enum": [
+                        "above_threshold",
+                        "always",
+                        "never"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.CreditCheckPolicy"
+                        }
+                    ],
+                    "example": "above_threshold"
+                },
+                "id": {
+                    "description": "generated when empty; ignored on update",
+                    "type": "string",
+                    "maxLength": 64,
+                    "example": "service456"
+                },
+                "name": {
+                    "type": "string",
+                    "maxLength": 128,
+                    "example": "Airport transfer"
+                }
+            }
+        },
+        "models.StatusChange": {
+            "description": "A single status transition of a booking",
+            "type": "object",
+            "properties": {
+                "actor": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Actor"
+                        }
+                    ],
+                    "example": "expiry_sweeper"
+                },
+                "booking_id": {
+                    "type": "string",
+                    "example": "01HS8ZQX3N8F6D9K2M4P7R1T5V"
+                },
+                "changed_at": {
+                    "type": "string"
+                },
+                "from": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.BookingStatus"
+                        }
+                    ],
+                    "example": "pending"
+                },
+                "reason": {
+                    "type": "string",
+                    "example": "not confirmed within 5m0s"
+                },
+                "to": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.BookingStatus"
+                        }
+                    ],
+                    "example": "canceled"
+                },
+                "version": {
+                    "description": "booking version after the change",
+                    "type": "integer",
+                    "example": 2
+                }
+            }
+        },
+        "models.WebhookDelivery": {
+            "description": "Webhook delivery log entry",
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "booking_id": {
+                    "type": "string",
+                    "example": "01HS8ZQX3N8F6D9K2M4P7R1T5V"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "delivered_at": {
+                    "type": "string"
+                },
+                "event_id": {
+                    "type": "string",
+                    "example": "01HS8ZQX3N8F6D9K2M4P7R1T5V"
+                },
+                "event_type": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.DomainEventType"
+                        }
+                    ],
+                    "example": "booking.confirmed"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "01HS8ZQX3N8F6D9K2M4P7R1T5V"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "last_status_code": {
+                    "type": "integer",
+                    "example": 200
+                },
+                "status": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.DeliveryStatus"
+                        }
+                    ],
+                    "example": "succeeded"
+                },
+                "subscription_id": {
+                    "type": "string",
+                    "example": "01HS8ZQX3N8F6D9K2M4P7R1T5V"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.WebhookSubscription": {
+            "description": "Webhook subscription. The secret is only returned when the subscription is created.",
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "event_types": {
+                    "description": "empty for every event type",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.DomainEventType"
+                    }
+                },
+                "id": {
+                    "type": "string",
+                    "example": "01HS8ZQX3N8F6D9K2M4P7R1T5V"
+                },
+                "secret": {
+                    "type": "string",
+                    "example": "whsec_3q2+7w=="
+                },
+                "url": {
+                    "type": "string",
+                    "example": "https://example.com/hooks/bookings"
+                },
+                "user_id": {
+                    "description": "empty for global subscriptions",
+                    "type": "string",
+                    "example": "user123"
+                }
+            }
+        },
+        "models.WebhookSubscriptionRequest": {
+            "description": "Webhook subscription request. Leave user_id empty to receive every user's events, and event_types empty for every event type.",
+            "type": "object",
+            "required": [
+                "url"
+            ],
+            "properties": {
+                "event_types": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.DomainEventType"
+                    }
+                },
+                "secret": {
+                    "description": "generated when empty",
+                    "type": "string",
+                    "minLength": 16,
+                    "example": "a-long-shared-secret"
+                },
+                "url": {
+                    "type": "string",
+                    "example": "https://example.com/hooks/bookings"
+                },
+                "user_id": {
+                    "type": "string",
+                    "example": "user123"
+                }
+            }
+        },
+        "validation.FieldError": {
+            "description": "Validation failure for a single field",
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string",
+                    "example": "price"
+                },
+                "message": {
+                    "type": "string",
+                    "example": "price must be greater than 0"
+                },
+                "param": {
+                    "type": "string",
+                    "example": "0"
+                },
+                "rule": {
+                    "type": "string",
+                    "example": "gt"
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "BearerAuth": {
+            "description": "\"Bearer \" followed by a JWT; required when authentication is configured",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
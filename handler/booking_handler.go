@@ -1,131 +1,352 @@
 package handler
 
 import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/touchsung/spd-fiber-booking-system/authz"
+	"github.com/touchsung/spd-fiber-booking-system/middleware"
 	"github.com/touchsung/spd-fiber-booking-system/models"
 	"github.com/touchsung/spd-fiber-booking-system/usecase"
+	"github.com/touchsung/spd-fiber-booking-system/validation"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 type BookingHandler struct {
 	bookingService *usecase.BookingService
+	validator      *validation.Validator
 }
 
 func NewBookingHandler(bookingService *usecase.BookingService) *BookingHandler {
-	return &BookingHandler{bookingService: bookingService}
+	return &BookingHandler{
+		bookingService: bookingService,
+		validator:      validation.New(),
+	}
 }
 
 // Create godoc
 // @Summary Create a new booking
-// @Description Create a new booking with the provided details. A credit check is performed for bookings with a price greater than 50,000.
+// @Description Create a new booking with the provided details. The service must exist in the catalog and be active. The price is computed by the server from the service's base price, the quantity and the pricing rules, and returned with a breakdown; a price sent by the client must match it. A credit check is performed according to the service's credit check policy, by default for bookings with a price above the high-value threshold (50,000 by default).
 // @Tags bookings
 // @Accept json
 // @Produce json
 // @Param booking body models.BookingRequest true "Booking Request"
+// @Param Idempotency-Key header string false "Client-chosen key; retries with the same key and payload replay the first response"
 // @Success 201 {object} models.Booking
-// @Failure 400 {object} ErrorResponse
-// @Failure 500 {object} ErrorResponse
+// @Header 201 {string} ETag "Booking version"
+// @Header 201 {string} Location "URL of the new booking"
+// @Failure 400 {object} Problem
+// @Failure 401 {object} Problem
+// @Failure 403 {object} Problem "user_id is not the authenticated user, or the caller may not create bookings"
+// @Failure 409 {object} Problem "Idempotency-Key reused with a different payload, or still in progress"
+// @Failure 422 {object} Problem "Validation failed, the service is unknown or inactive, or the price does not match"
+// @Failure 500 {object} Problem
+// @Security BearerAuth
 // @Router /bookings [post]
 func (h *BookingHandler) Create(c *fiber.Ctx) error {
 	var request models.BookingRequest
 	if err := c.BodyParser(&request); err != nil {
-		return c.Status(400).JSON(ErrorResponse{
-			Error: "Invalid request body",
-		})
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	if err := h.authorize(c, authz.PermissionCreateBookings); err != nil {
+		return err
+	}
+	// Authenticated callers book for themselves
+	if principal, ok := middleware.PrincipalFrom(c); ok {
+		if request.UserID != "" && request.UserID != principal.UserID {
+			return fiber.NewError(fiber.StatusForbidden, "user_id must be the authenticated user")
+		}
+		request.UserID = principal.UserID
+	}
+
+	if err := h.validator.Struct(request); err != nil {
+		return err
 	}
 
 	booking, err := h.bookingService.CreateBooking(request)
 	if err != nil {
-		return c.Status(500).JSON(ErrorResponse{
-			Error: "Failed to create booking",
-		})
+		return err
 	}
 
+	c.Set(fiber.HeaderETag, bookingETag(booking))
+	c.Location("/bookings/" + booking.ID)
 	return c.Status(201).JSON(booking)
 }
 
 // GetBooking godoc
 // @Summary Get a booking by ID
-// @Description Get a booking's details by its ID. The booking is retrieved from cache first, then from the mock repository if not found.
+// @Description Get a booking's details by its ID. The booking is retrieved from cache first, then from the repository if not found. The ETag header carries the booking version for use with If-Match. With at, the booking is rebuilt as it was at that time; this needs event-sourced storage.
 // @Tags bookings
 // @Accept json
 // @Produce json
 // @Param id path string true "Booking ID"
+// @Param at query string false "Return the booking as it was at this RFC 3339 timestamp"
 // @Success 200 {object} models.Booking
-// @Failure 404 {object} ErrorResponse
-// @Failure 500 {object} ErrorResponse
+// @Header 200 {string} ETag "Booking version"
+// @Failure 400 {object} Problem
+// @Failure 401 {object} Problem
+// @Failure 403 {object} Problem "The caller lacks the permission named in missing_permission"
+// @Failure 404 {object} Problem "The booking does not exist or belongs to another user"
+// @Failure 500 {object} Problem
+// @Failure 501 {object} Problem
+// @Security BearerAuth
 // @Router /bookings/{id} [get]
 func (h *BookingHandler) GetBooking(c *fiber.Ctx) error {
 	bookingID := c.Params("id")
 
-	booking, err := h.bookingService.GetBooking(bookingID)
+	at, err := optionalQueryTime(c, "at", false)
+	if err != nil {
+		return err
+	}
+	if at != nil {
+		// Past states cannot be updated, so they carry no ETag
+		booking, err := h.bookingService.GetBookingAt(bookingID, *at)
+		if err != nil {
+			return err
+		}
+		if err := h.authorizeBooking(c, booking, authz.PermissionReadOwnBookings, authz.PermissionReadAnyBooking); err != nil {
+			return err
+		}
+		return c.JSON(booking)
+	}
+
+	booking, err := h.authorizedBooking(c, bookingID, authz.PermissionReadOwnBookings, authz.PermissionReadAnyBooking)
 	if err != nil {
-		return c.Status(404).JSON(ErrorResponse{
-			Error: "Booking not found",
-		})
+		return err
 	}
 
+	c.Set(fiber.HeaderETag, bookingETag(booking))
 	return c.JSON(booking)
 }
 
+// GetBookingHistory godoc
+// @Summary Get a booking's status history
+// @Description List every status change of a booking, oldest first, with who made it and why.
+// @Tags bookings
+// @Accept json
+// @Produce json
+// @Param id path string true "Booking ID"
+// @Success 200 {array} models.StatusChange
+// @Failure 401 {object} Problem
+// @Failure 403 {object} Problem "The caller lacks the permission named in missing_permission"
+// @Failure 404 {object} Problem "The booking does not exist or belongs to another user"
+// @Failure 500 {object} Problem
+// @Security BearerAuth
+// @Router /bookings/{id}/history [get]
+func (h *BookingHandler) GetBookingHistory(c *fiber.Ctx) error {
+	bookingID := c.Params("id")
+	if _, err := h.authorizedBooking(c, bookingID, authz.PermissionReadOwnBookings, authz.PermissionReadAnyBooking); err != nil {
+		return err
+	}
+
+	history, err := h.bookingService.GetBookingHistory(bookingID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(history)
+}
+
 // ListBookings godoc
-// @Summary List all bookings
-// @Description Get a list of all bookings with optional sorting and filtering. Sort by price or date, or default to ID. Filter high-value bookings (price > 50,000).
+// @Summary List bookings
+// @Description Get a page of bookings with optional sorting and filtering. Sort by one or more fields, or default to ID; ties are always broken by ID. Filters combine with AND. Page with limit/offset, or pass the previous page's next_cursor as cursor.
 // @Tags bookings
 // @Accept json
 // @Produce json
-// @Param sort query string false "Sort by field (price or date)"
-// @Param high-value query bool false "Filter high-value bookings (price > 50,000)"
-// @Success 200 {array} models.Booking
+// @Param sort query string false "Comma-separated sort fields (id, price, created_at or date, status, user_id, service_id); prefix with - for descending, e.g. -price,created_at"
+// @Param high-value query bool false "Filter high-value bookings (price above the high-value threshold, 50,000 by default)"
+// @Param user_id query string false "Only bookings of this user; callers without bookings:read:any only see their own"
+// @Param service_id query string false "Only bookings of this service"
+// @Param status query []string false "Only bookings in any of these statuses (repeat or comma-separate)" collectionFormat(multi) Enums(pending, confirmed, rejected, canceled)
+// @Param min_price query string false "Minimum price in the service's currency, inclusive, e.g. 100.50"
+// @Param max_price query string false "Maximum price in the service's currency, inclusive"
+// @Param created_from query string false "Created at or after this RFC 3339 timestamp or YYYY-MM-DD date"
+// @Param created_to query string false "Created before this RFC 3339 timestamp; a YYYY-MM-DD date includes the whole day"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param offset query int false "Number of bookings to skip; cannot be combined with cursor"
+// @Param cursor query string false "Opaque cursor from a previous page's next_cursor"
+// @Success 200 {object} models.BookingPage
+// @Failure 400 {object} Problem
+// @Failure 401 {object} Problem
+// @Failure 403 {object} Problem "user_id is another user and the caller lacks bookings:read:any"
+// @Failure 500 {object} Problem
+// @Security BearerAuth
 // @Router /bookings [get]
 func (h *BookingHandler) ListBookings(c *fiber.Ctx) error {
 	// Parse query parameters
-	var sortBy *models.SortOption
-	if sort := c.Query("sort"); sort != "" {
-		switch sort {
-		case "price":
-			option := models.SortByPrice
-			sortBy = &option
-		case "date":
-			option := models.SortByDate
-			sortBy = &option
+	var query models.BookingQuery
+	var err error
+	if query.Sort, err = models.ParseSort(c.Query("sort")); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	if query.Filter, err = parseBookingFilter(c, h.bookingService.Currency()); err != nil {
+		return err
+	}
+	if principal, ok := middleware.PrincipalFrom(c); ok {
+		// Callers who cannot read every booking only list their own
+		policy := h.bookingService.Policy()
+		if query.Filter.UserID == "" && !policy.Allows(principal, authz.PermissionReadAnyBooking) {
+			query.Filter.UserID = principal.UserID
 		}
+		permission := authz.PermissionReadAnyBooking
+		if query.Filter.UserID == principal.UserID {
+			permission = authz.PermissionReadOwnBookings
+		}
+		if err := policy.Require(principal, permission); err != nil {
+			return err
+		}
+	}
+	if query.Limit, err = nonNegativeQueryInt(c, "limit"); err != nil {
+		return err
+	}
+	if query.Offset, err = nonNegativeQueryInt(c, "offset"); err != nil {
+		return err
+	}
+	query.Cursor = c.Query("cursor")
+	if query.Cursor != "" && query.Offset > 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "offset cannot be combined with cursor")
+	}
+
+	page, err := h.bookingService.ListBookings(query)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(page)
+}
+
+// parseBookingFilter reads the filter query parameters of GET /bookings; price
+// bounds are amounts of currency
+func parseBookingFilter(c *fiber.Ctx, currency string) (models.BookingFilter, error) {
+	filter := models.BookingFilter{
+		UserID:    c.Query("user_id"),
+		ServiceID: c.Query("service_id"),
 	}
 
-	var highValueOnly *bool
 	if highValue := c.Query("high-value"); highValue != "" {
-		value := highValue == "true"
-		highValueOnly = &value
+		filter.HighValueOnly = highValue == "true"
+	}
+
+	// status may be repeated and each value may hold a comma-separated list
+	for _, raw := range c.Context().QueryArgs().PeekMulti("status") {
+		for _, value := range strings.Split(string(raw), ",") {
+			status := models.BookingStatus(strings.TrimSpace(value))
+			if status == "" {
+				continue
+			}
+			if !status.Valid() {
+				return filter, fiber.NewError(fiber.StatusBadRequest, "unknown status "+strconv.Quote(string(status)))
+			}
+			if !slices.Contains(filter.Statuses, status) {
+				filter.Statuses = append(filter.Statuses, status)
+			}
+		}
 	}
 
-	bookings := h.bookingService.ListBookings(sortBy, highValueOnly)
-	return c.JSON(bookings)
+	var err error
+	if filter.MinPrice, err = optionalQueryPrice(c, "min_price", currency); err != nil {
+		return filter, err
+	}
+	if filter.MaxPrice, err = optionalQueryPrice(c, "max_price", currency); err != nil {
+		return filter, err
+	}
+	if filter.MinPrice != nil && filter.MaxPrice != nil && filter.MinPrice.Amount > filter.MaxPrice.Amount {
+		return filter, fiber.NewError(fiber.StatusBadRequest, "min_price cannot be greater than max_price")
+	}
+
+	if filter.CreatedFrom, err = optionalQueryTime(c, "created_from", false); err != nil {
+		return filter, err
+	}
+	if filter.CreatedBefore, err = optionalQueryTime(c, "created_to", true); err != nil {
+		return filter, err
+	}
+	if filter.CreatedFrom != nil && filter.CreatedBefore != nil && !filter.CreatedFrom.Before(*filter.CreatedBefore) {
+		return filter, fiber.NewError(fiber.StatusBadRequest, "created_from must be before created_to")
+	}
+	return filter, nil
+}
+
+// optionalQueryPrice parses an optional non-negative decimal amount of currency
+func optionalQueryPrice(c *fiber.Ctx, key, currency string) (*models.Money, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return nil, nil
+	}
+	value, err := models.ParseMoney(raw, currency)
+	if err != nil || value.IsNegative() {
+		return nil, fiber.NewError(fiber.StatusBadRequest,
+			fmt.Sprintf("%s must be a non-negative amount with at most %d decimal places", key, models.MinorUnitDigits(currency)))
+	}
+	return &value, nil
+}
+
+// optionalQueryTime parses an optional RFC 3339 timestamp or YYYY-MM-DD date.
+// When endOfDay is set a bare date covers the whole day, so the returned bound
+// is midnight of the following day.
+func optionalQueryTime(c *fiber.Ctx, key string, endOfDay bool) (*time.Time, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return nil, nil
+	}
+	if value, err := time.Parse(time.RFC3339, raw); err == nil {
+		return &value, nil
+	}
+	value, err := time.ParseInLocation(time.DateOnly, raw, time.Local)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, key+" must be an RFC 3339 timestamp or a YYYY-MM-DD date")
+	}
+	if endOfDay {
+		value = value.AddDate(0, 0, 1)
+	}
+	return &value, nil
+}
+
+// nonNegativeQueryInt parses an optional integer query parameter, defaulting to zero
+func nonNegativeQueryInt(c *fiber.Ctx, key string) (int, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return 0, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < 0 {
+		return 0, fiber.NewError(fiber.StatusBadRequest, key+" must be a non-negative integer")
+	}
+	return value, nil
 }
 
 // CancelBooking godoc
 // @Summary Cancel a booking
-// @Description Cancel a booking by its ID. Cannot cancel confirmed bookings. The booking is checked in both cache and repository.
+// @Description Cancel a booking by its ID. Pending bookings can be canceled by their owner or by support staff; confirmed bookings only by callers with the bookings:cancel:confirmed permission. Rejected and canceled bookings are final.
 // @Tags bookings
 // @Accept json
 // @Produce json
 // @Param id path string true "Booking ID"
+// @Param If-Match header string false "ETag from GET /bookings/{id}; the cancel only applies if the booking is unchanged"
 // @Success 200 {object} map[string]string
-// @Failure 400 {object} ErrorResponse "Cannot cancel confirmed booking"
-// @Failure 404 {object} ErrorResponse "Booking not found"
+// @Failure 401 {object} Problem
+// @Failure 403 {object} Problem "The caller lacks the permission named in missing_permission"
+// @Failure 404 {object} Problem "The booking does not exist or belongs to another user"
+// @Failure 409 {object} Problem "Booking status does not allow cancellation"
+// @Failure 412 {object} Problem "Booking changed since the If-Match ETag was issued"
+// @Security BearerAuth
 // @Router /bookings/{id} [delete]
 func (h *BookingHandler) CancelBooking(c *fiber.Ctx) error {
 	bookingID := c.Params("id")
 
-	err := h.bookingService.CancelBooking(bookingID)
+	expectedVersion, err := ifMatchVersion(c)
 	if err != nil {
-		if err.Error() == "cannot cancel a confirmed booking" {
-			return c.Status(400).JSON(ErrorResponse{
-				Error: err.Error(),
-			})
-		}
-		return c.Status(404).JSON(ErrorResponse{
-			Error: "Booking not found",
-		})
+		return err
+	}
+	if _, err := h.authorizedBooking(c, bookingID, authz.PermissionCancelOwnBookings, authz.PermissionCancelAnyBooking); err != nil {
+		return err
+	}
+	principal, _ := middleware.PrincipalFrom(c)
+	if err := h.bookingService.CancelBooking(bookingID, expectedVersion, principal); err != nil {
+		return err
 	}
 
 	return c.JSON(fiber.Map{
@@ -133,6 +354,61 @@ func (h *BookingHandler) CancelBooking(c *fiber.Ctx) error {
 	})
 }
 
-type ErrorResponse struct {
-	Error string `json:"error"`
+// authorize checks that the caller holds permission. Requests are not checked
+// when authentication is disabled.
+func (h *BookingHandler) authorize(c *fiber.Ctx, permission authz.Permission) error {
+	if principal, ok := middleware.PrincipalFrom(c); ok {
+		return h.bookingService.Policy().Require(principal, permission)
+	}
+	return nil
+}
+
+// authorizedBooking loads a booking the caller may act on, see authorizeBooking
+func (h *BookingHandler) authorizedBooking(c *fiber.Ctx, bookingID string, own, others authz.Permission) (*models.Booking, error) {
+	booking, err := h.bookingService.GetBooking(bookingID)
+	if err != nil {
+		return nil, err
+	}
+	return booking, h.authorizeBooking(c, booking, own, others)
+}
+
+// authorizeBooking checks the caller holds own for their bookings and others
+// for other users'. Callers who cannot read other users' bookings get not found
+// for them, so booking IDs cannot be probed.
+func (h *BookingHandler) authorizeBooking(c *fiber.Ctx, booking *models.Booking, own, others authz.Permission) error {
+	principal, ok := middleware.PrincipalFrom(c)
+	if !ok {
+		return nil
+	}
+	policy := h.bookingService.Policy()
+	if booking.UserID == principal.UserID {
+		return policy.Require(principal, own)
+	}
+	if !policy.Allows(principal, authz.PermissionReadAnyBooking) {
+		return &usecase.Error{Kind: usecase.ErrNotFound, Message: "booking not found"}
+	}
+	return policy.Require(principal, others)
+}
+
+// bookingETag is a strong entity tag derived from the booking version
+func bookingETag(booking *models.Booking) string {
+	return strconv.Quote(strconv.FormatInt(booking.Version, 10))
+}
+
+// ifMatchVersion returns the booking version required by the If-Match header,
+// or zero when the header is absent or "*". Weak or malformed tags can never
+// match the current version, so they fail the precondition outright.
+func ifMatchVersion(c *fiber.Ctx) (int64, error) {
+	ifMatch := strings.TrimSpace(c.Get(fiber.HeaderIfMatch))
+	if ifMatch == "" || ifMatch == "*" {
+		return 0, nil
+	}
+	if len(ifMatch) < 2 || ifMatch[0] != '"' || ifMatch[len(ifMatch)-1] != '"' {
+		return 0, fiber.NewError(fiber.StatusPreconditionFailed, "If-Match must be a single strong ETag")
+	}
+	version, err := strconv.ParseInt(ifMatch[1:len(ifMatch)-1], 10, 64)
+	if err != nil || version <= 0 {
+		return 0, fiber.NewError(fiber.StatusPreconditionFailed, "If-Match does not match the booking")
+	}
+	return version, nil
 }
@@ -0,0 +1,256 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/touchsung/spd-fiber-booking-system/authz"
+	"github.com/touchsung/spd-fiber-booking-system/middleware"
+	"github.com/touchsung/spd-fiber-booking-system/models"
+	"github.com/touchsung/spd-fiber-booking-system/repository"
+	"github.com/touchsung/spd-fiber-booking-system/usecase"
+	"github.com/touchsung/spd-fiber-booking-system/utils"
+)
+
+func TestParseBookingFilter(t *testing.T) {
+	var filter models.BookingFilter
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/bookings", func(c *fiber.Ctx) error {
+		var err error
+		filter, err = parseBookingFilter(c, "USD")
+		return err
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET",
+		"/bookings?user_id=user1&service_id=service2&status=pending,confirmed&status=pending&min_price=100&max_price=2500.5&created_from=2024-03-01T10:00:00Z&created_to=2024-03-19", nil))
+	require.NoError(t, err)
+	require.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "user1", filter.UserID)
+	assert.Equal(t, "service2", filter.ServiceID)
+	assert.Equal(t, []models.BookingStatus{models.StatusPending, models.StatusConfirmed}, filter.Statuses)
+	assert.Equal(t, models.NewMoney(10000, "USD"), *filter.MinPrice)
+	assert.Equal(t, models.NewMoney(250050, "USD"), *filter.MaxPrice)
+	assert.True(t, filter.CreatedFrom.Equal(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)))
+	// A bare end date includes the whole day
+	assert.True(t, filter.CreatedBefore.Equal(time.Date(2024, 3, 20, 0, 0, 0, 0, time.Local)))
+
+	for _, query := range []string{
+		"status=archived",
+		"min_price=abc",
+		"max_price=-1",
+		"max_price=10.005",
+		"min_price=200&max_price=100",
+		"created_from=yesterday",
+		"created_from=2024-03-02&created_to=2024-03-01",
+	} {
+		resp, err := app.Test(httptest.NewRequest("GET", "/bookings?"+query, nil))
+		require.NoError(t, err)
+		assert.Equal(t, 400, resp.StatusCode, query)
+	}
+}
+
+func TestBookingETagAndIfMatch(t *testing.T) {
+	repo := repository.NewMockRepository()
+	repo.ClearBookings()
+	service := usecase.NewBookingService(utils.NewInMemoryCache(), repo)
+	bookingHandler := NewBookingHandler(service)
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/bookings/:id", bookingHandler.GetBooking)
+	app.Delete("/bookings/:id", bookingHandler.CancelBooking)
+
+	booking, err := service.CreateBooking(models.BookingRequest{UserID: "user1", ServiceID: "service1"})
+	require.NoError(t, err)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/bookings/"+booking.ID, nil))
+	require.NoError(t, err)
+	etag := resp.Header.Get("ETag")
+	assert.Equal(t, `"1"`, etag)
+
+	for _, ifMatch := range []string{`"2"`, `W/"1"`, `1`} {
+		req := httptest.NewRequest("DELETE", "/bookings/"+booking.ID, nil)
+		req.Header.Set("If-Match", ifMatch)
+		resp, err = app.Test(req)
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusPreconditionFailed, resp.StatusCode, ifMatch)
+	}
+
+	req := httptest.NewRequest("DELETE", "/bookings/"+booking.ID, nil)
+	req.Header.Set("If-Match", etag)
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/bookings/"+booking.ID, nil))
+	require.NoError(t, err)
+	assert.Equal(t, `"2"`, resp.Header.Get("ETag"))
+}
+
+func TestGetBookingAtPointInTime(t *testing.T) {
+	repo := repository.NewEventSourcedRepository(repository.NewMemoryEventStore())
+	service := usecase.NewBookingService(utils.NewInMemoryCache(), repo)
+	bookingHandler := NewBookingHandler(service)
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/bookings/:id", bookingHandler.GetBooking)
+
+	booking, err := service.CreateBooking(models.BookingRequest{UserID: "user1", ServiceID: "service1"})
+	require.NoError(t, err)
+	createdAt := booking.CreatedAt.Format(time.RFC3339Nano)
+	require.NoError(t, service.CancelBooking(booking.ID, 0, authz.Principal{}))
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/bookings/"+booking.ID+"?at="+url.QueryEscape(createdAt), nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("ETag"))
+	var past models.Booking
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&past))
+	assert.Equal(t, models.StatusPending, past.Status)
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/bookings/"+booking.ID+"?at=yesterday", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+	// Storage without event history cannot answer point-in-time reads
+	plain := NewBookingHandler(usecase.NewBookingService(utils.NewInMemoryCache(), repository.NewMockRepository()))
+	app = fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/bookings/:id", plain.GetBooking)
+	resp, err = app.Test(httptest.NewRequest("GET", "/bookings/1?at="+url.QueryEscape(createdAt), nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusNotImplemented, resp.StatusCode)
+}
+
+const testSecret = "a-test-secret-of-at-least-32-bytes!"
+
+// tokenFor signs a token for userID with the given roles claim
+func tokenFor(t *testing.T, userID string, roles ...authz.Role) string {
+	claims := jwt.MapClaims{"sub": userID, "exp": time.Now().Add(time.Hour).Unix()}
+	if len(roles) > 0 {
+		claims["roles"] = roles
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testSecret))
+	require.NoError(t, err)
+	return token
+}
+
+func TestBookingsAreScopedToTheAuthenticatedUser(t *testing.T) {
+	authenticator, err := middleware.NewAuthenticator(middleware.AuthConfig{HMACSecret: testSecret})
+	require.NoError(t, err)
+	repo := repository.NewMockRepository()
+	service := usecase.NewBookingService(utils.NewInMemoryCache(), repo, usecase.WithCreditChecker(usecase.NewSimulatedCreditChecker(0)))
+	bookingHandler := NewBookingHandler(service)
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Use(authenticator.Authenticate())
+	app.Get("/bookings", bookingHandler.ListBookings)
+	app.Post("/bookings", bookingHandler.Create)
+	app.Get("/bookings/:id", bookingHandler.GetBooking)
+	app.Get("/bookings/:id/history", bookingHandler.GetBookingHistory)
+	app.Delete("/bookings/:id", bookingHandler.CancelBooking)
+
+	send := func(method, target, user, body string) int {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if user != "" {
+			req.Header.Set("Authorization", "Bearer "+tokenFor(t, user))
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, fiber.StatusUnauthorized, send("GET", "/bookings", "", ""))
+
+	// The booking belongs to the caller whatever the body says
+	req := httptest.NewRequest("POST", "/bookings", strings.NewReader(`{"service_id":"service1"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+tokenFor(t, "user1"))
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, fiber.StatusCreated, resp.StatusCode)
+	var created models.Booking
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+	assert.Equal(t, "user1", created.UserID)
+	assert.Equal(t, "/bookings/"+created.ID, resp.Header.Get(fiber.HeaderLocation))
+	assert.Equal(t, fiber.StatusForbidden, send("POST", "/bookings", "user1", `{"user_id":"user2","service_id":"service1"}`))
+
+	// Other users' bookings look like they do not exist
+	assert.Equal(t, fiber.StatusOK, send("GET", "/bookings/"+created.ID, "user1", ""))
+	assert.Equal(t, fiber.StatusNotFound, send("GET", "/bookings/"+created.ID, "user2", ""))
+	assert.Equal(t, fiber.StatusNotFound, send("GET", "/bookings/"+created.ID+"/history", "user2", ""))
+	assert.Equal(t, fiber.StatusNotFound, send("DELETE", "/bookings/"+created.ID, "user2", ""))
+	assert.Equal(t, fiber.StatusNotFound, send("GET", "/bookings/2", "user1", ""), "seeded booking 2 belongs to user2")
+
+	// Listings only hold the caller's bookings
+	req = httptest.NewRequest("GET", "/bookings", nil)
+	req.Header.Set("Authorization", "Bearer "+tokenFor(t, "user1"))
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	var page models.BookingPage
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+	for _, booking := range page.Data {
+		assert.Equal(t, "user1", booking.UserID)
+	}
+	assert.Equal(t, 2, page.Total, "the seeded booking 1 and the new one")
+	assert.Equal(t, fiber.StatusForbidden, send("GET", "/bookings?user_id=user2", "user1", ""))
+
+	assert.Equal(t, fiber.StatusOK, send("DELETE", "/bookings/"+created.ID, "user1", ""))
+}
+
+func TestStaffRolesReachEveryBooking(t *testing.T) {
+	authenticator, err := middleware.NewAuthenticator(middleware.AuthConfig{HMACSecret: testSecret})
+	require.NoError(t, err)
+	repo := repository.NewMockRepository()
+	service := usecase.NewBookingService(utils.NewInMemoryCache(), repo, usecase.WithCreditChecker(usecase.NewSimulatedCreditChecker(0)))
+	confirmed := &models.Booking{ID: "confirmed", UserID: "user1", ServiceID: "service1", Price: models.NewMoney(10000, models.DefaultCurrency),
+		Status: models.StatusConfirmed, Version: 1, CreatedAt: time.Now()}
+	require.NoError(t, repo.SaveBooking(confirmed))
+	bookingHandler := NewBookingHandler(service)
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Use(authenticator.Authenticate())
+	app.Get("/bookings", bookingHandler.ListBookings)
+	app.Get("/bookings/:id", bookingHandler.GetBooking)
+	app.Delete("/bookings/:id", bookingHandler.CancelBooking)
+
+	send := func(method, target, token string) *http.Response {
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
+	}
+	customer := tokenFor(t, "user1", authz.RoleCustomer)
+	support := tokenFor(t, "agent1", authz.RoleSupport)
+
+	// Only the policy decides what the booking's owner may do with a confirmed booking
+	resp := send("DELETE", "/bookings/confirmed", customer)
+	assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+	var problem Problem
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+	assert.Equal(t, "/problems/forbidden", problem.Type)
+	assert.Equal(t, authz.PermissionCancelConfirmedBooking, problem.MissingPermission)
+
+	// Support staff see every booking and may cancel confirmed ones
+	resp = send("GET", "/bookings", support)
+	require.Equal(t, fiber.StatusOK, resp.StatusCode)
+	var page models.BookingPage
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+	assert.Greater(t, page.Total, 2, "Expected bookings of several users")
+	assert.Equal(t, fiber.StatusOK, send("GET", "/bookings?user_id=user2", support).StatusCode)
+	assert.Equal(t, fiber.StatusOK, send("GET", "/bookings/confirmed", support).StatusCode)
+	assert.Equal(t, fiber.StatusOK, send("DELETE", "/bookings/confirmed", support).StatusCode)
+
+	// Roles the policy does not know grant nothing
+	resp = send("GET", "/bookings", tokenFor(t, "user1", "auditor"))
+	assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+	assert.Equal(t, authz.PermissionReadOwnBookings, problem.MissingPermission)
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/touchsung/spd-fiber-booking-system/models"
+	"github.com/touchsung/spd-fiber-booking-system/usecase"
+)
+
+type ConfigHandler struct {
+	bookingService *usecase.BookingService
+}
+
+func NewConfigHandler(bookingService *usecase.BookingService) *ConfigHandler {
+	return &ConfigHandler{bookingService: bookingService}
+}
+
+// GetConfig godoc
+// @Summary Get the booking settings
+// @Description Get the booking rules in effect: the high-value threshold above which bookings need a credit check, and how long a booking may stay pending.
+// @Tags config
+// @Accept json
+// @Produce json
+// @Success 200 {object} models.BookingSettings
+// @Failure 401 {object} Problem
+// @Failure 403 {object} Problem "Requires the config:manage permission"
+// @Security BearerAuth
+// @Router /config [get]
+func (h *ConfigHandler) GetConfig(c *fiber.Ctx) error {
+	return c.JSON(h.bookingService.Settings())
+}
+
+// UpdateConfig godoc
+// @Summary Change the booking settings
+// @Description Change the booking rules while the service runs; omitted fields keep their value. Changes apply to new bookings and the next expiry sweep, and last until the service restarts.
+// @Tags config
+// @Accept json
+// @Produce json
+// @Param settings body models.BookingSettingsRequest true "Settings to change"
+// @Success 200 {object} models.BookingSettings
+// @Failure 400 {object} Problem
+// @Failure 401 {object} Problem
+// @Failure 403 {object} Problem "Requires the config:manage permission"
+// @Failure 422 {object} Problem "The threshold is negative or in another currency, or the window is not a positive duration"
+// @Security BearerAuth
+// @Router /config [patch]
+func (h *ConfigHandler) UpdateConfig(c *fiber.Ctx) error {
+	var request models.BookingSettingsRequest
+	if err := c.BodyParser(&request); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	settings, err := h.bookingService.UpdateSettings(request)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(settings)
+}
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/touchsung/spd-fiber-booking-system/authz"
+	"github.com/touchsung/spd-fiber-booking-system/jobs"
+	"github.com/touchsung/spd-fiber-booking-system/repository"
+	"github.com/touchsung/spd-fiber-booking-system/usecase"
+	"github.com/touchsung/spd-fiber-booking-system/validation"
+)
+
+// ProblemContentType is the media type of RFC 7807 error bodies
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details body
+// @Description RFC 7807 problem details
+type Problem struct {
+	Type     string                  `json:"type" example:"/problems/not-found"`
+	Title    string                  `json:"title" example:"Not Found"`
+	Status   int                     `json:"status" example:"404"`
+	Detail   string                  `json:"detail,omitempty" example:"booking not found"`
+	Instance string                  `json:"instance,omitempty" example:"/bookings/42"`
+	Errors   []validation.FieldError `json:"errors,omitempty"`
+	// MissingPermission names the permission a 403 response lacked
+	MissingPermission authz.Permission `json:"missing_permission,omitempty" example:"bookings:read:any"`
+}
+
+// problemMapping ties an error kind to the status and problem type it renders as
+type problemMapping struct {
+	target error
+	status int
+	slug   string
+}
+
+// problemMappings is consulted in order; the first errors.Is match wins
+var problemMappings = []problemMapping{
+	{usecase.ErrNotFound, http.StatusNotFound, "not-found"},
+	{usecase.ErrInvalidTransition, http.StatusConflict, "invalid-transition"},
+	{usecase.ErrConflict, http.StatusConflict, "conflict"},
+	{usecase.ErrValidation, http.StatusUnprocessableEntity, "validation"},
+	{usecase.ErrPreconditionFailed, http.StatusPreconditionFailed, "precondition-failed"},
+	{usecase.ErrUnsupported, http.StatusNotImplemented, "unsupported"},
+	{usecase.ErrForbidden, http.StatusForbidden, "forbidden"},
+	{authz.ErrForbidden, http.StatusForbidden, "forbidden"},
+	{repository.ErrInvalidCursor, http.StatusBadRequest, "invalid-cursor"},
+	{repository.ErrInvalidSort, http.StatusBadRequest, "invalid-sort"},
+	{repository.ErrJobNotFound, http.StatusNotFound, "not-found"},
+	{jobs.ErrJobNotDead, http.StatusConflict, "conflict"},
+	{jobs.ErrQueueFull, http.StatusServiceUnavailable, "unavailable"},
+	{jobs.ErrQueueClosed, http.StatusServiceUnavailable, "unavailable"},
+}
+
+// ErrorHandler renders errors returned by handlers as problem+json. Install it
+// through fiber.Config.ErrorHandler so handlers can simply return errors.
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	problem := problemFor(err)
+	problem.Instance = c.OriginalURL()
+
+	c.Status(problem.Status)
+	return c.JSON(problem, ProblemContentType)
+}
+
+func problemFor(err error) Problem {
+	var fieldErrs validation.Errors
+	if errors.As(err, &fieldErrs) {
+		problem := newProblem(http.StatusUnprocessableEntity, "validation", "request validation failed")
+		problem.Errors = fieldErrs
+		return problem
+	}
+
+	for _, mapping := range problemMappings {
+		if errors.Is(err, mapping.target) {
+			problem := newProblem(mapping.status, mapping.slug, err.Error())
+			var forbidden *authz.ForbiddenError
+			if errors.As(err, &forbidden) {
+				problem.MissingPermission = forbidden.Permission
+			}
+			return problem
+		}
+	}
+
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return newProblem(fiberErr.Code, "", fiberErr.Message)
+	}
+
+	// Unknown errors may carry internal details, so they are not echoed back
+	return newProblem(http.StatusInternalServerError, "", "")
+}
+
+func newProblem(status int, slug, detail string) Problem {
+	problemType := "about:blank"
+	if slug != "" {
+		problemType = "/problems/" + slug
+	}
+	return Problem{
+		Type:   problemType,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
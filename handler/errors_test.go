@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/touchsung/spd-fiber-booking-system/usecase"
+	"github.com/touchsung/spd-fiber-booking-system/validation"
+)
+
+func TestErrorHandlerRendersProblems(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		typ    string
+		detail string
+	}{
+		{"not found", &usecase.Error{Kind: usecase.ErrNotFound, Message: "booking not found"}, 404, "/problems/not-found", "booking not found"},
+		{"wrapped conflict", fmt.Errorf("save: %w", usecase.ErrConflict), 409, "/problems/conflict", "save: conflict"},
+		{"fiber error", fiber.NewError(400, "Invalid request body"), 400, "about:blank", "Invalid request body"},
+		{"unknown error", errors.New("pq: connection refused"), 500, "about:blank", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+			app.Get("/bookings/:id", func(c *fiber.Ctx) error { return tt.err })
+
+			resp, err := app.Test(httptest.NewRequest("GET", "/bookings/42", nil))
+			require.NoError(t, err)
+			assert.Equal(t, tt.status, resp.StatusCode)
+			assert.Equal(t, ProblemContentType, resp.Header.Get("Content-Type"))
+
+			var problem Problem
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+			assert.Equal(t, tt.status, problem.Status)
+			assert.Equal(t, tt.typ, problem.Type)
+			assert.Equal(t, tt.detail, problem.Detail)
+			assert.Equal(t, "/bookings/42", problem.Instance)
+		})
+	}
+}
+
+func TestErrorHandlerIncludesValidationErrors(t *testing.T) {
+	problem := problemFor(validation.Errors{{Field: "price", Rule: "gt", Param: "0", Message: "price must be greater than 0"}})
+
+	assert.Equal(t, 422, problem.Status)
+	assert.Equal(t, "/problems/validation", problem.Type)
+	require.Len(t, problem.Errors, 1)
+	assert.Equal(t, "price", problem.Errors[0].Field)
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/touchsung/spd-fiber-booking-system/jobs"
+	"github.com/touchsung/spd-fiber-booking-system/models"
+)
+
+type JobHandler struct {
+	queue *jobs.Queue
+}
+
+func NewJobHandler(queue *jobs.Queue) *JobHandler {
+	return &JobHandler{queue: queue}
+}
+
+// ListJobs godoc
+// @Summary List background jobs
+// @Description List persisted background jobs, optionally filtered by a comma-separated list of statuses (queued, running, succeeded, dead).
+// @Tags jobs
+// @Accept json
+// @Produce json
+// @Param status query string false "Comma-separated job statuses"
+// @Success 200 {array} models.Job
+// @Failure 500 {object} Problem
+// @Failure 403 {object} Problem "Requires the jobs:manage permission"
+// @Security BearerAuth
+// @Router /jobs [get]
+func (h *JobHandler) ListJobs(c *fiber.Ctx) error {
+	var statuses []models.JobStatus
+	if status := c.Query("status"); status != "" {
+		for _, value := range strings.Split(status, ",") {
+			statuses = append(statuses, models.JobStatus(strings.TrimSpace(value)))
+		}
+	}
+
+	jobList, err := h.queue.Jobs(statuses...)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(jobList)
+}
+
+// Stats godoc
+// @Summary Get job queue statistics
+// @Description Get the number of queued and in-flight jobs along with succeeded, retried and dead-lettered counters since startup.
+// @Tags jobs
+// @Accept json
+// @Produce json
+// @Success 200 {object} jobs.Stats
+// @Failure 403 {object} Problem "Requires the jobs:manage permission"
+// @Security BearerAuth
+// @Router /jobs/stats [get]
+func (h *JobHandler) Stats(c *fiber.Ctx) error {
+	return c.JSON(h.queue.Stats())
+}
+
+// RetryJob godoc
+// @Summary Retry a dead-lettered job
+// @Description Move a dead job back onto the queue with a fresh set of attempts.
+// @Tags jobs
+// @Accept json
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 202 {object} models.Job
+// @Failure 404 {object} Problem
+// @Failure 409 {object} Problem "Job is not dead"
+// @Failure 503 {object} Problem "Queue is full"
+// @Failure 403 {object} Problem "Requires the jobs:manage permission"
+// @Security BearerAuth
+// @Router /jobs/{id}/retry [post]
+func (h *JobHandler) RetryJob(c *fiber.Ctx) error {
+	job, err := h.queue.Retry(c.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(202).JSON(job)
+}
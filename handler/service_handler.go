@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/touchsung/spd-fiber-booking-system/models"
+	"github.com/touchsung/spd-fiber-booking-system/usecase"
+	"github.com/touchsung/spd-fiber-booking-system/validation"
+)
+
+type ServiceHandler struct {
+	catalogService *usecase.CatalogService
+	validator      *validation.Validator
+}
+
+func NewServiceHandler(catalogService *usecase.CatalogService) *ServiceHandler {
+	return &ServiceHandler{
+		catalogService: catalogService,
+		validator:      validation.New(),
+	}
+}
+
+// CreateService godoc
+// @Summary Add a service to the catalog
+// @Description Add a bookable service. The ID is generated unless one is given.
+// @Tags services
+// @Accept json
+// @Produce json
+// @Param service body models.ServiceRequest true "Service"
+// @Success 201 {object} models.Service
+// @Failure 400 {object} Problem
+// @Failure 409 {object} Problem "A service with this ID already exists"
+// @Failure 422 {object} Problem "Validation failed; see errors"
+// @Failure 500 {object} Problem
+// @Failure 403 {object} Problem "Requires the services:manage permission"
+// @Security BearerAuth
+// @Router /services [post]
+func (h *ServiceHandler) CreateService(c *fiber.Ctx) error {
+	var request models.ServiceRequest
+	if err := c.BodyParser(&request); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Struct(request); err != nil {
+		return err
+	}
+
+	service, err := h.catalogService.CreateService(request)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(201).JSON(service)
+}
+
+// ListServices godoc
+// @Summary List services
+// @Description List the service catalog ordered by ID.
+// @Tags services
+// @Accept json
+// @Produce json
+// @Param active query bool false "Only services that can be booked"
+// @Success 200 {array} models.Service
+// @Failure 500 {object} Problem
+// @Security BearerAuth
+// @Router /services [get]
+func (h *ServiceHandler) ListServices(c *fiber.Ctx) error {
+	services, err := h.catalogService.ListServices(c.Query("active") == "true")
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(services)
+}
+
+// GetService godoc
+// @Summary Get a service
+// @Tags services
+// @Accept json
+// @Produce json
+// @Param id path string true "Service ID"
+// @Success 200 {object} models.Service
+// @Failure 404 {object} Problem
+// @Failure 500 {object} Problem
+// @Security BearerAuth
+// @Router /services/{id} [get]
+func (h *ServiceHandler) GetService(c *fiber.Ctx) error {
+	service, err := h.catalogService.GetService(c.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(service)
+}
+
+// UpdateService godoc
+// @Summary Update a service
+// @Description Replace a service's name, base price, active flag and credit check policy. Existing bookings are not changed.
+// @Tags services
+// @Accept json
+// @Produce json
+// @Param id path string true "Service ID"
+// @Param service body models.ServiceRequest true "Service"
+// @Success 200 {object} models.Service
+// @Failure 400 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 422 {object} Problem "Validation failed; see errors"
+// @Failure 500 {object} Problem
+// @Failure 403 {object} Problem "Requires the services:manage permission"
+// @Security BearerAuth
+// @Router /services/{id} [put]
+func (h *ServiceHandler) UpdateService(c *fiber.Ctx) error {
+	var request models.ServiceRequest
+	if err := c.BodyParser(&request); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Struct(request); err != nil {
+		return err
+	}
+
+	service, err := h.catalogService.UpdateService(c.Params("id"), request)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(service)
+}
+
+// DeleteService godoc
+// @Summary Delete a service
+// @Description Remove a service from the catalog. Existing bookings keep their service_id; set active to false instead to stop new bookings but keep the service.
+// @Tags services
+// @Accept json
+// @Produce json
+// @Param id path string true "Service ID"
+// @Success 204
+// @Failure 404 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure 403 {object} Problem "Requires the services:manage permission"
+// @Security BearerAuth
+// @Router /services/{id} [delete]
+func (h *ServiceHandler) DeleteService(c *fiber.Ctx) error {
+	if err := h.catalogService.DeleteService(c.Params("id")); err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
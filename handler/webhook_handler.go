@@ -0,0 +1,231 @@
+package handler
+
+import (
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/touchsung/spd-fiber-booking-system/authz"
+	"github.com/touchsung/spd-fiber-booking-system/middleware"
+	"github.com/touchsung/spd-fiber-booking-system/models"
+	"github.com/touchsung/spd-fiber-booking-system/usecase"
+	"github.com/touchsung/spd-fiber-booking-system/validation"
+)
+
+type WebhookHandler struct {
+	webhookService *usecase.WebhookService
+	policy         *authz.Policy
+	validator      *validation.Validator
+}
+
+// NewWebhookHandler returns a handler that lets authenticated callers manage
+// their own subscriptions; global and other users' subscriptions need the
+// policy's authz.PermissionManageWebhooks
+func NewWebhookHandler(webhookService *usecase.WebhookService, policy *authz.Policy) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: webhookService,
+		policy:         policy,
+		validator:      validation.New(),
+	}
+}
+
+// CreateSubscription godoc
+// @Summary Register a webhook
+// @Description Register a URL that receives signed JSON callbacks when bookings are confirmed, rejected or canceled. Subscriptions with a user_id only receive that user's bookings. Authenticated callers subscribe to their own bookings; global subscriptions (no user_id) and other users' need the webhooks:manage permission. URLs pointing at internal addresses are rejected. The response is the only place the signing secret is shown.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param subscription body models.WebhookSubscriptionRequest true "Webhook subscription"
+// @Success 201 {object} models.WebhookSubscription
+// @Failure 400 {object} Problem
+// @Failure 422 {object} Problem "Validation failed, or the URL points at a loopback, private, link-local or unspecified address"
+// @Failure 500 {object} Problem
+// @Failure 403 {object} Problem "user_id is another user and the caller lacks webhooks:manage"
+// @Security BearerAuth
+// @Router /webhooks [post]
+func (h *WebhookHandler) CreateSubscription(c *fiber.Ctx) error {
+	var request models.WebhookSubscriptionRequest
+	if err := c.BodyParser(&request); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	// Callers who cannot manage every webhook subscribe to their own bookings
+	if principal, ok := middleware.PrincipalFrom(c); ok && !h.policy.Allows(principal, authz.PermissionManageWebhooks) {
+		if request.UserID != "" && request.UserID != principal.UserID {
+			return &authz.ForbiddenError{Permission: authz.PermissionManageWebhooks}
+		}
+		request.UserID = principal.UserID
+	}
+
+	if err := h.validator.Struct(request); err != nil {
+		return err
+	}
+
+	subscription, err := h.webhookService.CreateSubscription(request)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(201).JSON(subscription)
+}
+
+// ListSubscriptions godoc
+// @Summary List webhooks
+// @Description List webhook subscriptions. With user_id, only that user's subscriptions and the global ones are returned. Callers without the webhooks:manage permission only see their own subscriptions.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param user_id query string false "Only subscriptions that receive this user's events"
+// @Success 200 {array} models.WebhookSubscription
+// @Failure 500 {object} Problem
+// @Failure 403 {object} Problem "user_id is another user and the caller lacks webhooks:manage"
+// @Security BearerAuth
+// @Router /webhooks [get]
+func (h *WebhookHandler) ListSubscriptions(c *fiber.Ctx) error {
+	userID := c.Query("user_id")
+	principal, ok := middleware.PrincipalFrom(c)
+	ownOnly := ok && !h.policy.Allows(principal, authz.PermissionManageWebhooks)
+	if ownOnly {
+		if userID != "" && userID != principal.UserID {
+			return &authz.ForbiddenError{Permission: authz.PermissionManageWebhooks}
+		}
+		userID = principal.UserID
+	}
+
+	subscriptions, err := h.webhookService.ListSubscriptions(userID)
+	if err != nil {
+		return err
+	}
+	if ownOnly {
+		// The listing includes the global subscriptions, which are not the caller's
+		subscriptions = slices.DeleteFunc(subscriptions, func(subscription *models.WebhookSubscription) bool {
+			return subscription.UserID != principal.UserID
+		})
+	}
+
+	return c.JSON(subscriptions)
+}
+
+// GetSubscription godoc
+// @Summary Get a webhook
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path string true "Subscription ID"
+// @Success 200 {object} models.WebhookSubscription
+// @Failure 404 {object} Problem "The subscription does not exist or is not the caller's"
+// @Failure 500 {object} Problem
+// @Security BearerAuth
+// @Router /webhooks/{id} [get]
+func (h *WebhookHandler) GetSubscription(c *fiber.Ctx) error {
+	subscription, err := h.authorizedSubscription(c, c.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(subscription)
+}
+
+// DeleteSubscription godoc
+// @Summary Delete a webhook
+// @Description Stop sending callbacks to a webhook. Its delivery log is kept.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path string true "Subscription ID"
+// @Success 204
+// @Failure 404 {object} Problem "The subscription does not exist or is not the caller's"
+// @Failure 500 {object} Problem
+// @Security BearerAuth
+// @Router /webhooks/{id} [delete]
+func (h *WebhookHandler) DeleteSubscription(c *fiber.Ctx) error {
+	if _, err := h.authorizedSubscription(c, c.Params("id")); err != nil {
+		return err
+	}
+	if err := h.webhookService.DeleteSubscription(c.Params("id")); err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ListDeliveries godoc
+// @Summary List a webhook's deliveries
+// @Description List the delivery log of a webhook, oldest first, optionally filtered by a comma-separated list of statuses (pending, succeeded, failed).
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path string true "Subscription ID"
+// @Param status query string false "Comma-separated delivery statuses"
+// @Success 200 {array} models.WebhookDelivery
+// @Failure 404 {object} Problem "The subscription does not exist or is not the caller's"
+// @Failure 500 {object} Problem
+// @Security BearerAuth
+// @Router /webhooks/{id}/deliveries [get]
+func (h *WebhookHandler) ListDeliveries(c *fiber.Ctx) error {
+	if _, err := h.authorizedSubscription(c, c.Params("id")); err != nil {
+		return err
+	}
+
+	var statuses []models.DeliveryStatus
+	if status := c.Query("status"); status != "" {
+		for _, value := range strings.Split(status, ",") {
+			statuses = append(statuses, models.DeliveryStatus(strings.TrimSpace(value)))
+		}
+	}
+
+	deliveries, err := h.webhookService.ListDeliveries(c.Params("id"), statuses...)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(deliveries)
+}
+
+// ReplayDelivery godoc
+// @Summary Replay a failed delivery
+// @Description Send a failed delivery again with a fresh set of attempts.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path string true "Delivery ID"
+// @Success 202 {object} models.WebhookDelivery
+// @Failure 404 {object} Problem "The delivery does not exist or is not for one of the caller's subscriptions"
+// @Failure 409 {object} Problem "Delivery has not failed"
+// @Failure 503 {object} Problem "Queue is full"
+// @Security BearerAuth
+// @Router /webhooks/deliveries/{id}/replay [post]
+func (h *WebhookHandler) ReplayDelivery(c *fiber.Ctx) error {
+	delivery, err := h.webhookService.GetDelivery(c.Params("id"))
+	if err != nil {
+		return err
+	}
+	if _, err := h.authorizedSubscription(c, delivery.SubscriptionID); err != nil {
+		if errors.Is(err, usecase.ErrNotFound) {
+			return &usecase.Error{Kind: usecase.ErrNotFound, Message: "webhook delivery not found"}
+		}
+		return err
+	}
+
+	delivery, err = h.webhookService.ReplayDelivery(delivery.ID)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(202).JSON(delivery)
+}
+
+// authorizedSubscription loads a subscription the caller may manage: their own,
+// or any with authz.PermissionManageWebhooks. Other subscriptions are reported
+// as not found, so subscription IDs cannot be probed.
+func (h *WebhookHandler) authorizedSubscription(c *fiber.Ctx, subscriptionID string) (*models.WebhookSubscription, error) {
+	subscription, err := h.webhookService.GetSubscription(subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	principal, ok := middleware.PrincipalFrom(c)
+	if ok && subscription.UserID != principal.UserID && !h.policy.Allows(principal, authz.PermissionManageWebhooks) {
+		return nil, &usecase.Error{Kind: usecase.ErrNotFound, Message: "webhook subscription not found"}
+	}
+	return subscription, nil
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/touchsung/spd-fiber-booking-system/authz"
+	"github.com/touchsung/spd-fiber-booking-system/middleware"
+	"github.com/touchsung/spd-fiber-booking-system/models"
+	"github.com/touchsung/spd-fiber-booking-system/repository"
+	"github.com/touchsung/spd-fiber-booking-system/usecase"
+)
+
+func TestWebhookSubscriptionsAreScopedToTheCaller(t *testing.T) {
+	authenticator, err := middleware.NewAuthenticator(middleware.AuthConfig{HMACSecret: testSecret})
+	require.NoError(t, err)
+	webhookHandler := NewWebhookHandler(usecase.NewWebhookService(repository.NewMockWebhookRepository(), nil, nil), authz.DefaultPolicy)
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Use(authenticator.Authenticate())
+	app.Post("/webhooks", webhookHandler.CreateSubscription)
+	app.Get("/webhooks", webhookHandler.ListSubscriptions)
+	app.Get("/webhooks/:id", webhookHandler.GetSubscription)
+	app.Delete("/webhooks/:id", webhookHandler.DeleteSubscription)
+
+	send := func(method, target, token, body string) *http.Response {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
+	}
+	customer := tokenFor(t, "user1")
+	admin := tokenFor(t, "root", authz.RoleAdmin)
+
+	// Customers subscribe to their own bookings, whatever the body says
+	resp := send("POST", "/webhooks", customer, `{"url":"https://example.com/mine"}`)
+	require.Equal(t, fiber.StatusCreated, resp.StatusCode)
+	var mine models.WebhookSubscription
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&mine))
+	assert.Equal(t, "user1", mine.UserID)
+
+	// Internal addresses cannot be subscribed to
+	for _, url := range []string{"http://127.0.0.1:3000/jobs", "http://169.254.169.254/latest/meta-data/", "http://[::1]/"} {
+		resp = send("POST", "/webhooks", customer, `{"url":"`+url+`"}`)
+		assert.Equal(t, fiber.StatusUnprocessableEntity, resp.StatusCode, url)
+	}
+
+	resp = send("POST", "/webhooks", customer, `{"url":"https://example.com/theirs","user_id":"user2"}`)
+	assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+	var problem Problem
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+	assert.Equal(t, authz.PermissionManageWebhooks, problem.MissingPermission)
+
+	// Global subscriptions are for staff only, and hidden from customers
+	resp = send("POST", "/webhooks", admin, `{"url":"https://example.com/all"}`)
+	require.Equal(t, fiber.StatusCreated, resp.StatusCode)
+	var global models.WebhookSubscription
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&global))
+	assert.Empty(t, global.UserID)
+
+	list := func(token, target string) []models.WebhookSubscription {
+		resp := send("GET", target, token, "")
+		require.Equal(t, fiber.StatusOK, resp.StatusCode)
+		var subscriptions []models.WebhookSubscription
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&subscriptions))
+		return subscriptions
+	}
+	if subscriptions := list(customer, "/webhooks"); assert.Len(t, subscriptions, 1) {
+		assert.Equal(t, mine.ID, subscriptions[0].ID)
+	}
+	assert.Len(t, list(admin, "/webhooks"), 2)
+	assert.Equal(t, fiber.StatusForbidden, send("GET", "/webhooks?user_id=user2", customer, "").StatusCode)
+
+	assert.Equal(t, fiber.StatusOK, send("GET", "/webhooks/"+mine.ID, customer, "").StatusCode)
+	assert.Equal(t, fiber.StatusNotFound, send("GET", "/webhooks/"+global.ID, customer, "").StatusCode)
+	assert.Equal(t, fiber.StatusNotFound, send("DELETE", "/webhooks/"+global.ID, customer, "").StatusCode)
+	assert.Equal(t, fiber.StatusOK, send("GET", "/webhooks/"+mine.ID, admin, "").StatusCode)
+	assert.Equal(t, fiber.StatusNoContent, send("DELETE", "/webhooks/"+mine.ID, customer, "").StatusCode)
+}
@@ -0,0 +1,417 @@
+// Package jobs runs persisted background work on a bounded worker pool.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/touchsung/spd-fiber-booking-system/models"
+	"github.com/touchsung/spd-fiber-booking-system/repository"
+	"github.com/touchsung/spd-fiber-booking-system/utils"
+)
+
+var (
+	// ErrQueueFull is returned by Enqueue when QueueSize jobs are already outstanding
+	ErrQueueFull = errors.New("job queue is full")
+	// ErrQueueClosed is returned once Shutdown has been called
+	ErrQueueClosed = errors.New("job queue is shut down")
+	// ErrJobNotDead is returned when retrying a job that has not been dead-lettered
+	ErrJobNotDead = errors.New("only dead jobs can be retried")
+)
+
+// Handler processes a single job. Returning an error schedules a retry until
+// the job runs out of attempts, after which it is dead-lettered.
+type Handler func(ctx context.Context, job *models.Job) error
+
+type Config struct {
+	Workers        int           // concurrent handlers
+	QueueSize      int           // outstanding jobs (queued, retrying or running)
+	MaxAttempts    int           // attempts before a job is dead-lettered
+	InitialBackoff time.Duration // delay before the first retry, doubled for each further retry
+	MaxBackoff     time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Workers:        4,
+		QueueSize:      1000,
+		MaxAttempts:    5,
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Minute,
+	}
+}
+
+// Stats is a snapshot of queue activity
+type Stats struct {
+	Queued    int    `json:"queued"`
+	InFlight  int    `json:"in_flight"`
+	Succeeded uint64 `json:"succeeded"`
+	Retried   uint64 `json:"retried"`
+	Dead      uint64 `json:"dead"`
+}
+
+type Queue struct {
+	config   Config
+	store    repository.JobRepository
+	handlers map[models.JobType]Handler
+
+	// slots holds one token per outstanding job and bounds the queue. Because
+	// ready has the same capacity, a job holding a slot can always be sent
+	// to ready without blocking.
+	slots chan struct{}
+	ready chan string
+
+	ctx     context.Context // passed to handlers, canceled on forced shutdown
+	cancel  context.CancelFunc
+	stop    chan struct{}
+	workers sync.WaitGroup
+
+	mutex       sync.Mutex
+	timers      map[string]*time.Timer
+	closed      bool
+	stats       Stats
+	now         func() time.Time
+	idGenerator utils.IDGenerator
+}
+
+// QueueOption customizes optional Queue dependencies
+type QueueOption func(*Queue)
+
+// WithIDGenerator overrides the default ULID generator for job IDs
+func WithIDGenerator(generator utils.IDGenerator) QueueOption {
+	return func(q *Queue) {
+		q.idGenerator = generator
+	}
+}
+
+func NewQueue(store repository.JobRepository, config Config, options ...QueueOption) *Queue {
+	ctx, cancel := context.WithCancel(context.Background())
+	queue := &Queue{
+		config:      config,
+		store:       store,
+		handlers:    make(map[models.JobType]Handler),
+		slots:       make(chan struct{}, config.QueueSize),
+		ready:       make(chan string, config.QueueSize),
+		ctx:         ctx,
+		cancel:      cancel,
+		stop:        make(chan struct{}),
+		timers:      make(map[string]*time.Timer),
+		now:         time.Now,
+		idGenerator: utils.NewULIDGenerator(),
+	}
+	for _, option := range options {
+		option(queue)
+	}
+	return queue
+}
+
+// Register sets the handler for a job type. It must be called before Start.
+func (q *Queue) Register(jobType models.JobType, handler Handler) {
+	q.handlers[jobType] = handler
+}
+
+// Start resumes jobs persisted by a previous run and starts the workers
+func (q *Queue) Start() error {
+	pending, err := q.store.ListJobs(models.JobStatusQueued, models.JobStatusRunning)
+	if err != nil {
+		return fmt.Errorf("load pending jobs: %w", err)
+	}
+
+	for i := 0; i < q.config.Workers; i++ {
+		q.workers.Add(1)
+		go q.work()
+	}
+
+	if len(pending) > 0 {
+		q.workers.Add(1)
+		go q.resume(pending)
+	}
+	return nil
+}
+
+// Enqueue persists a new job and schedules it for immediate execution
+func (q *Queue) Enqueue(jobType models.JobType, payload string) (*models.Job, error) {
+	now := q.now()
+	job := &models.Job{
+		ID:          q.idGenerator.NewID(),
+		Type:        jobType,
+		Payload:     payload,
+		Status:      models.JobStatusQueued,
+		MaxAttempts: q.config.MaxAttempts,
+		RunAt:       now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := q.admit(job, nil); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// Retry moves a dead-lettered job back onto the queue with a fresh set of attempts
+func (q *Queue) Retry(jobID string) (*models.Job, error) {
+	job, err := q.store.GetJob(jobID)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != models.JobStatusDead {
+		return nil, ErrJobNotDead
+	}
+
+	now := q.now()
+	job.Status = models.JobStatusQueued
+	job.Attempts = 0
+	job.RunAt = now
+	job.UpdatedAt = now
+	// The job is read again under the mutex: of two concurrent retries, only
+	// the first finds it still dead
+	stillDead := func() error {
+		current, err := q.store.GetJob(jobID)
+		if err != nil {
+			return err
+		}
+		if current.Status != models.JobStatusDead {
+			return ErrJobNotDead
+		}
+		return nil
+	}
+	if err := q.admit(job, stillDead); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// admit reserves a slot, persists job and hands it to the workers. A non-nil
+// precondition runs under the mutex first and rejects the job by returning an error.
+func (q *Queue) admit(job *models.Job, precondition func() error) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+	if precondition != nil {
+		if err := precondition(); err != nil {
+			return err
+		}
+	}
+	select {
+	case q.slots <- struct{}{}:
+	default:
+		return ErrQueueFull
+	}
+
+	if err := q.store.SaveJob(job); err != nil {
+		<-q.slots
+		return fmt.Errorf("persist job: %w", err)
+	}
+	q.ready <- job.ID
+	return nil
+}
+
+// Jobs lists persisted jobs in the given statuses, or all jobs when none are given
+func (q *Queue) Jobs(statuses ...models.JobStatus) ([]*models.Job, error) {
+	return q.store.ListJobs(statuses...)
+}
+
+func (q *Queue) Stats() Stats {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	stats := q.stats
+	stats.Queued = len(q.slots) - stats.InFlight
+	return stats
+}
+
+// Shutdown stops accepting jobs and waits for running handlers to return.
+// Jobs that have not run yet stay persisted and resume on the next Start. If
+// ctx expires first, running handlers are canceled and their jobs are
+// persisted as queued without consuming an attempt.
+func (q *Queue) Shutdown(ctx context.Context) error {
+	q.mutex.Lock()
+	if q.closed {
+		q.mutex.Unlock()
+		return nil
+	}
+	q.closed = true
+	close(q.stop)
+	for id, timer := range q.timers {
+		timer.Stop()
+		delete(q.timers, id)
+	}
+	q.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		q.cancel()
+		return nil
+	case <-ctx.Done():
+		q.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// resume re-admits jobs left over from a previous run, waiting for free slots
+// so that a large backlog does not overflow the queue
+func (q *Queue) resume(pending []*models.Job) {
+	defer q.workers.Done()
+
+	for _, job := range pending {
+		select {
+		case q.slots <- struct{}{}:
+		case <-q.stop:
+			return
+		}
+
+		if job.Status == models.JobStatusRunning {
+			// The process stopped while this job was running
+			job.Status = models.JobStatusQueued
+			job.UpdatedAt = q.now()
+			if err := q.store.SaveJob(job); err != nil {
+				log.Printf("jobs: failed to requeue job %s: %v", job.ID, err)
+			}
+		}
+		q.schedule(job.ID, job.RunAt.Sub(q.now()))
+	}
+}
+
+// schedule hands a job that already holds a slot to the workers after delay
+func (q *Queue) schedule(jobID string, delay time.Duration) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.closed {
+		return
+	}
+	if delay <= 0 {
+		q.ready <- jobID
+		return
+	}
+	q.timers[jobID] = time.AfterFunc(delay, func() {
+		q.mutex.Lock()
+		defer q.mutex.Unlock()
+		if _, pending := q.timers[jobID]; pending {
+			delete(q.timers, jobID)
+			q.ready <- jobID
+		}
+	})
+}
+
+func (q *Queue) work() {
+	defer q.workers.Done()
+
+	for {
+		select {
+		case <-q.stop:
+			return
+		case jobID := <-q.ready:
+			select {
+			case <-q.stop:
+				// Leave the job persisted for the next run
+				return
+			default:
+			}
+			q.run(jobID)
+		}
+	}
+}
+
+func (q *Queue) run(jobID string) {
+	job, err := q.store.GetJob(jobID)
+	if err != nil {
+		log.Printf("jobs: failed to load job %s: %v", jobID, err)
+		<-q.slots
+		return
+	}
+
+	handler, registered := q.handlers[job.Type]
+	if !registered {
+		q.finish(job, models.JobStatusDead, fmt.Sprintf("no handler registered for job type %q", job.Type))
+		return
+	}
+
+	job.Status = models.JobStatusRunning
+	job.Attempts++
+	job.UpdatedAt = q.now()
+	q.save(job)
+
+	q.mutex.Lock()
+	q.stats.InFlight++
+	q.mutex.Unlock()
+
+	err = handler(q.ctx, job)
+
+	q.mutex.Lock()
+	q.stats.InFlight--
+	q.mutex.Unlock()
+
+	switch {
+	case err == nil:
+		q.finish(job, models.JobStatusSucceeded, "")
+	case q.ctx.Err() != nil:
+		// Interrupted by shutdown: persist for the next run without using up an attempt
+		job.Attempts--
+		job.Status = models.JobStatusQueued
+		job.UpdatedAt = q.now()
+		q.save(job)
+		<-q.slots
+	case job.Attempts >= job.MaxAttempts:
+		q.finish(job, models.JobStatusDead, err.Error())
+	default:
+		delay := q.backoff(job.Attempts)
+		job.Status = models.JobStatusQueued
+		job.LastError = err.Error()
+		job.RunAt = q.now().Add(delay)
+		job.UpdatedAt = q.now()
+		q.save(job)
+
+		q.mutex.Lock()
+		q.stats.Retried++
+		q.mutex.Unlock()
+		q.schedule(job.ID, delay)
+	}
+}
+
+// finish records a final status and releases the job's slot
+func (q *Queue) finish(job *models.Job, status models.JobStatus, lastError string) {
+	job.Status = status
+	job.LastError = lastError
+	job.UpdatedAt = q.now()
+	q.save(job)
+
+	q.mutex.Lock()
+	switch status {
+	case models.JobStatusSucceeded:
+		q.stats.Succeeded++
+	case models.JobStatusDead:
+		q.stats.Dead++
+		log.Printf("jobs: job %s (%s) dead-lettered: %s", job.ID, job.Type, lastError)
+	}
+	q.mutex.Unlock()
+	<-q.slots
+}
+
+func (q *Queue) save(job *models.Job) {
+	if err := q.store.SaveJob(job); err != nil {
+		log.Printf("jobs: failed to persist job %s: %v", job.ID, err)
+	}
+}
+
+// backoff returns the delay before retrying after the given attempt
+func (q *Queue) backoff(attempt int) time.Duration {
+	delay := q.config.InitialBackoff
+	for i := 1; i < attempt && delay < q.config.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, q.config.MaxBackoff)
+}
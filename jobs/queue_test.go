@@ -0,0 +1,243 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/touchsung/spd-fiber-booking-system/models"
+	"github.com/touchsung/spd-fiber-booking-system/repository"
+)
+
+const testJobType models.JobType = "test"
+
+func testConfig() Config {
+	return Config{
+		Workers:        2,
+		QueueSize:      10,
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+	}
+}
+
+func waitForStatus(t *testing.T, store repository.JobRepository, jobID string, status models.JobStatus) *models.Job {
+	var job *models.Job
+	require.Eventually(t, func() bool {
+		var err error
+		job, err = store.GetJob(jobID)
+		return err == nil && job.Status == status
+	}, 2*time.Second, time.Millisecond, "Expected job to reach status %s", status)
+	return job
+}
+
+func TestQueueRunsJobs(t *testing.T) {
+	store := repository.NewMockJobRepository()
+	queue := NewQueue(store, testConfig())
+
+	var payload atomic.Value
+	queue.Register(testJobType, func(ctx context.Context, job *models.Job) error {
+		payload.Store(job.Payload)
+		return nil
+	})
+	require.NoError(t, queue.Start())
+	defer queue.Shutdown(context.Background())
+
+	job, err := queue.Enqueue(testJobType, "booking-1")
+	require.NoError(t, err)
+
+	done := waitForStatus(t, store, job.ID, models.JobStatusSucceeded)
+	assert.Equal(t, 1, done.Attempts)
+	assert.Equal(t, "booking-1", payload.Load())
+	assert.Equal(t, uint64(1), queue.Stats().Succeeded)
+}
+
+type sequenceIDGenerator struct {
+	next atomic.Int32
+}
+
+func (g *sequenceIDGenerator) NewID() string {
+	return fmt.Sprintf("job-%03d", g.next.Add(1))
+}
+
+func TestQueueUsesInjectedIDGenerator(t *testing.T) {
+	store := repository.NewMockJobRepository()
+	queue := NewQueue(store, testConfig(), WithIDGenerator(&sequenceIDGenerator{}))
+
+	first, err := queue.Enqueue(testJobType, "booking-1")
+	require.NoError(t, err)
+	second, err := queue.Enqueue(testJobType, "booking-2")
+	require.NoError(t, err)
+	assert.Equal(t, "job-001", first.ID)
+	assert.Equal(t, "job-002", second.ID)
+
+	stored, err := store.GetJob("job-002")
+	require.NoError(t, err)
+	assert.Equal(t, "booking-2", stored.Payload)
+}
+
+func TestQueueRetriesThenDeadLetters(t *testing.T) {
+	store := repository.NewMockJobRepository()
+	queue := NewQueue(store, testConfig())
+
+	var calls atomic.Int32
+	queue.Register(testJobType, func(ctx context.Context, job *models.Job) error {
+		calls.Add(1)
+		return errors.New("bureau unavailable")
+	})
+	require.NoError(t, queue.Start())
+	defer queue.Shutdown(context.Background())
+
+	job, err := queue.Enqueue(testJobType, "booking-1")
+	require.NoError(t, err)
+
+	dead := waitForStatus(t, store, job.ID, models.JobStatusDead)
+	assert.Equal(t, 3, dead.Attempts)
+	assert.Equal(t, "bureau unavailable", dead.LastError)
+	assert.Equal(t, int32(3), calls.Load())
+
+	stats := queue.Stats()
+	assert.Equal(t, uint64(2), stats.Retried)
+	assert.Equal(t, uint64(1), stats.Dead)
+	assert.Equal(t, 0, stats.Queued)
+
+	// Dead letters can be retried with a fresh set of attempts
+	_, err = queue.Retry(job.ID)
+	require.NoError(t, err)
+	waitForStatus(t, store, job.ID, models.JobStatusDead)
+	assert.Equal(t, int32(6), calls.Load())
+}
+
+// gatedJobStore holds the callers of the first limit reads until gate is
+// closed, so concurrent callers all read a job before any of them acts on it
+type gatedJobStore struct {
+	repository.JobRepository
+	held    atomic.Int32
+	limit   int32
+	waiting sync.WaitGroup
+	gate    chan struct{}
+}
+
+func (s *gatedJobStore) GetJob(jobID string) (*models.Job, error) {
+	job, err := s.JobRepository.GetJob(jobID)
+	if s.held.Add(1) <= s.limit {
+		s.waiting.Done()
+		<-s.gate
+	}
+	return job, err
+}
+
+func TestQueueRetriesDeadJobOnce(t *testing.T) {
+	const retries = 10
+	store := &gatedJobStore{JobRepository: repository.NewMockJobRepository(), limit: retries, gate: make(chan struct{})}
+	now := time.Now()
+	require.NoError(t, store.SaveJob(&models.Job{
+		ID: "job-1", Type: testJobType, Status: models.JobStatusDead,
+		Attempts: 3, MaxAttempts: 3, RunAt: now, CreatedAt: now, UpdatedAt: now,
+	}))
+	queue := NewQueue(store, testConfig())
+	// Not started, so the retried job stays queued
+
+	var retried, rejected atomic.Int32
+	var wg sync.WaitGroup
+	store.waiting.Add(retries)
+	for range retries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := queue.Retry("job-1")
+			if err == nil {
+				retried.Add(1)
+			} else if errors.Is(err, ErrJobNotDead) {
+				rejected.Add(1)
+			}
+		}()
+	}
+	// Every retry has seen the job dead before any is let through
+	store.waiting.Wait()
+	close(store.gate)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), retried.Load())
+	assert.Equal(t, int32(retries-1), rejected.Load())
+	assert.Equal(t, 1, queue.Stats().Queued, "Expected the job to take a single slot")
+	job, err := store.GetJob("job-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.JobStatusQueued, job.Status)
+	assert.Zero(t, job.Attempts)
+}
+
+func TestQueueRejectsWhenFull(t *testing.T) {
+	config := testConfig()
+	config.QueueSize = 2
+	queue := NewQueue(repository.NewMockJobRepository(), config)
+	// Not started, so nothing drains the queue
+
+	_, err := queue.Enqueue(testJobType, "1")
+	require.NoError(t, err)
+	_, err = queue.Enqueue(testJobType, "2")
+	require.NoError(t, err)
+	_, err = queue.Enqueue(testJobType, "3")
+	assert.ErrorIs(t, err, ErrQueueFull)
+	assert.Equal(t, 2, queue.Stats().Queued)
+}
+
+func TestQueueResumesPersistedJobs(t *testing.T) {
+	store := repository.NewMockJobRepository()
+
+	// A previous process queued one job and crashed while running another
+	first := NewQueue(store, testConfig())
+	queued, err := first.Enqueue(testJobType, "queued")
+	require.NoError(t, err)
+	running, err := first.Enqueue(testJobType, "running")
+	require.NoError(t, err)
+	running.Status = models.JobStatusRunning
+	running.Attempts = 1
+	require.NoError(t, store.SaveJob(running))
+
+	second := NewQueue(store, testConfig())
+	second.Register(testJobType, func(ctx context.Context, job *models.Job) error {
+		return nil
+	})
+	require.NoError(t, second.Start())
+	defer second.Shutdown(context.Background())
+
+	waitForStatus(t, store, queued.ID, models.JobStatusSucceeded)
+	resumed := waitForStatus(t, store, running.ID, models.JobStatusSucceeded)
+	assert.Equal(t, 2, resumed.Attempts)
+}
+
+func TestQueueShutdownPersistsInterruptedJobs(t *testing.T) {
+	store := repository.NewMockJobRepository()
+	queue := NewQueue(store, testConfig())
+
+	started := make(chan struct{})
+	queue.Register(testJobType, func(ctx context.Context, job *models.Job) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	require.NoError(t, queue.Start())
+
+	job, err := queue.Enqueue(testJobType, "booking-1")
+	require.NoError(t, err)
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, queue.Shutdown(ctx), context.DeadlineExceeded)
+
+	interrupted, err := store.GetJob(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobStatusQueued, interrupted.Status)
+	assert.Equal(t, 0, interrupted.Attempts, "Expected an interrupted attempt not to count")
+
+	_, err = queue.Enqueue(testJobType, "booking-2")
+	assert.ErrorIs(t, err, ErrQueueClosed)
+}
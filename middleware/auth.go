@@ -0,0 +1,216 @@
+package middleware
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/touchsung/spd-fiber-booking-system/authz"
+)
+
+// principalKey is the c.Locals key of the authenticated Principal
+const principalKey = "principal"
+
+// Principal is the caller identified by a bearer token
+type Principal = authz.Principal
+
+// PrincipalFrom returns the principal stored by Authenticate, or false when
+// the request was not authenticated
+func PrincipalFrom(c *fiber.Ctx) (Principal, bool) {
+	principal, ok := c.Locals(principalKey).(Principal)
+	return principal, ok
+}
+
+// AuthConfig selects how bearer tokens are verified. HS256 tokens are checked
+// against HMACSecret and RS256 tokens against the keys in JWKSFile; at least
+// one of them must be set.
+type AuthConfig struct {
+	HMACSecret string
+	JWKSFile   string        // JSON Web Key Set holding the RSA public keys
+	Issuer     string        // required iss claim when set
+	Audience   string        // required aud claim when set
+	Leeway     time.Duration // tolerated clock skew for exp and nbf
+}
+
+// Authenticator verifies bearer JWTs
+type Authenticator struct {
+	hmacSecret []byte
+	rsaKeys    map[string]*rsa.PublicKey // by kid
+	parser     *jwt.Parser
+}
+
+// NewAuthenticator loads the configured keys
+func NewAuthenticator(config AuthConfig) (*Authenticator, error) {
+	authenticator := &Authenticator{rsaKeys: make(map[string]*rsa.PublicKey)}
+	methods := make([]string, 0, 2)
+	if config.HMACSecret != "" {
+		authenticator.hmacSecret = []byte(config.HMACSecret)
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if config.JWKSFile != "" {
+		keys, err := loadJWKS(config.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		authenticator.rsaKeys = keys
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+	if len(methods) == 0 {
+		return nil, errors.New("an HMAC secret or a JWKS file is required")
+	}
+
+	// Only the configured algorithms are accepted, so an HS256 token can never
+	// be checked against an RSA public key
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(config.Leeway),
+	}
+	if config.Issuer != "" {
+		options = append(options, jwt.WithIssuer(config.Issuer))
+	}
+	if config.Audience != "" {
+		options = append(options, jwt.WithAudience(config.Audience))
+	}
+	authenticator.parser = jwt.NewParser(options...)
+	return authenticator, nil
+}
+
+// Authenticate rejects requests without a valid bearer token with 401 and
+// stores the caller's Principal for the handlers
+func (a *Authenticator) Authenticate() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		scheme, token, _ := strings.Cut(c.Get(fiber.HeaderAuthorization), " ")
+		if !strings.EqualFold(scheme, "Bearer") || token == "" {
+			c.Set(fiber.HeaderWWWAuthenticate, `Bearer`)
+			return fiber.NewError(fiber.StatusUnauthorized, "a bearer token is required")
+		}
+
+		principal, err := a.verify(token)
+		if err != nil {
+			c.Set(fiber.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+			return fiber.NewError(fiber.StatusUnauthorized, "invalid bearer token: "+err.Error())
+		}
+		c.Locals(principalKey, principal)
+		return c.Next()
+	}
+}
+
+// RequirePermission rejects authenticated callers without permission with 403.
+// Requests are not checked when authentication is disabled.
+func RequirePermission(policy *authz.Policy, permission authz.Permission) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if principal, ok := PrincipalFrom(c); ok {
+			if err := policy.Require(principal, permission); err != nil {
+				return err
+			}
+		}
+		return c.Next()
+	}
+}
+
+// tokenClaims are the claims read from a bearer token
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	Roles []string `json:"roles"`
+}
+
+func (a *Authenticator) verify(raw string) (Principal, error) {
+	var claims tokenClaims
+	if _, err := a.parser.ParseWithClaims(raw, &claims, a.key); err != nil {
+		return Principal{}, err
+	}
+	if claims.Subject == "" {
+		return Principal{}, errors.New("token has no subject")
+	}
+
+	principal := Principal{UserID: claims.Subject, Roles: make([]authz.Role, 0, len(claims.Roles))}
+	for _, role := range claims.Roles {
+		principal.Roles = append(principal.Roles, authz.Role(role))
+	}
+	// Tokens without roles belong to customers
+	if len(principal.Roles) == 0 {
+		principal.Roles = append(principal.Roles, authz.RoleCustomer)
+	}
+	return principal, nil
+}
+
+// key picks the verification key for the token's algorithm and key ID
+func (a *Authenticator) key(token *jwt.Token) (any, error) {
+	if token.Method.Alg() == jwt.SigningMethodHS256.Alg() {
+		return a.hmacSecret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	if key, ok := a.rsaKeys[kid]; ok {
+		return key, nil
+	}
+	// A set with a single key may be used by tokens without a kid
+	if kid == "" && len(a.rsaKeys) == 1 {
+		for _, key := range a.rsaKeys {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown key ID %q", kid)
+}
+
+// jsonWebKey holds the fields of an RSA JSON Web Key (RFC 7517)
+type jsonWebKey struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+	Alg     string `json:"alg"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+}
+
+// loadJWKS reads the RSA signing keys of a JSON Web Key Set; other keys are skipped
+func loadJWKS(path string) (map[string]*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading JWKS file: %w", err)
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("parsing JWKS file %s: %w", path, err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for i, jwk := range set.Keys {
+		if jwk.KeyType != "RSA" || (jwk.Use != "" && jwk.Use != "sig") || (jwk.Alg != "" && jwk.Alg != "RS256") {
+			continue
+		}
+		key, err := rsaPublicKey(jwk)
+		if err != nil {
+			return nil, fmt.Errorf("JWKS key %d: %w", i, err)
+		}
+		keys[jwk.KeyID] = key
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("JWKS file %s has no RS256 signing keys", path)
+	}
+	return keys, nil
+}
+
+func rsaPublicKey(jwk jsonWebKey) (*rsa.PublicKey, error) {
+	modulus, err := base64.RawURLEncoding.DecodeString(jwk.N)
+	if err != nil || len(modulus) == 0 {
+		return nil, errors.New("invalid modulus")
+	}
+	exponent, err := base64.RawURLEncoding.DecodeString(jwk.E)
+	if err != nil || len(exponent) == 0 || len(exponent) > 4 {
+		return nil, errors.New("invalid exponent")
+	}
+	e := new(big.Int).SetBytes(exponent)
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(modulus), E: int(e.Int64())}, nil
+}
@@ -0,0 +1,187 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/touchsung/spd-fiber-booking-system/authz"
+)
+
+const testSecret = "a-test-secret-of-at-least-32-bytes!"
+
+// writeJWKS stores key's public half as a JSON Web Key Set under kid
+func writeJWKS(t *testing.T, kid string, key *rsa.PrivateKey) string {
+	set := map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": kid,
+		"use": "sig",
+		"alg": "RS256",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}}
+	data, err := json.Marshal(set)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func signToken(t *testing.T, method jwt.SigningMethod, key any, kid string, claims jwt.RegisteredClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func validClaims(subject string) jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Subject:   subject,
+		Issuer:    "https://auth.example.com",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+}
+
+func setupAuthApp(t *testing.T, config AuthConfig) *fiber.App {
+	authenticator, err := NewAuthenticator(config)
+	require.NoError(t, err)
+
+	app := fiber.New()
+	app.Use(authenticator.Authenticate())
+	app.Get("/me", func(c *fiber.Ctx) error {
+		principal, ok := PrincipalFrom(c)
+		require.True(t, ok)
+		return c.SendString(principal.UserID)
+	})
+	return app
+}
+
+func getMe(t *testing.T, app *fiber.App, token string) (int, string) {
+	req := httptest.NewRequest("GET", "/me", nil)
+	if token != "" {
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+	}
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(body)
+}
+
+func TestAuthenticateVerifiesTokens(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	app := setupAuthApp(t, AuthConfig{
+		HMACSecret: testSecret,
+		JWKSFile:   writeJWKS(t, "key-1", rsaKey),
+		Issuer:     "https://auth.example.com",
+	})
+
+	code, body := getMe(t, app, signToken(t, jwt.SigningMethodHS256, []byte(testSecret), "", validClaims("user1")))
+	assert.Equal(t, fiber.StatusOK, code)
+	assert.Equal(t, "user1", body)
+
+	code, body = getMe(t, app, signToken(t, jwt.SigningMethodRS256, rsaKey, "key-1", validClaims("user2")))
+	assert.Equal(t, fiber.StatusOK, code)
+	assert.Equal(t, "user2", body)
+
+	// A single key also verifies tokens without a kid
+	code, _ = getMe(t, app, signToken(t, jwt.SigningMethodRS256, rsaKey, "", validClaims("user2")))
+	assert.Equal(t, fiber.StatusOK, code)
+
+	expired := validClaims("user1")
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+	noExpiry := validClaims("user1")
+	noExpiry.ExpiresAt = nil
+	wrongIssuer := validClaims("user1")
+	wrongIssuer.Issuer = "https://evil.example.com"
+
+	rejected := map[string]string{
+		"no token":       "",
+		"garbage":        "not-a-jwt",
+		"wrong secret":   signToken(t, jwt.SigningMethodHS256, []byte("another-secret-of-at-least-32-bytes"), "", validClaims("user1")),
+		"unknown key":    signToken(t, jwt.SigningMethodRS256, otherKey, "key-1", validClaims("user1")),
+		"unknown kid":    signToken(t, jwt.SigningMethodRS256, rsaKey, "key-2", validClaims("user1")),
+		"expired":        signToken(t, jwt.SigningMethodHS256, []byte(testSecret), "", expired),
+		"no expiry":      signToken(t, jwt.SigningMethodHS256, []byte(testSecret), "", noExpiry),
+		"wrong issuer":   signToken(t, jwt.SigningMethodHS256, []byte(testSecret), "", wrongIssuer),
+		"no subject":     signToken(t, jwt.SigningMethodHS256, []byte(testSecret), "", validClaims("")),
+		"unsigned":       signToken(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "", validClaims("user1")),
+		"HS384 not used": signToken(t, jwt.SigningMethodHS384, []byte(testSecret), "", validClaims("user1")),
+	}
+	for name, token := range rejected {
+		code, _ := getMe(t, app, token)
+		assert.Equal(t, fiber.StatusUnauthorized, code, name)
+	}
+}
+
+func TestAuthenticateOnlyAcceptsConfiguredAlgorithms(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	app := setupAuthApp(t, AuthConfig{JWKSFile: writeJWKS(t, "key-1", rsaKey)})
+
+	// Without a secret, HS256 tokens are refused even when signed with the
+	// public key, which a confused verifier might accept
+	publicKey := rsaKey.PublicKey.N.Bytes()
+	code, _ := getMe(t, app, signToken(t, jwt.SigningMethodHS256, publicKey, "key-1", validClaims("user1")))
+	assert.Equal(t, fiber.StatusUnauthorized, code)
+
+	_, err = NewAuthenticator(AuthConfig{})
+	assert.Error(t, err)
+	_, err = NewAuthenticator(AuthConfig{JWKSFile: filepath.Join(t.TempDir(), "missing.json")})
+	assert.Error(t, err)
+}
+
+func TestRequirePermissionChecksTokenRoles(t *testing.T) {
+	authenticator, err := NewAuthenticator(AuthConfig{HMACSecret: testSecret})
+	require.NoError(t, err)
+	// The handler package renders these errors in production
+	app := fiber.New(fiber.Config{ErrorHandler: func(c *fiber.Ctx, err error) error {
+		var forbidden *authz.ForbiddenError
+		if errors.As(err, &forbidden) {
+			return c.Status(fiber.StatusForbidden).SendString(string(forbidden.Permission))
+		}
+		return fiber.DefaultErrorHandler(c, err)
+	}})
+	app.Use(authenticator.Authenticate())
+	app.Get("/jobs", RequirePermission(authz.DefaultPolicy, authz.PermissionManageJobs), func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+
+	status := func(roles ...string) int {
+		claims := jwt.MapClaims{"sub": "user1", "exp": time.Now().Add(time.Hour).Unix()}
+		if roles != nil {
+			claims["roles"] = roles
+		}
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testSecret))
+		require.NoError(t, err)
+		req := httptest.NewRequest("GET", "/jobs", nil)
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, fiber.StatusOK, status("admin"))
+	assert.Equal(t, fiber.StatusOK, status("support", "admin"))
+	assert.Equal(t, fiber.StatusForbidden, status("support"))
+	assert.Equal(t, fiber.StatusForbidden, status(), "Expected tokens without roles to be customers")
+}
@@ -0,0 +1,209 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// IdempotencyKeyHeader carries the client-chosen key for a retryable request
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set to "true" on responses replayed from the store
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+)
+
+// replayedHeaders are the response headers stored with a response and replayed
+// with it, besides Content-Type
+var replayedHeaders = []string{fiber.HeaderETag, fiber.HeaderLocation}
+
+var (
+	// ErrIdempotencyKeyReused is returned when a key is sent again with a different request
+	ErrIdempotencyKeyReused = errors.New("idempotency key was already used for a different request")
+	// ErrIdempotencyInProgress is returned while the first request with a key is still running
+	ErrIdempotencyInProgress = errors.New("a request with this idempotency key is still being processed")
+)
+
+// StoredResponse is the response replayed for retries of a completed request
+type StoredResponse struct {
+	Status      int
+	ContentType string
+	Headers     map[string]string // the replayedHeaders the response had
+	Body        []byte
+}
+
+// IdempotencyStore remembers the outcome of requests by idempotency key
+type IdempotencyStore interface {
+	// Begin claims key for a request with the given fingerprint. It returns the
+	// stored response when the request already completed, nil when the caller
+	// should process it, or an error when the key cannot be used.
+	Begin(key string, fingerprint [32]byte) (*StoredResponse, error)
+	// Complete stores the response for a key claimed by Begin
+	Complete(key string, response StoredResponse)
+	// Abandon releases a key claimed by Begin so the request can be retried
+	Abandon(key string)
+}
+
+// Ensure MemoryIdempotencyStore satisfies IdempotencyStore
+var _ IdempotencyStore = (*MemoryIdempotencyStore)(nil)
+
+type idempotencyEntry struct {
+	fingerprint [32]byte
+	response    *StoredResponse // nil while the first request is running
+	expiresAt   time.Time
+}
+
+// MemoryIdempotencyStore keeps keys in memory for a fixed retention period
+type MemoryIdempotencyStore struct {
+	mutex     sync.Mutex
+	entries   map[string]*idempotencyEntry
+	retention time.Duration
+	lastPurge time.Time
+	now       func() time.Time
+}
+
+// NewMemoryIdempotencyStore forgets keys retention after they were first used
+func NewMemoryIdempotencyStore(retention time.Duration) *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		entries:   make(map[string]*idempotencyEntry),
+		retention: retention,
+		now:       time.Now,
+	}
+}
+
+func (s *MemoryIdempotencyStore) Begin(key string, fingerprint [32]byte) (*StoredResponse, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := s.now()
+	s.purgeExpired(now)
+
+	entry, exists := s.entries[key]
+	if exists && now.Before(entry.expiresAt) {
+		switch {
+		case entry.fingerprint != fingerprint:
+			return nil, ErrIdempotencyKeyReused
+		case entry.response == nil:
+			return nil, ErrIdempotencyInProgress
+		default:
+			return entry.response, nil
+		}
+	}
+
+	s.entries[key] = &idempotencyEntry{fingerprint: fingerprint, expiresAt: now.Add(s.retention)}
+	return nil, nil
+}
+
+func (s *MemoryIdempotencyStore) Complete(key string, response StoredResponse) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if entry, exists := s.entries[key]; exists {
+		entry.response = &response
+	}
+}
+
+func (s *MemoryIdempotencyStore) Abandon(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if entry, exists := s.entries[key]; exists && entry.response == nil {
+		delete(s.entries, key)
+	}
+}
+
+// purgeExpired drops expired keys at most once a minute; callers hold the mutex
+func (s *MemoryIdempotencyStore) purgeExpired(now time.Time) {
+	if now.Sub(s.lastPurge) < time.Minute {
+		return
+	}
+	s.lastPurge = now
+	for key, entry := range s.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+// Idempotency makes a route safe to retry. Requests carrying an
+// Idempotency-Key header are processed once per user and key; retries with the
+// same payload replay the stored response and a different payload gets 409.
+// Failed requests are not stored, so they can be retried with the same key.
+func Idempotency(store IdempotencyStore) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get(IdempotencyKeyHeader)
+		if key == "" {
+			return c.Next()
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			return fiber.NewError(fiber.StatusBadRequest, "Idempotency-Key must be at most 255 characters")
+		}
+
+		scopedKey := idempotencyUser(c) + "\x00" + key
+		fingerprint := sha256.Sum256([]byte(c.Method() + " " + c.Path() + "\n" + string(c.Body())))
+
+		stored, err := store.Begin(scopedKey, fingerprint)
+		if err != nil {
+			return fiber.NewError(fiber.StatusConflict, err.Error())
+		}
+		if stored != nil {
+			c.Set(IdempotentReplayedHeader, "true")
+			c.Set(fiber.HeaderContentType, stored.ContentType)
+			for name, value := range stored.Headers {
+				c.Set(name, value)
+			}
+			return c.Status(stored.Status).Send(stored.Body)
+		}
+
+		completed := false
+		defer func() {
+			if !completed {
+				store.Abandon(scopedKey)
+			}
+		}()
+
+		if err := c.Next(); err != nil {
+			return err
+		}
+		status := c.Response().StatusCode()
+		if status >= fiber.StatusInternalServerError {
+			return nil
+		}
+
+		headers := make(map[string]string, len(replayedHeaders))
+		for _, name := range replayedHeaders {
+			if value := c.GetRespHeader(name); value != "" {
+				headers[name] = strings.Clone(value) // value aliases the response buffer
+			}
+		}
+		store.Complete(scopedKey, StoredResponse{
+			Status:      status,
+			ContentType: string(c.Response().Header.ContentType()),
+			Headers:     headers,
+			Body:        append([]byte(nil), c.Response().Body()...),
+		})
+		completed = true
+		return nil
+	}
+}
+
+// idempotencyUser scopes keys to the authenticated user, or without
+// authentication to the user_id of the JSON request body, so two users cannot
+// collide on the same key
+func idempotencyUser(c *fiber.Ctx) string {
+	if principal, ok := PrincipalFrom(c); ok {
+		return principal.UserID
+	}
+	var body struct {
+		UserID string `json:"user_id"`
+	}
+	_ = json.Unmarshal(c.Body(), &body)
+	return body.UserID
+}
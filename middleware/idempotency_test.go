@@ -0,0 +1,157 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupIdempotentApp(store IdempotencyStore, status *int) (*fiber.App, *int) {
+	calls := 0
+	app := fiber.New()
+	app.Post("/bookings", Idempotency(store), func(c *fiber.Ctx) error {
+		calls++
+		if *status >= 400 {
+			return fiber.NewError(*status, "failed")
+		}
+		c.Set(fiber.HeaderETag, fmt.Sprintf(`"%d"`, calls))
+		c.Location(fmt.Sprintf("/bookings/%d", calls))
+		return c.Status(*status).JSON(fiber.Map{"call": calls})
+	})
+	return app, &calls
+}
+
+func postBooking(t *testing.T, app *fiber.App, key, body string) (int, string, string) {
+	req := httptest.NewRequest("POST", "/bookings", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(data), resp.Header.Get(IdempotentReplayedHeader)
+}
+
+func TestIdempotencyReplaysCompletedRequests(t *testing.T) {
+	status := fiber.StatusCreated
+	app, calls := setupIdempotentApp(NewMemoryIdempotencyStore(time.Hour), &status)
+	body := `{"user_id":"user1","price":100}`
+
+	code, first, replayed := postBooking(t, app, "key-1", body)
+	assert.Equal(t, fiber.StatusCreated, code)
+	assert.Empty(t, replayed)
+
+	code, second, replayed := postBooking(t, app, "key-1", body)
+	assert.Equal(t, fiber.StatusCreated, code)
+	assert.Equal(t, first, second)
+	assert.Equal(t, "true", replayed)
+	assert.Equal(t, 1, *calls, "Expected the retry not to reach the handler")
+
+	// A different payload under the same key is rejected
+	code, _, _ = postBooking(t, app, "key-1", `{"user_id":"user1","price":200}`)
+	assert.Equal(t, fiber.StatusConflict, code)
+
+	// Keys are scoped per user, and requests without a key are never deduplicated
+	postBooking(t, app, "key-1", `{"user_id":"user2","price":100}`)
+	postBooking(t, app, "", body)
+	postBooking(t, app, "", body)
+	assert.Equal(t, 4, *calls)
+}
+
+func TestIdempotencyScopesKeysToThePrincipal(t *testing.T) {
+	calls := 0
+	app := fiber.New()
+	app.Post("/bookings", func(c *fiber.Ctx) error {
+		c.Locals(principalKey, Principal{UserID: c.Get("X-Test-User")})
+		return c.Next()
+	}, Idempotency(NewMemoryIdempotencyStore(time.Hour)), func(c *fiber.Ctx) error {
+		calls++
+		return c.SendStatus(fiber.StatusCreated)
+	})
+	post := func(user, body string) *http.Response {
+		req := httptest.NewRequest("POST", "/bookings", strings.NewReader(body))
+		req.Header.Set(IdempotencyKeyHeader, "key-1")
+		req.Header.Set("X-Test-User", user)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	post("user1", `{"service_id":"service1"}`)
+	// Another user cannot replay or block user1's key by naming them in the body
+	resp := post("user2", `{"user_id":"user1","service_id":"service1"}`)
+	assert.Empty(t, resp.Header.Get(IdempotentReplayedHeader))
+	resp = post("user1", `{"service_id":"service1"}`)
+	assert.Equal(t, "true", resp.Header.Get(IdempotentReplayedHeader))
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotencyDoesNotStoreFailures(t *testing.T) {
+	status := fiber.StatusServiceUnavailable
+	app, calls := setupIdempotentApp(NewMemoryIdempotencyStore(time.Hour), &status)
+	body := `{"user_id":"user1"}`
+
+	code, _, _ := postBooking(t, app, "key-1", body)
+	assert.Equal(t, fiber.StatusServiceUnavailable, code)
+
+	status = fiber.StatusCreated
+	code, _, replayed := postBooking(t, app, "key-1", body)
+	assert.Equal(t, fiber.StatusCreated, code)
+	assert.Empty(t, replayed)
+	assert.Equal(t, 2, *calls)
+}
+
+func TestMemoryIdempotencyStoreRetention(t *testing.T) {
+	store := NewMemoryIdempotencyStore(time.Hour)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	fingerprint := [32]byte{1}
+
+	stored, err := store.Begin("key", fingerprint)
+	require.NoError(t, err)
+	assert.Nil(t, stored)
+
+	_, err = store.Begin("key", fingerprint)
+	assert.ErrorIs(t, err, ErrIdempotencyInProgress)
+
+	store.Complete("key", StoredResponse{Status: 201, Body: []byte("{}")})
+	stored, err = store.Begin("key", fingerprint)
+	require.NoError(t, err)
+	assert.Equal(t, 201, stored.Status)
+
+	// Once retention has passed the key can be reused for anything
+	now = now.Add(time.Hour)
+	stored, err = store.Begin("key", [32]byte{2})
+	require.NoError(t, err)
+	assert.Nil(t, stored)
+}
+
+func TestIdempotencyReplaysResponseHeaders(t *testing.T) {
+	status := fiber.StatusCreated
+	app, _ := setupIdempotentApp(NewMemoryIdempotencyStore(time.Hour), &status)
+
+	send := func() *http.Response {
+		req := httptest.NewRequest("POST", "/bookings", strings.NewReader(`{"user_id":"user1"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(IdempotencyKeyHeader, "key-1")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
+	}
+	first := send()
+	replayed := send()
+	assert.Equal(t, "true", replayed.Header.Get(IdempotentReplayedHeader))
+	assert.Equal(t, `"1"`, replayed.Header.Get(fiber.HeaderETag))
+	assert.Equal(t, "/bookings/1", replayed.Header.Get(fiber.HeaderLocation))
+	assert.Equal(t, first.Header.Get(fiber.HeaderETag), replayed.Header.Get(fiber.HeaderETag))
+}
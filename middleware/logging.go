@@ -42,6 +42,12 @@ func RequestLogger() fiber.Handler {
 		}
 
 		err := c.Next()
+		if err != nil {
+			// Render the error now so the logged status code matches the response
+			if handlerErr := c.App().ErrorHandler(c, err); handlerErr != nil {
+				_ = c.SendStatus(fiber.StatusInternalServerError)
+			}
+		}
 
 		// Create log entry
 		logEntry := RequestLog{
@@ -65,6 +71,7 @@ func RequestLogger() fiber.Handler {
 		logJSON, _ := json.Marshal(logEntry)
 		fmt.Println(string(logJSON))
 
-		return err
+		// The error has already been rendered above
+		return nil
 	}
 }
@@ -1,6 +1,9 @@
 package models
 
 import (
+	"fmt"
+	"slices"
+	"strings"
 	"time"
 )
 
@@ -9,14 +12,74 @@ type CreditCheckResult struct {
 	Status    BookingStatus
 }
 
-type SortOption string
+// SortField names a booking field that listings can be ordered by
+type SortField string
 
-// @Description Sort option enum
+// @Description Sortable booking fields
 const (
-	SortByPrice SortOption = "price"
-	SortByDate  SortOption = "date"
+	SortByID        SortField = "id"
+	SortByPrice     SortField = "price"
+	SortByDate      SortField = "created_at"
+	SortByStatus    SortField = "status"
+	SortByUserID    SortField = "user_id"
+	SortByServiceID SortField = "service_id"
 )
 
+// sortFieldAliases maps accepted spellings to sort fields; "date" predates created_at
+var sortFieldAliases = map[string]SortField{
+	"id":         SortByID,
+	"price":      SortByPrice,
+	"date":       SortByDate,
+	"created_at": SortByDate,
+	"status":     SortByStatus,
+	"user_id":    SortByUserID,
+	"service_id": SortByServiceID,
+}
+
+// Valid reports whether f is a known sort field
+func (f SortField) Valid() bool {
+	switch f {
+	case SortByID, SortByPrice, SortByDate, SortByStatus, SortByUserID, SortByServiceID:
+		return true
+	}
+	return false
+}
+
+// SortKey is one component of a multi-key ordering
+type SortKey struct {
+	Field SortField
+	Desc  bool
+}
+
+// ParseSort parses a comma-separated list of sort fields such as
+// "-price,created_at", where a leading "-" sorts that field descending
+func ParseSort(raw string) ([]SortKey, error) {
+	keys := make([]SortKey, 0)
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key := SortKey{}
+		if strings.HasPrefix(part, "-") {
+			key.Desc = true
+			part = part[1:]
+		}
+		field, ok := sortFieldAliases[part]
+		if !ok {
+			return nil, fmt.Errorf("unknown sort field %q", part)
+		}
+		key.Field = field
+		for _, existing := range keys {
+			if existing.Field == field {
+				return nil, fmt.Errorf("sort field %q given more than once", part)
+			}
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
 // BookingStatus represents the status of a booking
 // @Description Booking status enum
 type BookingStatus string
@@ -28,21 +91,48 @@ const (
 	StatusCanceled  BookingStatus = "canceled"  // Booking is canceled
 )
 
+// Valid reports whether s is a known booking status
+func (s BookingStatus) Valid() bool {
+	switch s {
+	case StatusPending, StatusConfirmed, StatusRejected, StatusCanceled:
+		return true
+	}
+	return false
+}
+
 // Booking represents a booking record
 // @Description Booking information
 type Booking struct {
-	ID        string        `json:"id" example:"20240319123456"`
+	ID        string        `json:"id" example:"01HS8ZQX3N8F6D9K2M4P7R1T5V"`
 	UserID    string        `json:"user_id" example:"user123"`
 	ServiceID string        `json:"service_id" example:"service456"`
-	Price     float64       `json:"price" example:"60000"`
+	Quantity  int           `json:"quantity" example:"5"`
+	Price     Money         `json:"price"` // total computed by the server
 	Status    BookingStatus `json:"status" example:"pending"`
 	CreatedAt time.Time     `json:"created_at"`
+	Version   int64         `json:"version" example:"1"` // incremented on every change
+	// PriceBreakdown explains Price; it is missing on bookings priced before the catalog existed
+	PriceBreakdown *PriceBreakdown `json:"price_breakdown,omitempty"`
+}
+
+// Clone returns a deep copy of the booking, so stores can hand out and keep
+// bookings without sharing the price breakdown with callers
+func (b *Booking) Clone() *Booking {
+	clone := *b
+	if b.PriceBreakdown != nil {
+		breakdown := *b.PriceBreakdown
+		breakdown.Adjustments = slices.Clone(b.PriceBreakdown.Adjustments)
+		clone.PriceBreakdown = &breakdown
+	}
+	return &clone
 }
 
 // BookingRequest represents the incoming booking request
-// @Description Booking creation request
+// @Description Booking creation request. The price is computed by the server; a price sent by the client is only compared with it.
 type BookingRequest struct {
-	UserID    string  `json:"user_id" example:"user123" validate:"required"`
-	ServiceID string  `json:"service_id" example:"service456" validate:"required"`
-	Price     float64 `json:"price" example:"60000" validate:"required,gt=0"`
+	UserID    string `json:"user_id,omitempty" example:"user123" validate:"required,max=64"` // taken from the bearer token when authentication is on
+	ServiceID string `json:"service_id" example:"service456" validate:"required,max=64"`
+	Quantity  int    `json:"quantity,omitempty" example:"5" validate:"omitempty,min=1,max=1000"` // defaults to 1
+	// Price is the total the client expects to pay; the booking is rejected when it differs from the computed price
+	Price *Money `json:"price,omitempty" validate:"omitempty,gt=0,lte=100000000"`
 }
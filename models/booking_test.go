@@ -0,0 +1,46 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSort(t *testing.T) {
+	keys, err := ParseSort("-price, created_at,status")
+	assert.NoError(t, err)
+	assert.Equal(t, []SortKey{
+		{Field: SortByPrice, Desc: true},
+		{Field: SortByDate},
+		{Field: SortByStatus},
+	}, keys)
+
+	// "date" is kept as an alias for created_at
+	keys, err = ParseSort("-date")
+	assert.NoError(t, err)
+	assert.Equal(t, []SortKey{{Field: SortByDate, Desc: true}}, keys)
+
+	keys, err = ParseSort("")
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+
+	for _, raw := range []string{"nickname", "price,-price", "--price", "-"} {
+		_, err := ParseSort(raw)
+		assert.Error(t, err, raw)
+	}
+}
+
+func TestBookingCloneCopiesPriceBreakdown(t *testing.T) {
+	booking := &Booking{ID: "1", PriceBreakdown: &PriceBreakdown{
+		Quantity:    5,
+		Adjustments: []PriceAdjustment{{Rule: "volume_discount"}},
+	}}
+
+	clone := booking.Clone()
+	clone.PriceBreakdown.Quantity = 1
+	clone.PriceBreakdown.Adjustments[0].Rule = "coupon"
+	assert.Equal(t, 5, booking.PriceBreakdown.Quantity)
+	assert.Equal(t, "volume_discount", booking.PriceBreakdown.Adjustments[0].Rule)
+
+	assert.Nil(t, (&Booking{ID: "2"}).Clone().PriceBreakdown)
+}
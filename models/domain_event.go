@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// DomainEventType names a booking outcome other services can react to
+type DomainEventType string
+
+const (
+	DomainEventBookingConfirmed DomainEventType = "booking.confirmed"
+	DomainEventBookingRejected  DomainEventType = "booking.rejected"
+	DomainEventBookingCanceled  DomainEventType = "booking.canceled"
+)
+
+// DomainEventTypeFor returns the event announcing a change to status, if any
+func DomainEventTypeFor(status BookingStatus) (DomainEventType, bool) {
+	switch status {
+	case StatusConfirmed:
+		return DomainEventBookingConfirmed, true
+	case StatusRejected:
+		return DomainEventBookingRejected, true
+	case StatusCanceled:
+		return DomainEventBookingCanceled, true
+	}
+	return "", false
+}
+
+// DomainEvent announces a booking status change. Delivery is at least once,
+// so consumers should deduplicate on ID.
+type DomainEvent struct {
+	ID         string          `json:"id" example:"01HS8ZQX3N8K2M4P6R8T0V2X4Z"`
+	Type       DomainEventType `json:"type" example:"booking.confirmed"`
+	BookingID  string          `json:"booking_id" example:"01HS8ZQX3N8F6D9K2M4P7R1T5V"`
+	Actor      Actor           `json:"actor" example:"credit_check"`
+	Reason     string          `json:"reason,omitempty" example:"credit check approved"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Booking    Booking         `json:"booking"` // state after the change
+}
+
+// OutboxMessage is a domain event stored next to the change that raised it,
+// waiting to be published
+type OutboxMessage struct {
+	Event         DomainEvent
+	Attempts      int
+	LastError     string
+	NextAttemptAt time.Time
+}
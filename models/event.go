@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// BookingEventType names a fact recorded about a booking
+type BookingEventType string
+
+const (
+	EventCreated           BookingEventType = "created"
+	EventCreditCheckPassed BookingEventType = "credit_check_passed"
+	EventCreditCheckFailed BookingEventType = "credit_check_failed"
+	EventCanceled          BookingEventType = "canceled"
+	EventExpired           BookingEventType = "expired"
+)
+
+// BookingEvent is one entry of a booking's append-only event stream
+type BookingEvent struct {
+	BookingID  string           `json:"booking_id"`
+	Version    int64            `json:"version"` // position in the stream, starting at 1
+	Type       BookingEventType `json:"type"`
+	Actor      Actor            `json:"actor,omitempty"`
+	Reason     string           `json:"reason,omitempty"`
+	OccurredAt time.Time        `json:"occurred_at"`
+	Booking    *Booking         `json:"booking,omitempty"` // initial state, only set on EventCreated
+}
+
+// Status returns the booking status an event leads to
+func (e BookingEvent) Status() BookingStatus {
+	switch e.Type {
+	case EventCreditCheckPassed:
+		return StatusConfirmed
+	case EventCreditCheckFailed:
+		return StatusRejected
+	case EventCanceled, EventExpired:
+		return StatusCanceled
+	case EventCreated:
+		if e.Booking != nil {
+			return e.Booking.Status
+		}
+	}
+	return StatusPending
+}
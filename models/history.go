@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Actor identifies who or what changed a booking's status
+type Actor string
+
+const (
+	ActorUser          Actor = "user"           // the booking's owner, e.g. through DELETE /bookings/:id
+	ActorStaff         Actor = "staff"          // support or admin staff acting on another user's booking
+	ActorCreditCheck   Actor = "credit_check"   // the result of a credit check
+	ActorExpirySweeper Actor = "expiry_sweeper" // the background job canceling stale pending bookings
+)
+
+// StatusChange is one entry of a booking's status history
+// @Description A single status transition of a booking
+type StatusChange struct {
+	BookingID string        `json:"booking_id" example:"01HS8ZQX3N8F6D9K2M4P7R1T5V"`
+	From      BookingStatus `json:"from" example:"pending"`
+	To        BookingStatus `json:"to" example:"canceled"`
+	Actor     Actor         `json:"actor" example:"expiry_sweeper"`
+	Reason    string        `json:"reason" example:"not confirmed within 5m0s"`
+	Version   int64         `json:"version" example:"2"` // booking version after the change
+	ChangedAt time.Time     `json:"changed_at"`
+}
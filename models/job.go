@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// JobStatus represents where a background job is in its lifecycle
+// @Description Job status enum
+type JobStatus string
+
+const (
+	JobStatusQueued    JobStatus = "queued"    // Waiting for a worker, possibly after a failed attempt
+	JobStatusRunning   JobStatus = "running"   // Picked up by a worker
+	JobStatusSucceeded JobStatus = "succeeded" // Finished successfully
+	JobStatusDead      JobStatus = "dead"      // Gave up after exhausting all attempts
+)
+
+// JobType identifies the handler that processes a job
+type JobType string
+
+const (
+	JobTypeCreditCheck     JobType = "credit_check"
+	JobTypeWebhookDelivery JobType = "webhook_delivery"
+)
+
+// Job is a persisted unit of background work
+// @Description Background job information
+type Job struct {
+	ID          string    `json:"id" example:"01HS8ZQX3N8F6D9K2M4P7R1T5V"`
+	Type        JobType   `json:"type" example:"credit_check"`
+	Payload     string    `json:"payload" example:"01HS8ZQX3N8F6D9K2M4P7R1T5V"`
+	Status      JobStatus `json:"status" example:"queued"`
+	Attempts    int       `json:"attempts" example:"1"`
+	MaxAttempts int       `json:"max_attempts" example:"5"`
+	LastError   string    `json:"last_error,omitempty"`
+	RunAt       time.Time `json:"run_at"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -0,0 +1,233 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is the currency of amounts recorded before prices carried
+// one, and of the service unless configured otherwise
+const DefaultCurrency = "USD"
+
+// ErrCurrencyMismatch is returned when combining or comparing amounts in different currencies
+var ErrCurrencyMismatch = errors.New("currency mismatch")
+
+// minorUnitDigits lists the ISO 4217 currencies whose minor unit is not a hundredth
+var minorUnitDigits = map[string]int{
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+}
+
+// MinorUnitDigits returns the number of decimal places of currency, e.g. 2 for USD and 0 for JPY
+func MinorUnitDigits(currency string) int {
+	if digits, ok := minorUnitDigits[currency]; ok {
+		return digits
+	}
+	return 2
+}
+
+// ValidCurrency reports whether code has the shape of an ISO 4217 code: three uppercase letters
+func ValidCurrency(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// Money is an exact amount of a currency, kept in its minor unit (cents for
+// USD). In JSON it is {"amount": "600.00", "currency": "USD"}.
+// @Description Amount of money; amount is a decimal string in the currency's major unit
+type Money struct {
+	Amount   int64  `json:"amount" swaggertype:"string" example:"600.00"` // minor units
+	Currency string `json:"currency" example:"USD"`                       // ISO 4217 code
+}
+
+// NewMoney returns amount minor units of currency
+func NewMoney(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// ParseMoney parses a decimal amount such as "600.5" exactly. It fails when
+// the amount has more decimal places than the currency.
+func ParseMoney(decimal, currency string) (Money, error) {
+	if !ValidCurrency(currency) {
+		return Money{}, fmt.Errorf("invalid currency %q", currency)
+	}
+	digits := MinorUnitDigits(currency)
+
+	raw := strings.TrimSpace(decimal)
+	sign := ""
+	if strings.HasPrefix(raw, "-") || strings.HasPrefix(raw, "+") {
+		sign, raw = raw[:1], raw[1:]
+	}
+	whole, fraction, hasPoint := strings.Cut(raw, ".")
+	if whole == "" && fraction == "" || !isDigits(whole) || !isDigits(fraction) || hasPoint && fraction == "" {
+		return Money{}, fmt.Errorf("invalid amount %q", decimal)
+	}
+	if len(fraction) > digits {
+		return Money{}, fmt.Errorf("amount %q has more than %d decimal places for %s", decimal, digits, currency)
+	}
+
+	amount, err := strconv.ParseInt(sign+whole+fraction+strings.Repeat("0", digits-len(fraction)), 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("amount %q is out of range", decimal)
+	}
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// FromMajorUnits converts a float amount such as a configured threshold,
+// rounding to the currency's minor unit. Use ParseMoney for anything exact.
+func FromMajorUnits(amount float64, currency string) Money {
+	scale := math.Pow10(MinorUnitDigits(currency))
+	return Money{Amount: int64(math.Round(amount * scale)), Currency: currency}
+}
+
+// Add returns m + other
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, fmt.Errorf("adding %s to %s: %w", other.Currency, m.Currency, ErrCurrencyMismatch)
+	}
+	return Money{Amount: m.Amount + other.Amount, Currency: m.Currency}, nil
+}
+
+// Sub returns m - other
+func (m Money) Sub(other Money) (Money, error) {
+	return m.Add(other.Neg())
+}
+
+// Mul returns m times n
+func (m Money) Mul(n int64) Money {
+	return Money{Amount: m.Amount * n, Currency: m.Currency}
+}
+
+// Percent returns percent of m, rounded half away from zero to the minor unit
+func (m Money) Percent(percent float64) Money {
+	return Money{Amount: int64(math.Round(float64(m.Amount) * percent / 100)), Currency: m.Currency}
+}
+
+// Neg returns -m
+func (m Money) Neg() Money {
+	return Money{Amount: -m.Amount, Currency: m.Currency}
+}
+
+// Cmp returns -1, 0 or +1 as m is less than, equal to or greater than other
+func (m Money) Cmp(other Money) (int, error) {
+	if m.Currency != other.Currency {
+		return 0, fmt.Errorf("comparing %s with %s: %w", m.Currency, other.Currency, ErrCurrencyMismatch)
+	}
+	switch {
+	case m.Amount < other.Amount:
+		return -1, nil
+	case m.Amount > other.Amount:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// Decimal formats the amount in major units with the currency's decimal places, e.g. "600.50"
+func (m Money) Decimal() string {
+	digits := MinorUnitDigits(m.Currency)
+	text := strconv.FormatInt(m.Amount, 10)
+	if digits == 0 {
+		return text
+	}
+
+	sign := ""
+	if m.Amount < 0 {
+		sign, text = "-", text[1:]
+	}
+	if len(text) <= digits {
+		text = strings.Repeat("0", digits-len(text)+1) + text
+	}
+	point := len(text) - digits
+	return sign + text[:point] + "." + text[point:]
+}
+
+// String formats m as an amount followed by its currency, e.g. "600.50 USD"
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+// moneyJSON is the wire format of Money
+type moneyJSON struct {
+	Amount   json.RawMessage `json:"amount"`
+	Currency string          `json:"currency"`
+}
+
+// MarshalJSON encodes the zero Money, which has no currency, as null
+func (m Money) MarshalJSON() ([]byte, error) {
+	if m.Currency == "" && m.Amount == 0 {
+		return []byte("null"), nil
+	}
+	amount, err := json.Marshal(m.Decimal())
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(moneyJSON{Amount: amount, Currency: m.Currency})
+}
+
+// UnmarshalJSON reads {"amount": ..., "currency": ...} with the amount as a
+// decimal string or number. A bare number is an amount stored before prices
+// carried a currency and is read in DefaultCurrency.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	if len(data) == 0 || data[0] != '{' {
+		var legacy float64
+		if err := json.Unmarshal(data, &legacy); err != nil {
+			return fmt.Errorf("money must be an object with amount and currency: %w", err)
+		}
+		*m = FromMajorUnits(legacy, DefaultCurrency)
+		return nil
+	}
+
+	var wire moneyJSON
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return err
+	}
+	if len(wire.Amount) == 0 {
+		return errors.New("money amount is required")
+	}
+	amount := string(wire.Amount)
+	if wire.Amount[0] == '"' {
+		if err := json.Unmarshal(wire.Amount, &amount); err != nil {
+			return err
+		}
+	}
+	parsed, err := ParseMoney(amount, wire.Currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
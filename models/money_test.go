@@ -0,0 +1,113 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		decimal  string
+		currency string
+		want     int64
+	}{
+		{"600", "USD", 60000},
+		{"600.5", "USD", 60050},
+		{"0.07", "USD", 7},
+		{"-12.34", "EUR", -1234},
+		{".5", "USD", 50},
+		{"1500", "JPY", 1500},
+		{"1.234", "KWD", 1234},
+	}
+	for _, tt := range tests {
+		money, err := ParseMoney(tt.decimal, tt.currency)
+		require.NoError(t, err, tt.decimal)
+		assert.Equal(t, NewMoney(tt.want, tt.currency), money, tt.decimal)
+	}
+
+	for _, bad := range []struct{ decimal, currency string }{
+		{"1.005", "USD"},
+		{"1.5", "JPY"},
+		{"abc", "USD"},
+		{"1.", "USD"},
+		{"", "USD"},
+		{"1e3", "USD"},
+		{"10", "usd"},
+		{"99999999999999999999", "USD"},
+	} {
+		_, err := ParseMoney(bad.decimal, bad.currency)
+		assert.Error(t, err, bad.decimal+" "+bad.currency)
+	}
+}
+
+func TestMoneyFormatting(t *testing.T) {
+	assert.Equal(t, "600.50", NewMoney(60050, "USD").Decimal())
+	assert.Equal(t, "0.07", NewMoney(7, "USD").Decimal())
+	assert.Equal(t, "-0.07", NewMoney(-7, "USD").Decimal())
+	assert.Equal(t, "1500", NewMoney(1500, "JPY").Decimal())
+	assert.Equal(t, "1.234", NewMoney(1234, "KWD").Decimal())
+	assert.Equal(t, "600.50 USD", NewMoney(60050, "USD").String())
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	price := NewMoney(1999, "USD")
+
+	sum, err := price.Add(NewMoney(1, "USD"))
+	require.NoError(t, err)
+	assert.Equal(t, NewMoney(2000, "USD"), sum)
+
+	difference, err := price.Sub(NewMoney(2000, "USD"))
+	require.NoError(t, err)
+	assert.True(t, difference.IsNegative())
+
+	assert.Equal(t, NewMoney(5997, "USD"), price.Mul(3))
+	// 7.5% of 0.99 is 0.07425
+	assert.Equal(t, NewMoney(7, "USD"), NewMoney(99, "USD").Percent(7.5))
+	// Halves round away from zero
+	assert.Equal(t, NewMoney(-1, "USD"), NewMoney(-10, "USD").Percent(5))
+
+	_, err = price.Add(NewMoney(1, "EUR"))
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+
+	result, err := price.Cmp(NewMoney(2000, "USD"))
+	require.NoError(t, err)
+	assert.Equal(t, -1, result)
+	_, err = price.Cmp(NewMoney(1999, "EUR"))
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+
+	assert.Equal(t, NewMoney(5000000, "USD"), FromMajorUnits(50000, "USD"))
+	assert.Equal(t, NewMoney(50000, "JPY"), FromMajorUnits(50000, "JPY"))
+}
+
+func TestMoneyJSON(t *testing.T) {
+	data, err := json.Marshal(NewMoney(60050, "USD"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"amount":"600.50","currency":"USD"}`, string(data))
+
+	data, err = json.Marshal(Money{})
+	require.NoError(t, err)
+	assert.Equal(t, "null", string(data))
+
+	var money Money
+	require.NoError(t, json.Unmarshal([]byte(`{"amount":"600.50","currency":"USD"}`), &money))
+	assert.Equal(t, NewMoney(60050, "USD"), money)
+
+	require.NoError(t, json.Unmarshal([]byte(`{"amount":1500,"currency":"JPY"}`), &money))
+	assert.Equal(t, NewMoney(1500, "JPY"), money)
+
+	// Amounts stored before prices had a currency
+	require.NoError(t, json.Unmarshal([]byte(`60000.5`), &money))
+	assert.Equal(t, NewMoney(6000050, DefaultCurrency), money)
+
+	for _, bad := range []string{
+		`{"amount":"1.005","currency":"USD"}`,
+		`{"amount":"10"}`,
+		`{"currency":"USD"}`,
+		`"ten"`,
+	} {
+		assert.Error(t, json.Unmarshal([]byte(bad), &money), bad)
+	}
+}
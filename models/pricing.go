@@ -0,0 +1,19 @@
+package models
+
+// PriceAdjustment is a discount or surcharge applied by a pricing rule
+// @Description Price adjustment; discounts are negative
+type PriceAdjustment struct {
+	Rule        string `json:"rule" example:"volume_discount"`
+	Description string `json:"description" example:"10% off 5 or more"`
+	Amount      Money  `json:"amount"`
+}
+
+// PriceBreakdown explains how the price of a booking was computed
+// @Description How a booking's price was computed
+type PriceBreakdown struct {
+	UnitPrice   Money             `json:"unit_price"` // the service's base price
+	Quantity    int               `json:"quantity" example:"5"`
+	Subtotal    Money             `json:"subtotal"`
+	Adjustments []PriceAdjustment `json:"adjustments,omitempty"` // in the order they were applied
+	Total       Money             `json:"total"`
+}
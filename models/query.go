@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// BookingQuery describes which bookings to list and how to page through them
+type BookingQuery struct {
+	Filter BookingFilter
+	Sort   []SortKey // applied in order; ties are always broken by ID
+	Limit  int       // zero means no limit
+	Offset int       // ignored when Cursor is set
+	Cursor string    // opaque cursor taken from BookingPage.NextCursor
+}
+
+// BookingFilter restricts which bookings are listed. Zero values match everything.
+type BookingFilter struct {
+	UserID        string
+	ServiceID     string
+	Statuses      []BookingStatus // any of
+	MinPrice      *Money          // inclusive; bookings in other currencies never match
+	MaxPrice      *Money          // inclusive; bookings in other currencies never match
+	CreatedFrom   *time.Time      // inclusive
+	CreatedBefore *time.Time      // exclusive
+	HighValueOnly bool            // resolved by the usecase into PriceAbove
+	PriceAbove    *Money          // exclusive lower price bound
+}
+
+// BookingPage is one page of bookings together with paging metadata
+// @Description Paginated list of bookings
+type BookingPage struct {
+	Data       []*Booking `json:"data"`
+	Total      int        `json:"total" example:"42"`
+	Limit      int        `json:"limit" example:"20"`
+	Offset     int        `json:"offset" example:"0"`
+	NextCursor string     `json:"next_cursor,omitempty" example:"eyJzIjoiaWQiLCJ2IjpbIjAxSFM4WlFYM04iXX0"`
+}
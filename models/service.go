@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// CreditCheckPolicy decides which bookings of a service need a credit check
+// @Description Credit check policy of a service
+type CreditCheckPolicy string
+
+const (
+	CreditCheckAboveThreshold CreditCheckPolicy = "above_threshold" // Only bookings above the high-value threshold
+	CreditCheckAlways         CreditCheckPolicy = "always"          // Every booking
+	CreditCheckNever          CreditCheckPolicy = "never"           // No booking
+)
+
+// Service is an entry of the service catalog that bookings refer to by ServiceID
+// @Description Bookable service
+type Service struct {
+	ID          string            `json:"id" example:"service456"`
+	Name        string            `json:"name" example:"Airport transfer"`
+	BasePrice   Money             `json:"base_price"`
+	Active      bool              `json:"active" example:"true"` // inactive services cannot be booked
+	CreditCheck CreditCheckPolicy `json:"credit_check" example:"above_threshold"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// ServiceRequest is the body of POST /services and PUT /services/{id}
+// @Description Service request. Active defaults to true and credit_check to above_threshold.
+type ServiceRequest struct {
+	ID          string            `json:"id,omitempty" example:"service456" validate:"omitempty,max=64"` // generated when empty; ignored on update
+	Name        string            `json:"name" example:"Airport transfer" validate:"required,max=128"`
+	BasePrice   Money             `json:"base_price" validate:"gte=0,lte=100000000"` // in the service's currency
+	Active      *bool             `json:"active,omitempty" example:"true"`
+	CreditCheck CreditCheckPolicy `json:"credit_check,omitempty" example:"above_threshold" validate:"omitempty,oneof=above_threshold always never"`
+}
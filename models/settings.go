@@ -0,0 +1,15 @@
+package models
+
+// BookingSettings are the booking rules admins can change while the service runs
+// @Description Booking rules in effect
+type BookingSettings struct {
+	HighValueThreshold Money  `json:"high_value_threshold"`         // bookings priced above it need a credit check
+	ExpiryWindow       string `json:"expiry_window" example:"5m0s"` // how long a booking may stay pending
+}
+
+// BookingSettingsRequest changes the booking rules; omitted fields keep their value
+// @Description Booking rule changes
+type BookingSettingsRequest struct {
+	HighValueThreshold *Money `json:"high_value_threshold,omitempty"`
+	ExpiryWindow       string `json:"expiry_window,omitempty" example:"10m"` // a Go duration
+}
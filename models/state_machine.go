@@ -0,0 +1,62 @@
+package models
+
+import "fmt"
+
+// InvalidTransitionError is returned when a booking status change is not allowed
+type InvalidTransitionError struct {
+	From BookingStatus
+	To   BookingStatus
+}
+
+func (e *InvalidTransitionError) Error() string {
+	return fmt.Sprintf("cannot change booking status from %s to %s", e.From, e.To)
+}
+
+// StateMachine declares which booking status changes are allowed
+type StateMachine struct {
+	transitions map[BookingStatus]map[BookingStatus]bool
+}
+
+// NewStateMachine builds a state machine from a from -> allowed targets table.
+// Statuses that only appear as targets are terminal.
+func NewStateMachine(transitions map[BookingStatus][]BookingStatus) *StateMachine {
+	machine := &StateMachine{transitions: make(map[BookingStatus]map[BookingStatus]bool)}
+	for from, targets := range transitions {
+		allowed := make(map[BookingStatus]bool, len(targets))
+		for _, to := range targets {
+			allowed[to] = true
+		}
+		machine.transitions[from] = allowed
+	}
+	return machine
+}
+
+// BookingStateMachine is the lifecycle every booking follows:
+//
+//	pending   -> confirmed | rejected | canceled
+//	confirmed -> canceled
+//
+// Rejected and canceled bookings are final. Canceling a confirmed booking is
+// reserved to staff; the usecase checks the permission.
+var BookingStateMachine = NewStateMachine(map[BookingStatus][]BookingStatus{
+	StatusPending:   {StatusConfirmed, StatusRejected, StatusCanceled},
+	StatusConfirmed: {StatusCanceled},
+})
+
+// CanTransition reports whether a booking may move from one status to another
+func (m *StateMachine) CanTransition(from, to BookingStatus) bool {
+	return m.transitions[from][to]
+}
+
+// Transition validates a status change, returning an *InvalidTransitionError if it is not allowed
+func (m *StateMachine) Transition(from, to BookingStatus) error {
+	if !m.CanTransition(from, to) {
+		return &InvalidTransitionError{From: from, To: to}
+	}
+	return nil
+}
+
+// IsTerminal reports whether no further transitions are possible from status
+func (m *StateMachine) IsTerminal(status BookingStatus) bool {
+	return len(m.transitions[status]) == 0
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBookingStateMachine(t *testing.T) {
+	tests := []struct {
+		from    BookingStatus
+		to      BookingStatus
+		allowed bool
+	}{
+		{StatusPending, StatusConfirmed, true},
+		{StatusPending, StatusRejected, true},
+		{StatusPending, StatusCanceled, true},
+		{StatusConfirmed, StatusCanceled, true},
+		{StatusConfirmed, StatusRejected, false},
+		{StatusCanceled, StatusConfirmed, false},
+		{StatusRejected, StatusConfirmed, false},
+		{StatusPending, StatusPending, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.from)+"->"+string(tt.to), func(t *testing.T) {
+			err := BookingStateMachine.Transition(tt.from, tt.to)
+			if tt.allowed {
+				assert.NoError(t, err)
+				return
+			}
+
+			var transitionErr *InvalidTransitionError
+			assert.True(t, errors.As(err, &transitionErr), "Expected an InvalidTransitionError")
+			assert.Equal(t, tt.from, transitionErr.From)
+			assert.Equal(t, tt.to, transitionErr.To)
+		})
+	}
+}
+
+func TestStateMachineTerminalStates(t *testing.T) {
+	assert.False(t, BookingStateMachine.IsTerminal(StatusPending))
+	assert.False(t, BookingStateMachine.IsTerminal(StatusConfirmed))
+	assert.True(t, BookingStateMachine.IsTerminal(StatusRejected))
+	assert.True(t, BookingStateMachine.IsTerminal(StatusCanceled))
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// WebhookSubscription registers a URL for signed callbacks about booking events
+// @Description Webhook subscription. The secret is only returned when the subscription is created.
+type WebhookSubscription struct {
+	ID         string            `json:"id" example:"01HS8ZQX3N8F6D9K2M4P7R1T5V"`
+	UserID     string            `json:"user_id,omitempty" example:"user123"` // empty for global subscriptions
+	URL        string            `json:"url" example:"https://example.com/hooks/bookings"`
+	EventTypes []DomainEventType `json:"event_types,omitempty"` // empty for every event type
+	Secret     string            `json:"secret,omitempty" example:"whsec_3q2+7w=="`
+	Active     bool              `json:"active" example:"true"`
+	CreatedAt  time.Time         `json:"created_at"`
+}
+
+// Matches reports whether the subscription wants event
+func (s *WebhookSubscription) Matches(event DomainEvent) bool {
+	if !s.Active {
+		return false
+	}
+	if s.UserID != "" && s.UserID != event.Booking.UserID {
+		return false
+	}
+	return len(s.EventTypes) == 0 || slices.Contains(s.EventTypes, event.Type)
+}
+
+// WebhookSubscriptionRequest is the body of POST /webhooks
+// @Description Webhook subscription request. Leave user_id empty to receive every user's events, and event_types empty for every event type.
+type WebhookSubscriptionRequest struct {
+	URL        string            `json:"url" validate:"required,http_url" example:"https://example.com/hooks/bookings"`
+	UserID     string            `json:"user_id" example:"user123"`
+	EventTypes []DomainEventType `json:"event_types" validate:"dive,oneof=booking.confirmed booking.rejected booking.canceled"`
+	Secret     string            `json:"secret" validate:"omitempty,min=16" example:"a-long-shared-secret"` // generated when empty
+}
+
+// DeliveryStatus is where a webhook delivery is in its lifecycle
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"   // Waiting for its first attempt or a retry
+	DeliverySucceeded DeliveryStatus = "succeeded" // The endpoint answered with a 2xx status
+	DeliveryFailed    DeliveryStatus = "failed"    // Gave up after exhausting all attempts
+)
+
+// WebhookDelivery is one event sent to one subscription, as recorded in the delivery log
+// @Description Webhook delivery log entry
+type WebhookDelivery struct {
+	ID             string          `json:"id" example:"01HS8ZQX3N8F6D9K2M4P7R1T5V"`
+	SubscriptionID string          `json:"subscription_id" example:"01HS8ZQX3N8F6D9K2M4P7R1T5V"`
+	EventID        string          `json:"event_id" example:"01HS8ZQX3N8F6D9K2M4P7R1T5V"`
+	EventType      DomainEventType `json:"event_type" example:"booking.confirmed"`
+	BookingID      string          `json:"booking_id" example:"01HS8ZQX3N8F6D9K2M4P7R1T5V"`
+	Payload        string          `json:"-"`
+	Status         DeliveryStatus  `json:"status" example:"succeeded"`
+	Attempts       int             `json:"attempts" example:"1"`
+	LastStatusCode int             `json:"last_status_code,omitempty" example:"200"`
+	LastError      string          `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
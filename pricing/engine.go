@@ -0,0 +1,64 @@
+// Package pricing computes booking prices on the server from the service
+// catalog, the booked quantity and a list of pricing rules.
+package pricing
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/touchsung/spd-fiber-booking-system/models"
+)
+
+// ErrInvalidQuantity is returned when asked to price fewer than one unit
+var ErrInvalidQuantity = errors.New("quantity must be at least 1")
+
+// Rule adjusts the running total of a quote. Rules run in order, each seeing
+// the total left by the previous ones.
+type Rule interface {
+	// Apply returns the adjustment to add to total, or false when the rule
+	// does not apply to this quote
+	Apply(service *models.Service, quantity int, total models.Money) (models.PriceAdjustment, bool)
+}
+
+// Engine prices bookings as the service's base price times the quantity,
+// adjusted by each rule in turn
+type Engine struct {
+	rules []Rule
+}
+
+func NewEngine(rules ...Rule) *Engine {
+	return &Engine{rules: rules}
+}
+
+// Quote prices quantity units of service in the service's currency.
+// Rules round their adjustments to the currency's minor unit.
+func (e *Engine) Quote(service *models.Service, quantity int) (*models.PriceBreakdown, error) {
+	if quantity < 1 {
+		return nil, ErrInvalidQuantity
+	}
+
+	subtotal := service.BasePrice.Mul(int64(quantity))
+	breakdown := &models.PriceBreakdown{
+		UnitPrice: service.BasePrice,
+		Quantity:  quantity,
+		Subtotal:  subtotal,
+		Total:     subtotal,
+	}
+	for _, rule := range e.rules {
+		adjustment, ok := rule.Apply(service, quantity, breakdown.Total)
+		if !ok || adjustment.Amount.IsZero() {
+			continue
+		}
+		total, err := breakdown.Total.Add(adjustment.Amount)
+		if err != nil {
+			return nil, fmt.Errorf("applying %s: %w", adjustment.Rule, err)
+		}
+		breakdown.Adjustments = append(breakdown.Adjustments, adjustment)
+		breakdown.Total = total
+	}
+	// Discounts never make a booking pay out
+	if breakdown.Total.IsNegative() {
+		breakdown.Total = models.NewMoney(0, breakdown.Total.Currency)
+	}
+	return breakdown, nil
+}
@@ -0,0 +1,67 @@
+package pricing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/touchsung/spd-fiber-booking-system/models"
+)
+
+func usd(amount int64) models.Money {
+	return models.NewMoney(amount, "USD")
+}
+
+func TestQuoteWithoutRules(t *testing.T) {
+	service := &models.Service{ID: "transfer", BasePrice: usd(1999)}
+
+	breakdown, err := NewEngine().Quote(service, 3)
+	require.NoError(t, err)
+	assert.Equal(t, &models.PriceBreakdown{UnitPrice: usd(1999), Quantity: 3, Subtotal: usd(5997), Total: usd(5997)}, breakdown)
+
+	_, err = NewEngine().Quote(service, 0)
+	assert.ErrorIs(t, err, ErrInvalidQuantity)
+}
+
+func TestQuoteAppliesRulesInOrder(t *testing.T) {
+	engine := NewEngine(
+		VolumeDiscount{Tiers: []VolumeTier{{MinQuantity: 5, Percent: 10}, {MinQuantity: 10, Percent: 15}}},
+		Tax{Name: "VAT", Percent: 7},
+	)
+	service := &models.Service{ID: "transfer", BasePrice: usd(100000)}
+
+	breakdown, err := engine.Quote(service, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []models.PriceAdjustment{{Rule: "tax", Description: "VAT 7%", Amount: usd(14000)}}, breakdown.Adjustments)
+	assert.Equal(t, usd(214000), breakdown.Total)
+
+	breakdown, err = engine.Quote(service, 5)
+	require.NoError(t, err)
+	assert.Equal(t, usd(500000), breakdown.Subtotal)
+	assert.Equal(t, []models.PriceAdjustment{
+		{Rule: "volume_discount", Description: "10% off 5 or more", Amount: usd(-50000)},
+		{Rule: "tax", Description: "VAT 7%", Amount: usd(31500)},
+	}, breakdown.Adjustments)
+	assert.Equal(t, usd(481500), breakdown.Total)
+
+	// The highest tier reached wins
+	breakdown, err = engine.Quote(service, 12)
+	require.NoError(t, err)
+	assert.Equal(t, usd(-180000), breakdown.Adjustments[0].Amount)
+	assert.Equal(t, usd(1091400), breakdown.Total)
+}
+
+func TestQuoteRoundsToMinorUnits(t *testing.T) {
+	engine := NewEngine(Tax{Percent: 7.5})
+	breakdown, err := engine.Quote(&models.Service{BasePrice: usd(99)}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, usd(7), breakdown.Adjustments[0].Amount)
+	assert.Equal(t, usd(106), breakdown.Total)
+	assert.Equal(t, "tax 7.5%", breakdown.Adjustments[0].Description)
+
+	// Yen have no minor unit: 7.5% of 99 JPY is 7 JPY
+	breakdown, err = engine.Quote(&models.Service{BasePrice: models.NewMoney(99, "JPY")}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, models.NewMoney(106, "JPY"), breakdown.Total)
+	assert.Equal(t, "106 JPY", breakdown.Total.String())
+}
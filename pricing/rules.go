@@ -0,0 +1,67 @@
+package pricing
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/touchsung/spd-fiber-booking-system/models"
+)
+
+// Ensure the built-in rules satisfy Rule
+var (
+	_ Rule = VolumeDiscount{}
+	_ Rule = Tax{}
+)
+
+// VolumeTier takes Percent off bookings of at least MinQuantity units
+type VolumeTier struct {
+	MinQuantity int
+	Percent     float64
+}
+
+// VolumeDiscount applies the tier with the highest MinQuantity the booking reaches
+type VolumeDiscount struct {
+	Tiers []VolumeTier
+}
+
+func (d VolumeDiscount) Apply(service *models.Service, quantity int, total models.Money) (models.PriceAdjustment, bool) {
+	var best *VolumeTier
+	for i, tier := range d.Tiers {
+		if quantity >= tier.MinQuantity && (best == nil || tier.MinQuantity > best.MinQuantity) {
+			best = &d.Tiers[i]
+		}
+	}
+	if best == nil {
+		return models.PriceAdjustment{}, false
+	}
+	return models.PriceAdjustment{
+		Rule:        "volume_discount",
+		Description: fmt.Sprintf("%s%% off %d or more", formatPercent(best.Percent), best.MinQuantity),
+		Amount:      total.Percent(best.Percent).Neg(),
+	}, true
+}
+
+// Tax adds Percent of the total so far, so it should run after any discounts
+type Tax struct {
+	Name    string // shown in the breakdown, "tax" when empty
+	Percent float64
+}
+
+func (t Tax) Apply(service *models.Service, quantity int, total models.Money) (models.PriceAdjustment, bool) {
+	if t.Percent == 0 {
+		return models.PriceAdjustment{}, false
+	}
+	name := t.Name
+	if name == "" {
+		name = "tax"
+	}
+	return models.PriceAdjustment{
+		Rule:        "tax",
+		Description: fmt.Sprintf("%s %s%%", name, formatPercent(t.Percent)),
+		Amount:      total.Percent(t.Percent),
+	}, true
+}
+
+func formatPercent(percent float64) string {
+	return strconv.FormatFloat(percent, 'f', -1, 64)
+}
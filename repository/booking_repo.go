@@ -2,62 +2,130 @@ package repository
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/touchsung/spd-fiber-booking-system/models"
 )
 
+// Ensure MockRepository satisfies BookingRepository and Outbox
+var (
+	_ BookingRepository = (*MockRepository)(nil)
+	_ Outbox            = (*MockRepository)(nil)
+)
+
+// MockRepository keeps bookings in memory. Bookings are cloned on the way in
+// and out, so callers can never mutate stored state.
 type MockRepository struct {
-	defaultBookings map[string]*models.Booking
+	mutex           sync.RWMutex
+	defaultBookings map[string]models.Booking
+	history         map[string][]models.StatusChange
+	*memoryOutbox
 }
 
 func NewMockRepository() *MockRepository {
 	mockRepo := &MockRepository{
-		defaultBookings: make(map[string]*models.Booking),
+		defaultBookings: make(map[string]models.Booking),
+		history:         make(map[string][]models.StatusChange),
+		memoryOutbox:    newMemoryOutbox(),
 	}
 
 	baseTime := time.Now().Add(-24 * time.Hour) // Start from yesterday
 	// Initialize default bookings (ID 1-10)
 	for i := 1; i <= 10; i++ {
 		id := fmt.Sprintf("%d", i)
-		mockRepo.defaultBookings[id] = &models.Booking{
+		mockRepo.defaultBookings[id] = models.Booking{
 			ID:        id,
 			UserID:    fmt.Sprintf("user%d", i),
 			ServiceID: fmt.Sprintf("service%d", i),
-			Price:     float64(i * 10000), // Some will be high-value
+			Quantity:  1,
+			Price:     models.NewMoney(int64(i)*1000000, models.DefaultCurrency), // Some will be high-value
 			Status:    models.StatusConfirmed,
 			CreatedAt: baseTime.Add(time.Duration(i) * time.Hour), // Spread over time
+			Version:   1,
 		}
 	}
 
 	return mockRepo
 }
 
-func (m *MockRepository) GetBooking(bookingID string) (*models.Booking, bool) {
+func (m *MockRepository) GetBooking(bookingID string) (*models.Booking, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
 	booking, exists := m.defaultBookings[bookingID]
-	return booking, exists
+	if !exists {
+		return nil, ErrBookingNotFound
+	}
+	return booking.Clone(), nil
 }
 
-func (m *MockRepository) GetAllBookings() []*models.Booking {
+func (m *MockRepository) ListBookings(query models.BookingQuery) (*models.BookingPage, error) {
+	m.mutex.RLock()
 	bookings := make([]*models.Booking, 0, len(m.defaultBookings))
 	for _, booking := range m.defaultBookings {
-		bookings = append(bookings, booking)
+		bookings = append(bookings, booking.Clone())
+	}
+	m.mutex.RUnlock()
+
+	return queryBookings(bookings, query)
+}
+
+func (m *MockRepository) UpdateBookingStatus(change models.StatusChange, expectedVersion int64, events ...models.DomainEvent) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	booking, exists := m.defaultBookings[change.BookingID]
+	if !exists {
+		return ErrBookingNotFound
 	}
-	return bookings
+	if booking.Version != expectedVersion {
+		return ErrVersionConflict
+	}
+	booking.Status = change.To
+	booking.Version++
+	m.defaultBookings[change.BookingID] = booking
+
+	change.Version = booking.Version
+	m.history[change.BookingID] = append(m.history[change.BookingID], change)
+	m.add(events)
+	return nil
 }
 
-func (m *MockRepository) UpdateBookingStatus(bookingID string, status models.BookingStatus) bool {
-	if booking, exists := m.defaultBookings[bookingID]; exists {
-		booking.Status = status
-		return true
+func (m *MockRepository) GetStatusHistory(bookingID string) ([]models.StatusChange, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if _, exists := m.defaultBookings[bookingID]; !exists {
+		return nil, ErrBookingNotFound
 	}
-	return false
+	return append([]models.StatusChange{}, m.history[bookingID]...), nil
+}
+
+func (m *MockRepository) DeleteBooking(bookingID string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, exists := m.defaultBookings[bookingID]; !exists {
+		return ErrBookingNotFound
+	}
+	delete(m.defaultBookings, bookingID)
+	delete(m.history, bookingID)
+	return nil
 }
 
 func (m *MockRepository) ClearBookings() {
-	m.defaultBookings = make(map[string]*models.Booking)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.defaultBookings = make(map[string]models.Booking)
+	m.history = make(map[string][]models.StatusChange)
 }
 
-func (m *MockRepository) SaveBooking(booking *models.Booking) {
-	m.defaultBookings[booking.ID] = booking
+func (m *MockRepository) SaveBooking(booking *models.Booking) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.defaultBookings[booking.ID] = *booking.Clone()
+	return nil
 }
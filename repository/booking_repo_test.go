@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/touchsung/spd-fiber-booking-system/models"
+)
+
+func TestMockRepositoryCopiesBookings(t *testing.T) {
+	repo := NewMockRepository()
+	repo.ClearBookings()
+
+	booking := &models.Booking{ID: "1", Status: models.StatusPending, Version: 1}
+	require.NoError(t, repo.SaveBooking(booking))
+	booking.Status = models.StatusCanceled
+
+	found, err := repo.GetBooking("1")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusPending, found.Status)
+
+	found.Status = models.StatusConfirmed
+	page, err := repo.ListBookings(models.BookingQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusPending, page.Data[0].Status)
+
+	// Nor does the price breakdown
+	require.NoError(t, repo.SaveBooking(&models.Booking{ID: "2", Version: 1, PriceBreakdown: &models.PriceBreakdown{
+		Adjustments: []models.PriceAdjustment{{Rule: "volume_discount"}},
+	}}))
+	found, err = repo.GetBooking("2")
+	require.NoError(t, err)
+	found.PriceBreakdown.Adjustments[0].Rule = "coupon"
+	found, err = repo.GetBooking("2")
+	require.NoError(t, err)
+	assert.Equal(t, "volume_discount", found.PriceBreakdown.Adjustments[0].Rule)
+}
+
+func TestMockRepositoryConcurrentAccess(t *testing.T) {
+	repo := NewMockRepository()
+	repo.ClearBookings()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := fmt.Sprintf("%d", i%5)
+			_ = repo.SaveBooking(&models.Booking{ID: id, Status: models.StatusPending, Version: 1})
+			_ = repo.UpdateBookingStatus(models.StatusChange{BookingID: id, From: models.StatusPending, To: models.StatusCanceled}, 1)
+			_, _ = repo.GetBooking(id)
+			_, _ = repo.ListBookings(models.BookingQuery{})
+		}(i)
+	}
+	wg.Wait()
+
+	page, err := repo.ListBookings(models.BookingQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, 5, page.Total)
+}
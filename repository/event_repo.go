@@ -0,0 +1,281 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/touchsung/spd-fiber-booking-system/models"
+)
+
+var (
+	// ErrBookingExists is returned when saving a booking whose event stream already exists
+	ErrBookingExists = errors.New("booking already exists")
+	// ErrAppendOnly is returned for operations that would rewrite an event stream
+	ErrAppendOnly = errors.New("event-sourced bookings cannot be deleted")
+)
+
+// PointInTimeReader is implemented by repositories that can rebuild a booking
+// as it was at an earlier time
+type PointInTimeReader interface {
+	GetBookingAt(bookingID string, at time.Time) (*models.Booking, error)
+}
+
+// Ensure EventSourcedRepository satisfies BookingRepository, PointInTimeReader and Outbox
+var (
+	_ BookingRepository = (*EventSourcedRepository)(nil)
+	_ PointInTimeReader = (*EventSourcedRepository)(nil)
+	_ Outbox            = (*EventSourcedRepository)(nil)
+)
+
+// DefaultSnapshotInterval snapshots a booking after every event, so reads
+// normally load one snapshot and replay nothing
+const DefaultSnapshotInterval = 1
+
+// EventSourcedRepository never overwrites a booking. Every change is appended
+// to the booking's event stream and the current state is rebuilt by replaying
+// the stream on top of the latest snapshot.
+type EventSourcedRepository struct {
+	store            EventStore
+	snapshotInterval int64
+	now              func() time.Time
+}
+
+// EventRepositoryOption configures an EventSourcedRepository
+type EventRepositoryOption func(*EventSourcedRepository)
+
+// WithSnapshotInterval snapshots a booking every interval events. Zero disables snapshots.
+func WithSnapshotInterval(interval int) EventRepositoryOption {
+	return func(r *EventSourcedRepository) {
+		r.snapshotInterval = int64(interval)
+	}
+}
+
+func NewEventSourcedRepository(store EventStore, options ...EventRepositoryOption) *EventSourcedRepository {
+	repo := &EventSourcedRepository{
+		store:            store,
+		snapshotInterval: DefaultSnapshotInterval,
+		now:              time.Now,
+	}
+	for _, option := range options {
+		option(repo)
+	}
+	return repo
+}
+
+func (r *EventSourcedRepository) GetBooking(bookingID string) (*models.Booking, error) {
+	booking, err := r.store.Snapshot(bookingID)
+	if err != nil {
+		return nil, err
+	}
+	var after int64
+	if booking != nil {
+		after = booking.Version
+	}
+
+	events, err := r.store.Events(bookingID, after)
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range events {
+		booking = applyEvent(booking, event)
+	}
+	if booking == nil {
+		return nil, ErrBookingNotFound
+	}
+	return booking, nil
+}
+
+// GetBookingAt rebuilds the booking as it was at the given time by replaying
+// its events up to then. Bookings created after at are not found.
+func (r *EventSourcedRepository) GetBookingAt(bookingID string, at time.Time) (*models.Booking, error) {
+	events, err := r.store.Events(bookingID, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var booking *models.Booking
+	for _, event := range events {
+		if event.OccurredAt.After(at) {
+			break
+		}
+		booking = applyEvent(booking, event)
+	}
+	if booking == nil {
+		return nil, ErrBookingNotFound
+	}
+	return booking, nil
+}
+
+// ListBookings queries the store's projection when it keeps one. Otherwise it
+// loads the snapshots of every booking and filters them in memory; only
+// bookings whose snapshot is behind their stream are replayed, which with the
+// default snapshot interval happens only when saving a snapshot failed.
+func (r *EventSourcedRepository) ListBookings(query models.BookingQuery) (*models.BookingPage, error) {
+	if lister, ok := r.store.(BookingLister); ok {
+		return lister.ListBookings(query)
+	}
+
+	versions, err := r.store.StreamVersions()
+	if err != nil {
+		return nil, err
+	}
+	snapshots, err := r.store.Snapshots()
+	if err != nil {
+		return nil, err
+	}
+	current := make(map[string]*models.Booking, len(snapshots))
+	for _, snapshot := range snapshots {
+		current[snapshot.ID] = snapshot
+	}
+
+	bookings := make([]*models.Booking, 0, len(versions))
+	for id, version := range versions {
+		if snapshot, ok := current[id]; ok && snapshot.Version >= version {
+			bookings = append(bookings, snapshot)
+			continue
+		}
+		booking, err := r.GetBooking(id)
+		if err != nil {
+			return nil, fmt.Errorf("rebuilding booking %s: %w", id, err)
+		}
+		bookings = append(bookings, booking)
+	}
+	return queryBookings(bookings, query)
+}
+
+// SaveBooking starts a new event stream. Existing bookings only change
+// through UpdateBookingStatus.
+func (r *EventSourcedRepository) SaveBooking(booking *models.Booking) error {
+	initial := booking.Clone()
+	initial.Version = 1
+	event := models.BookingEvent{
+		BookingID:  booking.ID,
+		Version:    1,
+		Type:       models.EventCreated,
+		OccurredAt: booking.CreatedAt,
+		Booking:    initial,
+	}
+	if err := r.store.Append(booking.ID, 0, []models.BookingEvent{event}); err != nil {
+		if errors.Is(err, ErrVersionConflict) {
+			return ErrBookingExists
+		}
+		return err
+	}
+	return r.snapshot(initial)
+}
+
+func (r *EventSourcedRepository) UpdateBookingStatus(change models.StatusChange, expectedVersion int64, events ...models.DomainEvent) error {
+	eventType, err := eventTypeFor(change)
+	if err != nil {
+		return err
+	}
+	booking, err := r.GetBooking(change.BookingID)
+	if err != nil {
+		return err
+	}
+	if booking.Version != expectedVersion {
+		return ErrVersionConflict
+	}
+
+	occurredAt := change.ChangedAt
+	if occurredAt.IsZero() {
+		occurredAt = r.now()
+	}
+	event := models.BookingEvent{
+		BookingID:  change.BookingID,
+		Version:    expectedVersion + 1,
+		Type:       eventType,
+		Actor:      change.Actor,
+		Reason:     change.Reason,
+		OccurredAt: occurredAt,
+	}
+	if err := r.store.Append(change.BookingID, expectedVersion, []models.BookingEvent{event}, events...); err != nil {
+		return err
+	}
+	return r.snapshot(applyEvent(booking, event))
+}
+
+// GetStatusHistory derives the status changes from the event stream
+func (r *EventSourcedRepository) GetStatusHistory(bookingID string) ([]models.StatusChange, error) {
+	events, err := r.store.Events(bookingID, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, ErrBookingNotFound
+	}
+
+	history := make([]models.StatusChange, 0, len(events)-1)
+	status := events[0].Status()
+	for _, event := range events[1:] {
+		history = append(history, models.StatusChange{
+			BookingID: bookingID,
+			From:      status,
+			To:        event.Status(),
+			Actor:     event.Actor,
+			Reason:    event.Reason,
+			Version:   event.Version,
+			ChangedAt: event.OccurredAt,
+		})
+		status = event.Status()
+	}
+	return history, nil
+}
+
+func (r *EventSourcedRepository) DeleteBooking(bookingID string) error {
+	return ErrAppendOnly
+}
+
+func (r *EventSourcedRepository) PendingEvents(now time.Time, limit int) ([]models.OutboxMessage, error) {
+	return r.store.PendingEvents(now, limit)
+}
+
+func (r *EventSourcedRepository) MarkPublished(eventID string, at time.Time) error {
+	return r.store.MarkPublished(eventID, at)
+}
+
+func (r *EventSourcedRepository) MarkFailed(eventID string, reason string, retryAt time.Time) error {
+	return r.store.MarkFailed(eventID, reason, retryAt)
+}
+
+func (r *EventSourcedRepository) snapshot(booking *models.Booking) error {
+	if r.snapshotInterval <= 0 || booking.Version%r.snapshotInterval != 0 {
+		return nil
+	}
+	// The event is already durable; a missing snapshot only makes reads replay more
+	_ = r.store.SaveSnapshot(booking)
+	return nil
+}
+
+// eventTypeFor names the event recorded for a status change
+func eventTypeFor(change models.StatusChange) (models.BookingEventType, error) {
+	switch change.To {
+	case models.StatusConfirmed:
+		return models.EventCreditCheckPassed, nil
+	case models.StatusRejected:
+		return models.EventCreditCheckFailed, nil
+	case models.StatusCanceled:
+		if change.Actor == models.ActorExpirySweeper {
+			return models.EventExpired, nil
+		}
+		return models.EventCanceled, nil
+	}
+	return "", fmt.Errorf("no booking event records a change to status %q", change.To)
+}
+
+// applyEvent returns the booking state after event; booking is nil before the
+// created event and is never modified in place
+func applyEvent(booking *models.Booking, event models.BookingEvent) *models.Booking {
+	var next models.Booking
+	if event.Type == models.EventCreated && event.Booking != nil {
+		next = *event.Booking.Clone()
+	} else if booking != nil {
+		next = *booking
+	} else {
+		return nil
+	}
+	next.Status = event.Status()
+	next.Version = event.Version
+	return &next
+}
@@ -0,0 +1,328 @@
+package repository
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/touchsung/spd-fiber-booking-system/models"
+)
+
+// eventStores runs each test against the memory and the SQLite event store
+func eventStores(t *testing.T) map[string]func(t *testing.T) EventStore {
+	return map[string]func(t *testing.T) EventStore{
+		"memory": func(t *testing.T) EventStore { return NewMemoryEventStore() },
+		"sqlite": func(t *testing.T) EventStore {
+			db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "events.db"))
+			require.NoError(t, err)
+			t.Cleanup(func() { db.Close() })
+
+			store, err := NewSQLEventStore(db)
+			require.NoError(t, err)
+			return store
+		},
+	}
+}
+
+func newPendingBooking(id string, createdAt time.Time) *models.Booking {
+	return &models.Booking{
+		ID:        id,
+		UserID:    "user1",
+		ServiceID: "service1",
+		Price:     usd(6000000),
+		Status:    models.StatusPending,
+		CreatedAt: createdAt,
+		Version:   1,
+	}
+}
+
+func TestEventSourcedRepositoryReplaysEvents(t *testing.T) {
+	for name, newStore := range eventStores(t) {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			// Without snapshots every read replays the whole stream
+			repo := NewEventSourcedRepository(store, WithSnapshotInterval(0))
+			createdAt := time.Now().Truncate(time.Microsecond)
+			require.NoError(t, repo.SaveBooking(newPendingBooking("booking-1", createdAt)))
+
+			require.NoError(t, repo.UpdateBookingStatus(models.StatusChange{
+				BookingID: "booking-1",
+				To:        models.StatusConfirmed,
+				Actor:     models.ActorCreditCheck,
+				Reason:    "credit check approved",
+				ChangedAt: createdAt.Add(time.Second),
+			}, 1))
+
+			found, err := repo.GetBooking("booking-1")
+			require.NoError(t, err)
+			assert.Equal(t, models.StatusConfirmed, found.Status)
+			assert.Equal(t, int64(2), found.Version)
+			assert.Equal(t, usd(6000000), found.Price)
+			assert.True(t, createdAt.Equal(found.CreatedAt))
+
+			events, err := store.Events("booking-1", 0)
+			require.NoError(t, err)
+			require.Len(t, events, 2)
+			assert.Equal(t, models.EventCreated, events[0].Type)
+			assert.Equal(t, models.EventCreditCheckPassed, events[1].Type)
+
+			snapshot, err := store.Snapshot("booking-1")
+			require.NoError(t, err)
+			assert.Nil(t, snapshot)
+
+			_, err = repo.GetBooking("missing")
+			assert.ErrorIs(t, err, ErrBookingNotFound)
+		})
+	}
+}
+
+func TestEventSourcedRepositorySnapshots(t *testing.T) {
+	for name, newStore := range eventStores(t) {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			repo := NewEventSourcedRepository(store, WithSnapshotInterval(2))
+			require.NoError(t, repo.SaveBooking(newPendingBooking("booking-1", time.Now())))
+
+			snapshot, err := store.Snapshot("booking-1")
+			require.NoError(t, err)
+			assert.Nil(t, snapshot, "version 1 is not due for a snapshot")
+
+			require.NoError(t, repo.UpdateBookingStatus(models.StatusChange{
+				BookingID: "booking-1",
+				To:        models.StatusCanceled,
+				Actor:     models.ActorUser,
+			}, 1))
+
+			snapshot, err = store.Snapshot("booking-1")
+			require.NoError(t, err)
+			require.NotNil(t, snapshot)
+			assert.Equal(t, int64(2), snapshot.Version)
+			assert.Equal(t, models.StatusCanceled, snapshot.Status)
+
+			// Reads start from the snapshot, so one that is ahead of the log wins
+			require.NoError(t, store.SaveSnapshot(&models.Booking{ID: "booking-1", Price: usd(1), Status: models.StatusCanceled, Version: 3}))
+			found, err := repo.GetBooking("booking-1")
+			require.NoError(t, err)
+			assert.Equal(t, usd(1), found.Price)
+		})
+	}
+}
+
+func TestEventSourcedRepositoryPointInTime(t *testing.T) {
+	for name, newStore := range eventStores(t) {
+		t.Run(name, func(t *testing.T) {
+			repo := NewEventSourcedRepository(newStore(t))
+			createdAt := time.Now().Truncate(time.Microsecond)
+			require.NoError(t, repo.SaveBooking(newPendingBooking("booking-1", createdAt)))
+			require.NoError(t, repo.UpdateBookingStatus(models.StatusChange{
+				BookingID: "booking-1",
+				To:        models.StatusCanceled,
+				Actor:     models.ActorExpirySweeper,
+				Reason:    "not confirmed within 5m0s",
+				ChangedAt: createdAt.Add(time.Minute),
+			}, 1))
+
+			_, err := repo.GetBookingAt("booking-1", createdAt.Add(-time.Second))
+			assert.ErrorIs(t, err, ErrBookingNotFound)
+
+			before, err := repo.GetBookingAt("booking-1", createdAt.Add(30*time.Second))
+			require.NoError(t, err)
+			assert.Equal(t, models.StatusPending, before.Status)
+			assert.Equal(t, int64(1), before.Version)
+
+			after, err := repo.GetBookingAt("booking-1", createdAt.Add(time.Minute))
+			require.NoError(t, err)
+			assert.Equal(t, models.StatusCanceled, after.Status)
+			assert.Equal(t, int64(2), after.Version)
+
+			history, err := repo.GetStatusHistory("booking-1")
+			require.NoError(t, err)
+			require.Len(t, history, 1)
+			assert.Equal(t, models.StatusPending, history[0].From)
+			assert.Equal(t, models.StatusCanceled, history[0].To)
+			assert.Equal(t, models.ActorExpirySweeper, history[0].Actor)
+			assert.Equal(t, "not confirmed within 5m0s", history[0].Reason)
+			assert.Equal(t, int64(2), history[0].Version)
+		})
+	}
+}
+
+func TestEventSourcedRepositoryConflicts(t *testing.T) {
+	for name, newStore := range eventStores(t) {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			repo := NewEventSourcedRepository(store)
+			booking := newPendingBooking("booking-1", time.Now())
+			require.NoError(t, repo.SaveBooking(booking))
+			assert.ErrorIs(t, repo.SaveBooking(booking), ErrBookingExists)
+
+			change := models.StatusChange{BookingID: "booking-1", To: models.StatusRejected, Actor: models.ActorCreditCheck}
+			assert.ErrorIs(t, repo.UpdateBookingStatus(change, 2), ErrVersionConflict)
+			require.NoError(t, repo.UpdateBookingStatus(change, 1))
+			assert.ErrorIs(t, repo.UpdateBookingStatus(change, 1), ErrVersionConflict)
+
+			// The store itself rejects appends at a stale version
+			assert.ErrorIs(t, store.Append("booking-1", 1, []models.BookingEvent{{
+				BookingID: "booking-1", Version: 2, Type: models.EventCanceled, OccurredAt: time.Now(),
+			}}), ErrVersionConflict)
+
+			// Outbox events are only stored with a successful append
+			event := models.DomainEvent{ID: "event-1", Type: models.DomainEventBookingCanceled, BookingID: "booking-1", OccurredAt: time.Now()}
+			cancel := models.StatusChange{BookingID: "booking-1", To: models.StatusCanceled, Actor: models.ActorUser}
+			assert.ErrorIs(t, repo.UpdateBookingStatus(cancel, 1, event), ErrVersionConflict)
+			pending, err := repo.PendingEvents(time.Now(), 10)
+			require.NoError(t, err)
+			assert.Empty(t, pending)
+
+			assert.ErrorIs(t, repo.DeleteBooking("booking-1"), ErrAppendOnly)
+			_, err = repo.GetStatusHistory("missing")
+			assert.ErrorIs(t, err, ErrBookingNotFound)
+		})
+	}
+}
+
+func TestEventSourcedRepositoryOutbox(t *testing.T) {
+	for name, newStore := range eventStores(t) {
+		t.Run(name, func(t *testing.T) {
+			repo := NewEventSourcedRepository(newStore(t))
+			require.NoError(t, repo.SaveBooking(newPendingBooking("booking-1", time.Now())))
+
+			now := time.Now()
+			event := models.DomainEvent{ID: "event-1", Type: models.DomainEventBookingConfirmed, BookingID: "booking-1", OccurredAt: now}
+			require.NoError(t, repo.UpdateBookingStatus(models.StatusChange{
+				BookingID: "booking-1", To: models.StatusConfirmed, Actor: models.ActorCreditCheck, ChangedAt: now,
+			}, 1, event))
+
+			pending, err := repo.PendingEvents(now, 10)
+			require.NoError(t, err)
+			require.Len(t, pending, 1)
+			assert.Equal(t, models.DomainEventBookingConfirmed, pending[0].Event.Type)
+
+			require.NoError(t, repo.MarkPublished("event-1", now))
+			pending, err = repo.PendingEvents(now, 10)
+			require.NoError(t, err)
+			assert.Empty(t, pending)
+		})
+	}
+}
+
+func TestEventSourcedRepositoryList(t *testing.T) {
+	for name, newStore := range eventStores(t) {
+		t.Run(name, func(t *testing.T) {
+			repo := NewEventSourcedRepository(newStore(t))
+			now := time.Now()
+			for _, id := range []string{"b", "a", "c"} {
+				require.NoError(t, repo.SaveBooking(newPendingBooking(id, now)))
+			}
+			require.NoError(t, repo.UpdateBookingStatus(models.StatusChange{
+				BookingID: "c", To: models.StatusConfirmed, Actor: models.ActorCreditCheck,
+			}, 1))
+
+			page, err := repo.ListBookings(models.BookingQuery{
+				Filter: models.BookingFilter{Statuses: []models.BookingStatus{models.StatusPending}},
+				Limit:  10,
+			})
+			require.NoError(t, err)
+			require.Len(t, page.Data, 2)
+			assert.Equal(t, "a", page.Data[0].ID)
+			assert.Equal(t, "b", page.Data[1].ID)
+		})
+	}
+}
+
+// countingEventStore counts the streams read from an EventStore
+type countingEventStore struct {
+	EventStore
+	reads []string
+}
+
+func (s *countingEventStore) Events(bookingID string, afterVersion int64) ([]models.BookingEvent, error) {
+	s.reads = append(s.reads, bookingID)
+	return s.EventStore.Events(bookingID, afterVersion)
+}
+
+func TestEventSourcedRepositoryListUsesSnapshots(t *testing.T) {
+	for name, newStore := range eventStores(t) {
+		t.Run(name, func(t *testing.T) {
+			store := &countingEventStore{EventStore: newStore(t)}
+			repo := NewEventSourcedRepository(store, WithSnapshotInterval(2))
+			now := time.Now()
+			for _, id := range []string{"a", "b"} {
+				require.NoError(t, repo.SaveBooking(newPendingBooking(id, now)))
+				require.NoError(t, repo.UpdateBookingStatus(models.StatusChange{
+					BookingID: id, To: models.StatusConfirmed, Actor: models.ActorCreditCheck,
+				}, 1))
+			}
+			require.NoError(t, repo.SaveBooking(newPendingBooking("c", now)))
+			store.reads = nil
+
+			page, err := repo.ListBookings(models.BookingQuery{Limit: 10})
+			require.NoError(t, err)
+			require.Len(t, page.Data, 3)
+			for _, booking := range page.Data {
+				expected := models.StatusConfirmed
+				if booking.ID == "c" {
+					expected = models.StatusPending
+				}
+				assert.Equal(t, expected, booking.Status, booking.ID)
+			}
+			assert.Equal(t, []string{"c"}, store.reads, "Expected only the booking without a current snapshot to be replayed")
+		})
+	}
+}
+
+func TestSQLEventStoreListsFromProjection(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "events.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	store, err := NewSQLEventStore(db)
+	require.NoError(t, err)
+
+	// Without snapshots listings can only come from the projection
+	repo := NewEventSourcedRepository(store, WithSnapshotInterval(0))
+	start := time.Now().Truncate(time.Second)
+	for i, id := range []string{"a", "b", "c", "d", "e"} {
+		require.NoError(t, repo.SaveBooking(newPendingBooking(id, start.Add(time.Duration(i)*time.Minute))))
+	}
+	require.NoError(t, repo.UpdateBookingStatus(models.StatusChange{BookingID: "b", To: models.StatusConfirmed, Actor: models.ActorCreditCheck}, 1))
+	require.NoError(t, repo.UpdateBookingStatus(models.StatusChange{BookingID: "d", To: models.StatusCanceled, Actor: models.ActorUser}, 1))
+
+	pendingIDs := func(repo *EventSourcedRepository) []string {
+		query := models.BookingQuery{
+			Filter: models.BookingFilter{Statuses: []models.BookingStatus{models.StatusPending}},
+			Sort:   []models.SortKey{{Field: models.SortByDate, Desc: true}},
+			Limit:  2,
+		}
+		var ids []string
+		for {
+			page, err := repo.ListBookings(query)
+			require.NoError(t, err)
+			assert.Equal(t, 3, page.Total)
+			for _, booking := range page.Data {
+				assert.Equal(t, models.StatusPending, booking.Status)
+				ids = append(ids, booking.ID)
+			}
+			if page.NextCursor == "" {
+				return ids
+			}
+			query.Cursor = page.NextCursor
+		}
+	}
+	assert.Equal(t, []string{"e", "c", "a"}, pendingIDs(repo))
+
+	page, err := repo.ListBookings(models.BookingQuery{Filter: models.BookingFilter{Statuses: []models.BookingStatus{models.StatusConfirmed}}})
+	require.NoError(t, err)
+	require.Len(t, page.Data, 1)
+	assert.Equal(t, int64(2), page.Data[0].Version)
+	assert.Equal(t, usd(6000000), page.Data[0].Price)
+
+	// Streams written before the projection existed are projected on startup
+	_, err = db.Exec(`DELETE FROM booking_projections`)
+	require.NoError(t, err)
+	reopened, err := NewSQLEventStore(db)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"e", "c", "a"}, pendingIDs(NewEventSourcedRepository(reopened)))
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/touchsung/spd-fiber-booking-system/models"
+)
+
+// EventStore is an append-only log of booking events plus the snapshots used
+// to avoid replaying whole streams on every read. It also keeps the outbox of
+// domain events raised by those events.
+type EventStore interface {
+	Outbox
+	// Append adds events to a booking's stream, but only while the stream's
+	// last version still equals expectedVersion (zero for a new stream). The
+	// outbox events are stored in the same step.
+	Append(bookingID string, expectedVersion int64, events []models.BookingEvent, outbox ...models.DomainEvent) error
+	// Events returns the booking's events with a version above afterVersion, oldest first
+	Events(bookingID string, afterVersion int64) ([]models.BookingEvent, error)
+	// StreamVersions returns the last version of every stream by booking ID
+	StreamVersions() (map[string]int64, error)
+	// SaveSnapshot stores the booking state as of booking.Version
+	SaveSnapshot(booking *models.Booking) error
+	// Snapshot returns the latest snapshot, or nil when there is none
+	Snapshot(bookingID string) (*models.Booking, error)
+	// Snapshots returns the latest snapshot of every booking that has one
+	Snapshots() ([]*models.Booking, error)
+}
+
+// BookingLister is implemented by event stores that keep a queryable
+// projection of every booking's current state, so listings need not rebuild
+// bookings from their snapshots
+type BookingLister interface {
+	ListBookings(query models.BookingQuery) (*models.BookingPage, error)
+}
+
+// Ensure MemoryEventStore satisfies EventStore
+var _ EventStore = (*MemoryEventStore)(nil)
+
+// MemoryEventStore keeps event streams in memory
+type MemoryEventStore struct {
+	mutex     sync.RWMutex
+	streams   map[string][]models.BookingEvent
+	snapshots map[string]models.Booking
+	*memoryOutbox
+}
+
+func NewMemoryEventStore() *MemoryEventStore {
+	return &MemoryEventStore{
+		streams:      make(map[string][]models.BookingEvent),
+		snapshots:    make(map[string]models.Booking),
+		memoryOutbox: newMemoryOutbox(),
+	}
+}
+
+func (s *MemoryEventStore) Append(bookingID string, expectedVersion int64, events []models.BookingEvent, outbox ...models.DomainEvent) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stream := s.streams[bookingID]
+	if int64(len(stream)) != expectedVersion {
+		return ErrVersionConflict
+	}
+	for _, event := range events {
+		if event.Booking != nil {
+			event.Booking = event.Booking.Clone()
+		}
+		stream = append(stream, event)
+	}
+	s.streams[bookingID] = stream
+	s.add(outbox)
+	return nil
+}
+
+func (s *MemoryEventStore) Events(bookingID string, afterVersion int64) ([]models.BookingEvent, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	stream := s.streams[bookingID]
+	if afterVersion >= int64(len(stream)) {
+		return []models.BookingEvent{}, nil
+	}
+	return slices.Clone(stream[afterVersion:]), nil
+}
+
+func (s *MemoryEventStore) StreamVersions() (map[string]int64, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	versions := make(map[string]int64, len(s.streams))
+	for id, stream := range s.streams {
+		versions[id] = int64(len(stream))
+	}
+	return versions, nil
+}
+
+func (s *MemoryEventStore) SaveSnapshot(booking *models.Booking) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.snapshots[booking.ID] = *booking.Clone()
+	return nil
+}
+
+func (s *MemoryEventStore) Snapshot(bookingID string) (*models.Booking, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	snapshot, exists := s.snapshots[bookingID]
+	if !exists {
+		return nil, nil
+	}
+	return snapshot.Clone(), nil
+}
+
+func (s *MemoryEventStore) Snapshots() ([]*models.Booking, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	snapshots := make([]*models.Booking, 0, len(s.snapshots))
+	for _, snapshot := range s.snapshots {
+		snapshots = append(snapshots, snapshot.Clone())
+	}
+	return snapshots, nil
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/touchsung/spd-fiber-booking-system/models"
+)
+
+// ErrJobNotFound is returned when a job does not exist in the repository
+var ErrJobNotFound = errors.New("job not found")
+
+// JobRepository persists background job state so work survives a restart
+type JobRepository interface {
+	SaveJob(job *models.Job) error
+	GetJob(jobID string) (*models.Job, error)
+	// ListJobs returns jobs in any of the given statuses, oldest first. No statuses means all jobs.
+	ListJobs(statuses ...models.JobStatus) ([]*models.Job, error)
+}
+
+// Ensure MockJobRepository satisfies JobRepository
+var _ JobRepository = (*MockJobRepository)(nil)
+
+// MockJobRepository keeps jobs in memory; state is lost on restart
+type MockJobRepository struct {
+	jobs  map[string]models.Job
+	mutex sync.RWMutex
+}
+
+func NewMockJobRepository() *MockJobRepository {
+	return &MockJobRepository{
+		jobs: make(map[string]models.Job),
+	}
+}
+
+func (m *MockJobRepository) SaveJob(job *models.Job) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.jobs[job.ID] = *job
+	return nil
+}
+
+func (m *MockJobRepository) GetJob(jobID string) (*models.Job, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	job, exists := m.jobs[jobID]
+	if !exists {
+		return nil, ErrJobNotFound
+	}
+	return &job, nil
+}
+
+func (m *MockJobRepository) ListJobs(statuses ...models.JobStatus) ([]*models.Job, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	jobs := make([]*models.Job, 0)
+	for _, job := range m.jobs {
+		if len(statuses) > 0 && !containsJobStatus(statuses, job.Status) {
+			continue
+		}
+		job := job
+		jobs = append(jobs, &job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+	return jobs, nil
+}
+
+func containsJobStatus(statuses []models.JobStatus, status models.JobStatus) bool {
+	for _, candidate := range statuses {
+		if candidate == status {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,258 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// migration is a single, append-only schema change. Statements must stay
+// compatible with both PostgreSQL and SQLite.
+type migration struct {
+	version    int
+	statements []string
+}
+
+// migrations is applied in order; never edit an entry once released, add a new one instead
+var migrations = []migration{
+	{
+		version: 1,
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS bookings (
+				id         TEXT PRIMARY KEY,
+				user_id    TEXT NOT NULL,
+				service_id TEXT NOT NULL,
+				price      DOUBLE PRECISION NOT NULL,
+				status     TEXT NOT NULL,
+				created_at TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_bookings_status ON bookings (status)`,
+		},
+	},
+	{
+		version: 2,
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS jobs (
+				id           TEXT PRIMARY KEY,
+				type         TEXT NOT NULL,
+				payload      TEXT NOT NULL,
+				status       TEXT NOT NULL,
+				attempts     INTEGER NOT NULL,
+				max_attempts INTEGER NOT NULL,
+				last_error   TEXT NOT NULL,
+				run_at       TIMESTAMP NOT NULL,
+				created_at   TIMESTAMP NOT NULL,
+				updated_at   TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs (status)`,
+		},
+	},
+	{
+		version: 3,
+		statements: []string{
+			`CREATE INDEX IF NOT EXISTS idx_bookings_user_id ON bookings (user_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_bookings_service_id ON bookings (service_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_bookings_created_at ON bookings (created_at)`,
+		},
+	},
+	{
+		version: 4,
+		statements: []string{
+			`ALTER TABLE bookings ADD COLUMN version BIGINT NOT NULL DEFAULT 1`,
+		},
+	},
+	{
+		version: 5,
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS booking_status_history (
+				booking_id  TEXT NOT NULL,
+				version     BIGINT NOT NULL,
+				from_status TEXT NOT NULL,
+				to_status   TEXT NOT NULL,
+				actor       TEXT NOT NULL,
+				reason      TEXT NOT NULL,
+				changed_at  TIMESTAMP NOT NULL,
+				PRIMARY KEY (booking_id, version)
+			)`,
+		},
+	},
+	{
+		version: 6,
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS booking_events (
+				booking_id  TEXT NOT NULL,
+				version     BIGINT NOT NULL,
+				type        TEXT NOT NULL,
+				actor       TEXT NOT NULL,
+				reason      TEXT NOT NULL,
+				data        TEXT NOT NULL,
+				occurred_at TIMESTAMP NOT NULL,
+				PRIMARY KEY (booking_id, version)
+			)`,
+			`CREATE TABLE IF NOT EXISTS booking_snapshots (
+				booking_id TEXT PRIMARY KEY,
+				version    BIGINT NOT NULL,
+				data       TEXT NOT NULL
+			)`,
+		},
+	},
+	{
+		version: 7,
+		statements: []string{
+			// next_attempt_at is Unix milliseconds so due checks compare
+			// numerically on every driver
+			`CREATE TABLE IF NOT EXISTS outbox (
+				id              TEXT PRIMARY KEY,
+				type            TEXT NOT NULL,
+				booking_id      TEXT NOT NULL,
+				payload         TEXT NOT NULL,
+				attempts        INTEGER NOT NULL DEFAULT 0,
+				last_error      TEXT NOT NULL DEFAULT '',
+				next_attempt_at BIGINT NOT NULL,
+				published_at    TIMESTAMP
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox (published_at, next_attempt_at)`,
+		},
+	},
+	{
+		version: 8,
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+				id          TEXT PRIMARY KEY,
+				user_id     TEXT NOT NULL,
+				url         TEXT NOT NULL,
+				event_types TEXT NOT NULL,
+				secret      TEXT NOT NULL,
+				active      BOOLEAN NOT NULL,
+				created_at  TIMESTAMP NOT NULL
+			)`,
+			`CREATE TABLE IF NOT EXISTS webhook_deliveries (
+				id               TEXT PRIMARY KEY,
+				subscription_id  TEXT NOT NULL,
+				event_id         TEXT NOT NULL,
+				event_type       TEXT NOT NULL,
+				booking_id       TEXT NOT NULL,
+				payload          TEXT NOT NULL,
+				status           TEXT NOT NULL,
+				attempts         INTEGER NOT NULL,
+				last_status_code INTEGER NOT NULL,
+				last_error       TEXT NOT NULL,
+				created_at       TIMESTAMP NOT NULL,
+				updated_at       TIMESTAMP NOT NULL,
+				delivered_at     TIMESTAMP,
+				UNIQUE (subscription_id, event_id)
+			)`,
+		},
+	},
+	{
+		version: 9,
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS services (
+				id           TEXT PRIMARY KEY,
+				name         TEXT NOT NULL,
+				base_price   DOUBLE PRECISION NOT NULL,
+				active       BOOLEAN NOT NULL,
+				credit_check TEXT NOT NULL,
+				created_at   TIMESTAMP NOT NULL,
+				updated_at   TIMESTAMP NOT NULL
+			)`,
+		},
+	},
+	{
+		version: 10,
+		statements: []string{
+			`ALTER TABLE bookings ADD COLUMN quantity INTEGER NOT NULL DEFAULT 1`,
+			// JSON, NULL for bookings priced before the service catalog
+			`ALTER TABLE bookings ADD COLUMN price_breakdown TEXT`,
+		},
+	},
+	{
+		// Prices become integer minor units plus a currency. Existing rows were
+		// priced in models.DefaultCurrency, which has two decimal places.
+		version: 11,
+		statements: []string{
+			`ALTER TABLE bookings ADD COLUMN price_minor BIGINT NOT NULL DEFAULT 0`,
+			`ALTER TABLE bookings ADD COLUMN currency TEXT NOT NULL DEFAULT 'USD'`,
+			`UPDATE bookings SET price_minor = CAST(ROUND(price * 100) AS BIGINT)`,
+			`ALTER TABLE bookings DROP COLUMN price`,
+			`ALTER TABLE bookings RENAME COLUMN price_minor TO price`,
+			`ALTER TABLE services ADD COLUMN base_price_minor BIGINT NOT NULL DEFAULT 0`,
+			`ALTER TABLE services ADD COLUMN currency TEXT NOT NULL DEFAULT 'USD'`,
+			`UPDATE services SET base_price_minor = CAST(ROUND(base_price * 100) AS BIGINT)`,
+			`ALTER TABLE services DROP COLUMN base_price`,
+			`ALTER TABLE services RENAME COLUMN base_price_minor TO base_price`,
+		},
+	},
+	{
+		version: 12,
+		statements: []string{
+			// Finds the older unpublished events of a booking, see PendingEvents
+			`CREATE INDEX IF NOT EXISTS idx_outbox_booking ON outbox (booking_id, id)`,
+		},
+	},
+	{
+		version: 13,
+		statements: []string{
+			// The current state of every event-sourced booking, with the
+			// columns of bookings so listings filter and page in SQL.
+			// SQLEventStore fills it for streams written before this version.
+			`CREATE TABLE IF NOT EXISTS booking_projections (
+				id              TEXT PRIMARY KEY,
+				user_id         TEXT NOT NULL,
+				service_id      TEXT NOT NULL,
+				quantity        INTEGER NOT NULL,
+				price           BIGINT NOT NULL,
+				currency        TEXT NOT NULL,
+				status          TEXT NOT NULL,
+				created_at      TIMESTAMP NOT NULL,
+				version         BIGINT NOT NULL,
+				price_breakdown TEXT
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_booking_projections_status ON booking_projections (status)`,
+			`CREATE INDEX IF NOT EXISTS idx_booking_projections_user_id ON booking_projections (user_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_booking_projections_service_id ON booking_projections (service_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_booking_projections_created_at ON booking_projections (created_at)`,
+		},
+	},
+}
+
+// Migrate brings the database schema up to the latest version
+func Migrate(db *sql.DB) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY
+	)`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	var current int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := applyMigration(db, m); err != nil {
+			return fmt.Errorf("apply migration %d: %w", m.version, err)
+		}
+	}
+	return nil
+}
+
+func applyMigration(db *sql.DB, m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, statement := range m.statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES ($1)`, m.version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
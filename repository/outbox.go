@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/touchsung/spd-fiber-booking-system/models"
+)
+
+// ErrOutboxMessageNotFound is returned when marking an event the outbox does not hold
+var ErrOutboxMessageNotFound = errors.New("outbox message not found")
+
+// Outbox holds domain events that were written together with the booking
+// change that raised them until they have been published. Repositories fill it
+// through the events passed to BookingRepository.UpdateBookingStatus.
+type Outbox interface {
+	// PendingEvents returns up to limit unpublished messages due by now, oldest
+	// first. A booking's messages are held back while an older one of the same
+	// booking waits for a retry, so each booking's events are published in order.
+	PendingEvents(now time.Time, limit int) ([]models.OutboxMessage, error)
+	// MarkPublished removes the event from the pending messages
+	MarkPublished(eventID string, at time.Time) error
+	// MarkFailed records a failed attempt and defers the next one until retryAt
+	MarkFailed(eventID string, reason string, retryAt time.Time) error
+}
+
+// Ensure memoryOutbox satisfies Outbox
+var _ Outbox = (*memoryOutbox)(nil)
+
+// memoryOutbox is the outbox of the in-memory repositories. Published
+// messages are dropped.
+type memoryOutbox struct {
+	outboxMutex sync.Mutex
+	messages    []models.OutboxMessage
+}
+
+func newMemoryOutbox() *memoryOutbox {
+	return &memoryOutbox{}
+}
+
+// add queues events for publishing
+func (o *memoryOutbox) add(events []models.DomainEvent) {
+	if len(events) == 0 {
+		return
+	}
+	o.outboxMutex.Lock()
+	defer o.outboxMutex.Unlock()
+
+	for _, event := range events {
+		event.Booking = *event.Booking.Clone()
+		o.messages = append(o.messages, models.OutboxMessage{Event: event, NextAttemptAt: event.OccurredAt})
+	}
+}
+
+func (o *memoryOutbox) PendingEvents(now time.Time, limit int) ([]models.OutboxMessage, error) {
+	o.outboxMutex.Lock()
+	defer o.outboxMutex.Unlock()
+
+	pending := make([]models.OutboxMessage, 0, min(limit, len(o.messages)))
+	waiting := make(map[string]bool) // bookings with a message that is not due yet
+	for _, message := range o.messages {
+		if len(pending) == limit {
+			break
+		}
+		bookingID := message.Event.BookingID
+		switch {
+		case waiting[bookingID]:
+		case message.NextAttemptAt.After(now):
+			waiting[bookingID] = true
+		default:
+			message.Event.Booking = *message.Event.Booking.Clone()
+			pending = append(pending, message)
+		}
+	}
+	return pending, nil
+}
+
+func (o *memoryOutbox) MarkPublished(eventID string, at time.Time) error {
+	o.outboxMutex.Lock()
+	defer o.outboxMutex.Unlock()
+
+	i := o.indexOf(eventID)
+	if i < 0 {
+		return ErrOutboxMessageNotFound
+	}
+	o.messages = slices.Delete(o.messages, i, i+1)
+	return nil
+}
+
+func (o *memoryOutbox) MarkFailed(eventID string, reason string, retryAt time.Time) error {
+	o.outboxMutex.Lock()
+	defer o.outboxMutex.Unlock()
+
+	i := o.indexOf(eventID)
+	if i < 0 {
+		return ErrOutboxMessageNotFound
+	}
+	o.messages[i].Attempts++
+	o.messages[i].LastError = reason
+	o.messages[i].NextAttemptAt = retryAt
+	return nil
+}
+
+func (o *memoryOutbox) indexOf(eventID string) int {
+	return slices.IndexFunc(o.messages, func(message models.OutboxMessage) bool {
+		return message.Event.ID == eventID
+	})
+}
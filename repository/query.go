@@ -0,0 +1,257 @@
+package repository
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/touchsung/spd-fiber-booking-system/models"
+)
+
+var (
+	// ErrInvalidCursor is returned when a cursor is malformed or was issued for a different sort order
+	ErrInvalidCursor = errors.New("invalid pagination cursor")
+	// ErrInvalidSort is returned when a query sorts by an unknown field
+	ErrInvalidSort = errors.New("invalid sort field")
+)
+
+// sortColumn is one key of a keyset ordering; field is the SQL column name
+type sortColumn struct {
+	field string
+	desc  bool
+}
+
+// sortColumnsFor translates the query's sort keys into an ordering that
+// always ends with id, so every booking has a unique position for cursors
+func sortColumnsFor(query models.BookingQuery) ([]sortColumn, error) {
+	columns := make([]sortColumn, 0, len(query.Sort)+1)
+	for _, key := range query.Sort {
+		if !key.Field.Valid() {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidSort, key.Field)
+		}
+		columns = append(columns, sortColumn{field: string(key.Field), desc: key.Desc})
+		if key.Field == models.SortByID {
+			// id is unique, so any later key could never break a tie
+			return columns, nil
+		}
+	}
+	return append(columns, sortColumn{field: "id"}), nil
+}
+
+func signature(columns []sortColumn) string {
+	parts := make([]string, len(columns))
+	for i, column := range columns {
+		parts[i] = column.field
+		if column.desc {
+			parts[i] = "-" + parts[i]
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// cursor is the decoded form of an opaque pagination cursor: the sort key
+// values of the last booking on the previous page
+type cursor struct {
+	Signature string            `json:"s"`
+	Values    []json.RawMessage `json:"v"`
+}
+
+func encodeCursor(columns []sortColumn, booking *models.Booking) string {
+	c := cursor{Signature: signature(columns)}
+	for _, column := range columns {
+		raw, _ := json.Marshal(bookingValue(booking, column.field))
+		c.Values = append(c.Values, raw)
+	}
+	encoded, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+// decodeCursor returns the typed sort key values stored in raw
+func decodeCursor(raw string, columns []sortColumn) ([]any, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(decoded, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if c.Signature != signature(columns) || len(c.Values) != len(columns) {
+		return nil, ErrInvalidCursor
+	}
+
+	values := make([]any, len(columns))
+	for i, column := range columns {
+		var err error
+		switch column.field {
+		case "price":
+			var price int64
+			err = json.Unmarshal(c.Values[i], &price)
+			values[i] = price
+		case "created_at":
+			var createdAt time.Time
+			err = json.Unmarshal(c.Values[i], &createdAt)
+			values[i] = createdAt
+		default:
+			var text string
+			err = json.Unmarshal(c.Values[i], &text)
+			values[i] = text
+		}
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+	return values, nil
+}
+
+// bookingValue returns the value of a sortable column
+func bookingValue(booking *models.Booking, field string) any {
+	switch field {
+	case "price":
+		return booking.Price.Amount
+	case "created_at":
+		return booking.CreatedAt.UTC()
+	case "status":
+		return string(booking.Status)
+	case "user_id":
+		return booking.UserID
+	case "service_id":
+		return booking.ServiceID
+	default:
+		return booking.ID
+	}
+}
+
+func bookingValues(booking *models.Booking, columns []sortColumn) []any {
+	values := make([]any, len(columns))
+	for i, column := range columns {
+		values[i] = bookingValue(booking, column.field)
+	}
+	return values
+}
+
+// compareKeys orders two sets of sort key values according to columns
+func compareKeys(columns []sortColumn, a, b []any) int {
+	for i, column := range columns {
+		result := compareValues(column.field, a[i], b[i])
+		if column.desc {
+			result = -result
+		}
+		if result != 0 {
+			return result
+		}
+	}
+	return 0
+}
+
+func compareValues(field string, a, b any) int {
+	switch a := a.(type) {
+	case int64:
+		return cmp.Compare(a, b.(int64))
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	case string:
+		if field == "id" {
+			return compareIDs(a, b.(string))
+		}
+		return strings.Compare(a, b.(string))
+	}
+	return 0
+}
+
+// compareIDs orders legacy numeric IDs numerically and everything else
+// lexicographically, which for ULIDs is creation order
+func compareIDs(a, b string) int {
+	id1, err1 := strconv.Atoi(a)
+	id2, err2 := strconv.Atoi(b)
+	if err1 != nil || err2 != nil {
+		// Fallback to string comparison if conversion fails
+		return strings.Compare(a, b)
+	}
+	return cmp.Compare(id1, id2)
+}
+
+func matchesFilter(booking *models.Booking, filter models.BookingFilter) bool {
+	switch {
+	case filter.UserID != "" && booking.UserID != filter.UserID:
+		return false
+	case filter.ServiceID != "" && booking.ServiceID != filter.ServiceID:
+		return false
+	case len(filter.Statuses) > 0 && !slices.Contains(filter.Statuses, booking.Status):
+		return false
+	case filter.MinPrice != nil && !priceMatches(booking.Price, *filter.MinPrice, 0, 1):
+		return false
+	case filter.MaxPrice != nil && !priceMatches(booking.Price, *filter.MaxPrice, -1, 0):
+		return false
+	case filter.PriceAbove != nil && !priceMatches(booking.Price, *filter.PriceAbove, 1):
+		return false
+	case filter.CreatedFrom != nil && booking.CreatedAt.Before(*filter.CreatedFrom):
+		return false
+	case filter.CreatedBefore != nil && !booking.CreatedAt.Before(*filter.CreatedBefore):
+		return false
+	}
+	return true
+}
+
+// queryBookings applies a query to an in-memory set of bookings
+func queryBookings(bookings []*models.Booking, query models.BookingQuery) (*models.BookingPage, error) {
+	columns, err := sortColumnsFor(query)
+	if err != nil {
+		return nil, err
+	}
+	var after []any
+	if query.Cursor != "" {
+		if after, err = decodeCursor(query.Cursor, columns); err != nil {
+			return nil, err
+		}
+	}
+
+	matched := make([]*models.Booking, 0, len(bookings))
+	for _, booking := range bookings {
+		if matchesFilter(booking, query.Filter) {
+			matched = append(matched, booking)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return compareKeys(columns, bookingValues(matched[i], columns), bookingValues(matched[j], columns)) < 0
+	})
+
+	total := len(matched)
+	start := min(query.Offset, total)
+	if after != nil {
+		start = sort.Search(total, func(i int) bool {
+			return compareKeys(columns, bookingValues(matched[i], columns), after) > 0
+		})
+	}
+	end := total
+	if query.Limit > 0 {
+		end = min(start+query.Limit, total)
+	}
+
+	page := &models.BookingPage{
+		Data:  matched[start:end],
+		Total: total,
+		Limit: query.Limit,
+	}
+	if after == nil {
+		page.Offset = start
+	}
+	if end < total && end > start {
+		page.NextCursor = encodeCursor(columns, matched[end-1])
+	}
+	return page, nil
+}
+
+// priceMatches reports whether price compares to bound with one of the
+// accepted results; prices in another currency never match
+func priceMatches(price, bound models.Money, accepted ...int) bool {
+	result, err := price.Cmp(bound)
+	return err == nil && slices.Contains(accepted, result)
+}
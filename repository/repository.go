@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/touchsung/spd-fiber-booking-system/models"
+)
+
+var (
+	// ErrBookingNotFound is returned when a booking does not exist in the repository
+	ErrBookingNotFound = errors.New("booking not found")
+	// ErrVersionConflict is returned when a compare-and-swap update finds a different version
+	ErrVersionConflict = errors.New("booking version conflict")
+)
+
+// BookingRepository is the persistence boundary used by the booking usecase
+type BookingRepository interface {
+	GetBooking(bookingID string) (*models.Booking, error)
+	// ListBookings returns the page of bookings selected by query
+	ListBookings(query models.BookingQuery) (*models.BookingPage, error)
+	SaveBooking(booking *models.Booking) error
+	// UpdateBookingStatus applies change.To and increments the version, but only
+	// while the stored version still equals expectedVersion. The change is
+	// appended to the booking's status history and events are added to the
+	// outbox in the same step.
+	UpdateBookingStatus(change models.StatusChange, expectedVersion int64, events ...models.DomainEvent) error
+	// GetStatusHistory returns the booking's status changes, oldest first
+	GetStatusHistory(bookingID string) ([]models.StatusChange, error)
+	DeleteBooking(bookingID string) error
+}
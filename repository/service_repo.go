@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/touchsung/spd-fiber-booking-system/models"
+)
+
+var (
+	// ErrServiceNotFound is returned when a catalog service does not exist
+	ErrServiceNotFound = errors.New("service not found")
+	// ErrServiceExists is returned when creating a service with an ID that is taken
+	ErrServiceExists = errors.New("service already exists")
+)
+
+// ServiceRepository persists the service catalog
+type ServiceRepository interface {
+	// CreateService stores a new service, failing with ErrServiceExists when its ID is taken
+	CreateService(service *models.Service) error
+	UpdateService(service *models.Service) error
+	GetService(serviceID string) (*models.Service, error)
+	// ListServices returns services ordered by ID, only the active ones when activeOnly is set
+	ListServices(activeOnly bool) ([]*models.Service, error)
+	DeleteService(serviceID string) error
+}
+
+// Ensure MockServiceRepository satisfies ServiceRepository
+var _ ServiceRepository = (*MockServiceRepository)(nil)
+
+// MockServiceRepository keeps the catalog in memory, seeded with the services
+// the default mock bookings refer to
+type MockServiceRepository struct {
+	mutex    sync.RWMutex
+	services map[string]models.Service
+}
+
+func NewMockServiceRepository() *MockServiceRepository {
+	mockRepo := &MockServiceRepository{services: make(map[string]models.Service)}
+
+	createdAt := time.Now().Add(-48 * time.Hour)
+	for i := 1; i <= 10; i++ {
+		id := fmt.Sprintf("service%d", i)
+		mockRepo.services[id] = models.Service{
+			ID:          id,
+			Name:        fmt.Sprintf("Service %d", i),
+			BasePrice:   models.NewMoney(int64(i)*1000000, models.DefaultCurrency),
+			Active:      true,
+			CreditCheck: models.CreditCheckAboveThreshold,
+			CreatedAt:   createdAt,
+			UpdatedAt:   createdAt,
+		}
+	}
+
+	return mockRepo
+}
+
+// ClearServices removes every service, including the seeded ones
+func (m *MockServiceRepository) ClearServices() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.services = make(map[string]models.Service)
+}
+
+func (m *MockServiceRepository) CreateService(service *models.Service) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, exists := m.services[service.ID]; exists {
+		return ErrServiceExists
+	}
+	m.services[service.ID] = *service
+	return nil
+}
+
+func (m *MockServiceRepository) UpdateService(service *models.Service) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, exists := m.services[service.ID]; !exists {
+		return ErrServiceNotFound
+	}
+	m.services[service.ID] = *service
+	return nil
+}
+
+func (m *MockServiceRepository) GetService(serviceID string) (*models.Service, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	service, exists := m.services[serviceID]
+	if !exists {
+		return nil, ErrServiceNotFound
+	}
+	return &service, nil
+}
+
+func (m *MockServiceRepository) ListServices(activeOnly bool) ([]*models.Service, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	services := make([]*models.Service, 0, len(m.services))
+	for _, service := range m.services {
+		if activeOnly && !service.Active {
+			continue
+		}
+		services = append(services, &service)
+	}
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].ID < services[j].ID
+	})
+	return services, nil
+}
+
+func (m *MockServiceRepository) DeleteService(serviceID string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, exists := m.services[serviceID]; !exists {
+		return ErrServiceNotFound
+	}
+	delete(m.services, serviceID)
+	return nil
+}
@@ -0,0 +1,262 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/touchsung/spd-fiber-booking-system/models"
+)
+
+// Ensure SQLEventStore satisfies EventStore and BookingLister
+var (
+	_ EventStore    = (*SQLEventStore)(nil)
+	_ BookingLister = (*SQLEventStore)(nil)
+)
+
+// SQLEventStore keeps event streams in the booking_events table, snapshots in
+// booking_snapshots and the current state of every booking, for listings, in
+// booking_projections
+type SQLEventStore struct {
+	db *sql.DB
+	sqlOutbox
+}
+
+// NewSQLEventStore runs pending migrations and returns an event store backed by db
+func NewSQLEventStore(db *sql.DB) (*SQLEventStore, error) {
+	if err := Migrate(db); err != nil {
+		return nil, err
+	}
+	store := &SQLEventStore{db: db, sqlOutbox: sqlOutbox{db: db}}
+	if err := store.projectMissingStreams(); err != nil {
+		return nil, fmt.Errorf("project event streams: %w", err)
+	}
+	return store, nil
+}
+
+const eventColumns = `booking_id, version, type, actor, reason, data, occurred_at`
+
+func (s *SQLEventStore) Append(bookingID string, expectedVersion int64, events []models.BookingEvent, outbox ...models.DomainEvent) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var current int64
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM booking_events WHERE booking_id = $1`,
+		bookingID).Scan(&current); err != nil {
+		return err
+	}
+	if current != expectedVersion {
+		return ErrVersionConflict
+	}
+
+	for _, event := range events {
+		data := []byte("{}")
+		if event.Booking != nil {
+			if data, err = json.Marshal(event.Booking); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.Exec(`INSERT INTO booking_events (`+eventColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			bookingID, event.Version, string(event.Type), string(event.Actor), event.Reason, string(data),
+			event.OccurredAt.UTC()); err != nil {
+			// A concurrent writer claimed the same version first
+			if latest, _ := s.lastVersion(bookingID); latest != expectedVersion {
+				return ErrVersionConflict
+			}
+			return err
+		}
+	}
+	if err := project(tx, bookingID, events); err != nil {
+		return err
+	}
+	if err := insertOutboxEvents(tx, outbox); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// project applies events to the booking's row in booking_projections
+func project(tx *sql.Tx, bookingID string, events []models.BookingEvent) error {
+	booking, err := scanBooking(tx.QueryRow(`SELECT `+bookingColumns+` FROM booking_projections WHERE id = $1`, bookingID))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	for _, event := range events {
+		booking = applyEvent(booking, event)
+	}
+	if booking == nil {
+		return nil
+	}
+	return upsertBooking(tx, "booking_projections", booking)
+}
+
+// projectMissingStreams fills booking_projections for the streams written
+// before it existed
+func (s *SQLEventStore) projectMissingStreams() error {
+	rows, err := s.db.Query(`SELECT booking_id FROM booking_events e WHERE version = 1
+		AND NOT EXISTS (SELECT 1 FROM booking_projections p WHERE p.id = e.booking_id)`)
+	if err != nil {
+		return err
+	}
+	ids := make([]string, 0)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		events, err := s.Events(id, 0)
+		if err != nil {
+			return err
+		}
+		var booking *models.Booking
+		for _, event := range events {
+			booking = applyEvent(booking, event)
+		}
+		if booking == nil {
+			continue
+		}
+		args, err := bookingArgs(booking)
+		if err != nil {
+			return err
+		}
+		// A concurrent Append has already projected a newer state
+		if _, err := s.db.Exec(`INSERT INTO booking_projections (`+bookingColumns+`)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) ON CONFLICT (id) DO NOTHING`, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListBookings filters, orders and pages the projections in SQL
+func (s *SQLEventStore) ListBookings(query models.BookingQuery) (*models.BookingPage, error) {
+	return listBookings(s.db, "booking_projections", query)
+}
+
+func (s *SQLEventStore) lastVersion(bookingID string) (int64, error) {
+	var version int64
+	err := s.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM booking_events WHERE booking_id = $1`,
+		bookingID).Scan(&version)
+	return version, err
+}
+
+func (s *SQLEventStore) Events(bookingID string, afterVersion int64) ([]models.BookingEvent, error) {
+	rows, err := s.db.Query(`SELECT `+eventColumns+` FROM booking_events
+		WHERE booking_id = $1 AND version > $2 ORDER BY version`, bookingID, afterVersion)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]models.BookingEvent, 0)
+	for rows.Next() {
+		var (
+			event      models.BookingEvent
+			eventType  string
+			actor      string
+			data       string
+			occurredAt time.Time
+		)
+		if err := rows.Scan(&event.BookingID, &event.Version, &eventType, &actor, &event.Reason, &data, &occurredAt); err != nil {
+			return nil, err
+		}
+		event.Type = models.BookingEventType(eventType)
+		event.Actor = models.Actor(actor)
+		event.OccurredAt = occurredAt.Local()
+		if event.Type == models.EventCreated {
+			event.Booking = &models.Booking{}
+			if err := json.Unmarshal([]byte(data), event.Booking); err != nil {
+				return nil, err
+			}
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+func (s *SQLEventStore) StreamVersions() (map[string]int64, error) {
+	rows, err := s.db.Query(`SELECT booking_id, MAX(version) FROM booking_events GROUP BY booking_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := make(map[string]int64)
+	for rows.Next() {
+		var (
+			id      string
+			version int64
+		)
+		if err := rows.Scan(&id, &version); err != nil {
+			return nil, err
+		}
+		versions[id] = version
+	}
+	return versions, rows.Err()
+}
+
+func (s *SQLEventStore) SaveSnapshot(booking *models.Booking) error {
+	data, err := json.Marshal(booking)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO booking_snapshots (booking_id, version, data) VALUES ($1, $2, $3)
+		ON CONFLICT (booking_id) DO UPDATE SET
+			version = EXCLUDED.version,
+			data = EXCLUDED.data
+		WHERE booking_snapshots.version < EXCLUDED.version`,
+		booking.ID, booking.Version, string(data))
+	return err
+}
+
+func (s *SQLEventStore) Snapshot(bookingID string) (*models.Booking, error) {
+	var data string
+	err := s.db.QueryRow(`SELECT data FROM booking_snapshots WHERE booking_id = $1`, bookingID).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var booking models.Booking
+	if err := json.Unmarshal([]byte(data), &booking); err != nil {
+		return nil, err
+	}
+	return &booking, nil
+}
+
+func (s *SQLEventStore) Snapshots() ([]*models.Booking, error) {
+	rows, err := s.db.Query(`SELECT data FROM booking_snapshots`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshots := make([]*models.Booking, 0)
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var booking models.Booking
+		if err := json.Unmarshal([]byte(data), &booking); err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, &booking)
+	}
+	return snapshots, rows.Err()
+}
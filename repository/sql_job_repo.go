@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/touchsung/spd-fiber-booking-system/models"
+)
+
+// Ensure SQLJobRepository satisfies JobRepository
+var _ JobRepository = (*SQLJobRepository)(nil)
+
+// SQLJobRepository stores background jobs in the jobs table
+type SQLJobRepository struct {
+	db *sql.DB
+}
+
+// NewSQLJobRepository runs pending migrations and returns a job repository backed by db
+func NewSQLJobRepository(db *sql.DB) (*SQLJobRepository, error) {
+	if err := Migrate(db); err != nil {
+		return nil, err
+	}
+	return &SQLJobRepository{db: db}, nil
+}
+
+const jobColumns = `id, type, payload, status, attempts, max_attempts, last_error, run_at, created_at, updated_at`
+
+func (r *SQLJobRepository) SaveJob(job *models.Job) error {
+	_, err := r.db.Exec(`INSERT INTO jobs (`+jobColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (id) DO UPDATE SET
+			status = EXCLUDED.status,
+			attempts = EXCLUDED.attempts,
+			max_attempts = EXCLUDED.max_attempts,
+			last_error = EXCLUDED.last_error,
+			run_at = EXCLUDED.run_at,
+			updated_at = EXCLUDED.updated_at`,
+		job.ID, string(job.Type), job.Payload, string(job.Status), job.Attempts, job.MaxAttempts, job.LastError,
+		job.RunAt.UTC(), job.CreatedAt.UTC(), job.UpdatedAt.UTC())
+	return err
+}
+
+func (r *SQLJobRepository) GetJob(jobID string) (*models.Job, error) {
+	row := r.db.QueryRow(`SELECT `+jobColumns+` FROM jobs WHERE id = $1`, jobID)
+	job, err := scanJob(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrJobNotFound
+	}
+	return job, err
+}
+
+func (r *SQLJobRepository) ListJobs(statuses ...models.JobStatus) ([]*models.Job, error) {
+	query := `SELECT ` + jobColumns + ` FROM jobs`
+	args := make([]any, 0, len(statuses))
+	if len(statuses) > 0 {
+		placeholders := make([]string, len(statuses))
+		for i, status := range statuses {
+			placeholders[i] = fmt.Sprintf("$%d", i+1)
+			args = append(args, string(status))
+		}
+		query += ` WHERE status IN (` + strings.Join(placeholders, ", ") + `)`
+	}
+	query += ` ORDER BY created_at, id`
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := make([]*models.Job, 0)
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+func scanJob(row rowScanner) (*models.Job, error) {
+	var (
+		job                         models.Job
+		jobType, status             string
+		runAt, createdAt, updatedAt time.Time
+	)
+	if err := row.Scan(&job.ID, &jobType, &job.Payload, &status, &job.Attempts, &job.MaxAttempts, &job.LastError,
+		&runAt, &createdAt, &updatedAt); err != nil {
+		return nil, err
+	}
+	job.Type = models.JobType(jobType)
+	job.Status = models.JobStatus(status)
+	job.RunAt = runAt.Local()
+	job.CreatedAt = createdAt.Local()
+	job.UpdatedAt = updatedAt.Local()
+	return &job, nil
+}
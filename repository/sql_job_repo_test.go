@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/touchsung/spd-fiber-booking-system/models"
+)
+
+func TestSQLJobRepository(t *testing.T) {
+	jobs, err := NewSQLJobRepository(setupSQLRepository(t).db)
+	require.NoError(t, err)
+
+	now := time.Now().Truncate(time.Microsecond)
+	queued := &models.Job{ID: "job-1", Type: models.JobTypeCreditCheck, Payload: "booking-1", Status: models.JobStatusQueued,
+		MaxAttempts: 5, RunAt: now, CreatedAt: now, UpdatedAt: now}
+	dead := &models.Job{ID: "job-2", Type: models.JobTypeCreditCheck, Payload: "booking-2", Status: models.JobStatusDead,
+		Attempts: 5, MaxAttempts: 5, LastError: "timeout", RunAt: now, CreatedAt: now.Add(time.Second), UpdatedAt: now}
+	require.NoError(t, jobs.SaveJob(queued))
+	require.NoError(t, jobs.SaveJob(dead))
+
+	// Saving again updates the mutable fields
+	queued.Status = models.JobStatusRunning
+	queued.Attempts = 1
+	require.NoError(t, jobs.SaveJob(queued))
+
+	found, err := jobs.GetJob(queued.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobStatusRunning, found.Status)
+	assert.Equal(t, 1, found.Attempts)
+	assert.Equal(t, "booking-1", found.Payload)
+
+	all, err := jobs.ListJobs()
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+	assert.Equal(t, queued.ID, all[0].ID, "Expected oldest job first")
+
+	deadJobs, err := jobs.ListJobs(models.JobStatusDead, models.JobStatusQueued)
+	require.NoError(t, err)
+	require.Len(t, deadJobs, 1)
+	assert.Equal(t, "timeout", deadJobs[0].LastError)
+
+	_, err = jobs.GetJob("missing")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/touchsung/spd-fiber-booking-system/models"
+)
+
+// Ensure sqlOutbox satisfies Outbox
+var _ Outbox = (*sqlOutbox)(nil)
+
+// sqlOutbox is the outbox table shared by the SQL repositories. Published
+// messages are kept with their published_at time.
+type sqlOutbox struct {
+	db *sql.DB
+}
+
+// insertOutboxEvents adds events to the outbox as part of tx
+func insertOutboxEvents(tx *sql.Tx, events []models.DomainEvent) error {
+	for _, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`INSERT INTO outbox (id, type, booking_id, payload, next_attempt_at) VALUES ($1, $2, $3, $4, $5)`,
+			event.ID, string(event.Type), event.BookingID, string(payload), event.OccurredAt.UnixMilli()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *sqlOutbox) PendingEvents(now time.Time, limit int) ([]models.OutboxMessage, error) {
+	// IDs are ULIDs, so ordering by ID is ordering by creation
+	rows, err := o.db.Query(`SELECT payload, attempts, last_error, next_attempt_at FROM outbox message
+		WHERE published_at IS NULL AND next_attempt_at <= $1
+		AND NOT EXISTS (SELECT 1 FROM outbox earlier WHERE earlier.booking_id = message.booking_id
+			AND earlier.id < message.id AND earlier.published_at IS NULL AND earlier.next_attempt_at > $1)
+		ORDER BY id LIMIT $2`, now.UnixMilli(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := make([]models.OutboxMessage, 0)
+	for rows.Next() {
+		var (
+			message       models.OutboxMessage
+			payload       string
+			nextAttemptAt int64
+		)
+		if err := rows.Scan(&payload, &message.Attempts, &message.LastError, &nextAttemptAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(payload), &message.Event); err != nil {
+			return nil, err
+		}
+		message.NextAttemptAt = time.UnixMilli(nextAttemptAt)
+		messages = append(messages, message)
+	}
+	return messages, rows.Err()
+}
+
+func (o *sqlOutbox) MarkPublished(eventID string, at time.Time) error {
+	result, err := o.db.Exec(`UPDATE outbox SET published_at = $1 WHERE id = $2`, at.UTC(), eventID)
+	if err != nil {
+		return err
+	}
+	return outboxAffected(result)
+}
+
+func (o *sqlOutbox) MarkFailed(eventID string, reason string, retryAt time.Time) error {
+	result, err := o.db.Exec(`UPDATE outbox SET attempts = attempts + 1, last_error = $1, next_attempt_at = $2 WHERE id = $3`,
+		reason, retryAt.UnixMilli(), eventID)
+	if err != nil {
+		return err
+	}
+	return outboxAffected(result)
+}
+
+func outboxAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrOutboxMessageNotFound
+	}
+	return nil
+}
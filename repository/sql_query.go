@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/touchsung/spd-fiber-booking-system/models"
+)
+
+// sqlArgs collects query arguments and hands out matching $n placeholders
+type sqlArgs struct {
+	values []any
+}
+
+func (a *sqlArgs) add(value any) string {
+	a.values = append(a.values, value)
+	return fmt.Sprintf("$%d", len(a.values))
+}
+
+func filterConditions(filter models.BookingFilter, args *sqlArgs) []string {
+	conditions := make([]string, 0)
+	if filter.UserID != "" {
+		conditions = append(conditions, `user_id = `+args.add(filter.UserID))
+	}
+	if filter.ServiceID != "" {
+		conditions = append(conditions, `service_id = `+args.add(filter.ServiceID))
+	}
+	if len(filter.Statuses) > 0 {
+		placeholders := make([]string, len(filter.Statuses))
+		for i, status := range filter.Statuses {
+			placeholders[i] = args.add(string(status))
+		}
+		conditions = append(conditions, `status IN (`+strings.Join(placeholders, ", ")+`)`)
+	}
+	if filter.MinPrice != nil {
+		conditions = append(conditions, priceCondition(`>=`, *filter.MinPrice, args))
+	}
+	if filter.MaxPrice != nil {
+		conditions = append(conditions, priceCondition(`<=`, *filter.MaxPrice, args))
+	}
+	if filter.PriceAbove != nil {
+		conditions = append(conditions, priceCondition(`>`, *filter.PriceAbove, args))
+	}
+	if filter.CreatedFrom != nil {
+		conditions = append(conditions, `created_at >= `+args.add(filter.CreatedFrom.UTC()))
+	}
+	if filter.CreatedBefore != nil {
+		conditions = append(conditions, `created_at < `+args.add(filter.CreatedBefore.UTC()))
+	}
+	return conditions
+}
+
+// priceCondition compares price with bound; bookings in another currency never match
+func priceCondition(operator string, bound models.Money, args *sqlArgs) string {
+	return `(currency = ` + args.add(bound.Currency) + ` AND price ` + operator + ` ` + args.add(bound.Amount) + `)`
+}
+
+// keysetCondition selects rows that sort strictly after the cursor values:
+// (c1 > v1) OR (c1 = v1 AND c2 > v2) OR ..., with < for descending columns
+func keysetCondition(columns []sortColumn, after []any, args *sqlArgs) string {
+	alternatives := make([]string, 0, len(columns))
+	for i, column := range columns {
+		terms := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			terms = append(terms, columns[j].field+` = `+args.add(after[j]))
+		}
+		operator := ` > `
+		if column.desc {
+			operator = ` < `
+		}
+		terms = append(terms, column.field+operator+args.add(after[i]))
+		alternatives = append(alternatives, `(`+strings.Join(terms, ` AND `)+`)`)
+	}
+	return `(` + strings.Join(alternatives, ` OR `) + `)`
+}
+
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return ` WHERE ` + strings.Join(conditions, ` AND `)
+}
+
+func orderClause(columns []sortColumn) string {
+	parts := make([]string, len(columns))
+	for i, column := range columns {
+		parts[i] = column.field
+		if column.desc {
+			parts[i] += ` DESC`
+		}
+	}
+	return ` ORDER BY ` + strings.Join(parts, `, `)
+}
@@ -0,0 +1,292 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/touchsung/spd-fiber-booking-system/models"
+)
+
+// Ensure SQLRepository satisfies BookingRepository and Outbox
+var (
+	_ BookingRepository = (*SQLRepository)(nil)
+	_ Outbox            = (*SQLRepository)(nil)
+)
+
+// SQLRepository stores bookings through database/sql. Queries use $n
+// placeholders so the same SQL runs on PostgreSQL and SQLite.
+type SQLRepository struct {
+	db *sql.DB
+	sqlOutbox
+}
+
+// NewSQLRepository runs pending migrations and returns a repository backed by db
+func NewSQLRepository(db *sql.DB) (*SQLRepository, error) {
+	if err := Migrate(db); err != nil {
+		return nil, err
+	}
+	return &SQLRepository{db: db, sqlOutbox: sqlOutbox{db: db}}, nil
+}
+
+const bookingColumns = `id, user_id, service_id, quantity, price, currency, status, created_at, version, price_breakdown`
+
+func (r *SQLRepository) GetBooking(bookingID string) (*models.Booking, error) {
+	row := r.db.QueryRow(`SELECT `+bookingColumns+` FROM bookings WHERE id = $1`, bookingID)
+	booking, err := scanBooking(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrBookingNotFound
+	}
+	return booking, err
+}
+
+func (r *SQLRepository) ListBookings(query models.BookingQuery) (*models.BookingPage, error) {
+	return listBookings(r.db, "bookings", query)
+}
+
+func (r *SQLRepository) SaveBooking(booking *models.Booking) error {
+	return upsertBooking(r.db, "bookings", booking)
+}
+
+func (r *SQLRepository) UpdateBookingStatus(change models.StatusChange, expectedVersion int64, events ...models.DomainEvent) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE bookings SET status = $1, version = version + 1 WHERE id = $2 AND version = $3`,
+		string(change.To), change.BookingID, expectedVersion)
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result); err != nil {
+		if !errors.Is(err, ErrBookingNotFound) {
+			return err
+		}
+		// Nothing matched: tell a missing booking apart from a newer version
+		var version int64
+		err = tx.QueryRow(`SELECT version FROM bookings WHERE id = $1`, change.BookingID).Scan(&version)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrBookingNotFound
+		}
+		if err != nil {
+			return err
+		}
+		return ErrVersionConflict
+	}
+
+	if _, err := tx.Exec(`INSERT INTO booking_status_history (`+historyColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		change.BookingID, expectedVersion+1, string(change.From), string(change.To),
+		string(change.Actor), change.Reason, change.ChangedAt.UTC()); err != nil {
+		return err
+	}
+	if err := insertOutboxEvents(tx, events); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+const historyColumns = `booking_id, version, from_status, to_status, actor, reason, changed_at`
+
+func (r *SQLRepository) GetStatusHistory(bookingID string) ([]models.StatusChange, error) {
+	var exists int
+	err := r.db.QueryRow(`SELECT 1 FROM bookings WHERE id = $1`, bookingID).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrBookingNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(`SELECT `+historyColumns+` FROM booking_status_history
+		WHERE booking_id = $1 ORDER BY version`, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := make([]models.StatusChange, 0)
+	for rows.Next() {
+		var (
+			change    models.StatusChange
+			from, to  string
+			actor     string
+			changedAt time.Time
+		)
+		if err := rows.Scan(&change.BookingID, &change.Version, &from, &to, &actor, &change.Reason, &changedAt); err != nil {
+			return nil, err
+		}
+		change.From = models.BookingStatus(from)
+		change.To = models.BookingStatus(to)
+		change.Actor = models.Actor(actor)
+		change.ChangedAt = changedAt.Local()
+		history = append(history, change)
+	}
+	return history, rows.Err()
+}
+
+func (r *SQLRepository) DeleteBooking(bookingID string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM bookings WHERE id = $1`, bookingID)
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM booking_status_history WHERE booking_id = $1`, bookingID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// listBookings runs query against table, which has the columns of bookings,
+// filtering, ordering and paging in SQL
+func listBookings(db *sql.DB, table string, query models.BookingQuery) (*models.BookingPage, error) {
+	columns, err := sortColumnsFor(query)
+	if err != nil {
+		return nil, err
+	}
+	var after []any
+	if query.Cursor != "" {
+		if after, err = decodeCursor(query.Cursor, columns); err != nil {
+			return nil, err
+		}
+	}
+
+	var args sqlArgs
+	conditions := filterConditions(query.Filter, &args)
+
+	page := &models.BookingPage{Limit: query.Limit}
+	countQuery := `SELECT COUNT(*) FROM ` + table + whereClause(conditions)
+	if err := db.QueryRow(countQuery, args.values...).Scan(&page.Total); err != nil {
+		return nil, err
+	}
+
+	if after != nil {
+		conditions = append(conditions, keysetCondition(columns, after, &args))
+	}
+	selectQuery := `SELECT ` + bookingColumns + ` FROM ` + table + whereClause(conditions) + orderClause(columns)
+	if query.Limit > 0 {
+		// Fetch one extra row to learn whether another page follows
+		selectQuery += ` LIMIT ` + args.add(query.Limit+1)
+	}
+	if after == nil && query.Offset > 0 {
+		if query.Limit <= 0 {
+			// SQLite only accepts OFFSET after a LIMIT
+			selectQuery += ` LIMIT ` + args.add(int64(math.MaxInt64))
+		}
+		selectQuery += ` OFFSET ` + args.add(query.Offset)
+		page.Offset = query.Offset
+	}
+
+	rows, err := db.Query(selectQuery, args.values...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page.Data = make([]*models.Booking, 0)
+	for rows.Next() {
+		booking, err := scanBooking(rows)
+		if err != nil {
+			return nil, err
+		}
+		page.Data = append(page.Data, booking)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if query.Limit > 0 && len(page.Data) > query.Limit {
+		page.Data = page.Data[:query.Limit]
+		page.NextCursor = encodeCursor(columns, page.Data[len(page.Data)-1])
+	}
+	return page, nil
+}
+
+// execer is implemented by both *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// bookingArgs returns the values of bookingColumns for booking
+func bookingArgs(booking *models.Booking) ([]any, error) {
+	var breakdown sql.NullString
+	if booking.PriceBreakdown != nil {
+		data, err := json.Marshal(booking.PriceBreakdown)
+		if err != nil {
+			return nil, err
+		}
+		breakdown = sql.NullString{String: string(data), Valid: true}
+	}
+	return []any{booking.ID, booking.UserID, booking.ServiceID, booking.Quantity, booking.Price.Amount, booking.Price.Currency,
+		string(booking.Status), booking.CreatedAt.UTC(), booking.Version, breakdown}, nil
+}
+
+// upsertBooking writes booking to table, which has the columns of bookings
+func upsertBooking(db execer, table string, booking *models.Booking) error {
+	args, err := bookingArgs(booking)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`INSERT INTO `+table+` (`+bookingColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (id) DO UPDATE SET
+			user_id = EXCLUDED.user_id,
+			service_id = EXCLUDED.service_id,
+			quantity = EXCLUDED.quantity,
+			price = EXCLUDED.price,
+			currency = EXCLUDED.currency,
+			status = EXCLUDED.status,
+			created_at = EXCLUDED.created_at,
+			version = EXCLUDED.version,
+			price_breakdown = EXCLUDED.price_breakdown`, args...)
+	return err
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanBooking(row rowScanner) (*models.Booking, error) {
+	var (
+		booking   models.Booking
+		status    string
+		createdAt time.Time
+		breakdown sql.NullString
+	)
+	if err := row.Scan(&booking.ID, &booking.UserID, &booking.ServiceID, &booking.Quantity, &booking.Price.Amount, &booking.Price.Currency, &status,
+		&createdAt, &booking.Version, &breakdown); err != nil {
+		return nil, err
+	}
+	booking.Status = models.BookingStatus(status)
+	booking.CreatedAt = createdAt.Local()
+	if breakdown.Valid {
+		if err := json.Unmarshal([]byte(breakdown.String), &booking.PriceBreakdown); err != nil {
+			return nil, err
+		}
+	}
+	return &booking, nil
+}
+
+func requireAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrBookingNotFound
+	}
+	return nil
+}
@@ -0,0 +1,362 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/touchsung/spd-fiber-booking-system/models"
+	_ "modernc.org/sqlite"
+)
+
+func setupSQLRepository(t *testing.T) *SQLRepository {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "bookings.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	repo, err := NewSQLRepository(db)
+	require.NoError(t, err)
+	return repo
+}
+
+func usd(amount int64) models.Money {
+	return models.NewMoney(amount, "USD")
+}
+
+func TestMigrateIsIdempotent(t *testing.T) {
+	repo := setupSQLRepository(t)
+
+	// Running migrations a second time must be a no-op
+	assert.NoError(t, Migrate(repo.db))
+
+	var version int
+	require.NoError(t, repo.db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version))
+	assert.Equal(t, migrations[len(migrations)-1].version, version)
+}
+
+func TestMigrateConvertsPricesToMinorUnits(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "bookings.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	// A database last migrated before prices were stored as minor units
+	_, err = db.Exec(`CREATE TABLE schema_migrations (version INTEGER PRIMARY KEY)`)
+	require.NoError(t, err)
+	for _, m := range migrations {
+		if m.version <= 10 {
+			require.NoError(t, applyMigration(db, m))
+		}
+	}
+	_, err = db.Exec(`INSERT INTO bookings (id, user_id, service_id, price, status, created_at, version, price_breakdown)
+		VALUES ('legacy', 'user1', 'service1', 600.5, 'pending', $1, 1,
+			'{"unit_price":600.5,"quantity":1,"subtotal":600.5,"total":600.5}')`, time.Now().UTC())
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO services (id, name, base_price, active, credit_check, created_at, updated_at)
+		VALUES ('service1', 'Service 1', 19.99, TRUE, 'never', $1, $1)`, time.Now().UTC())
+	require.NoError(t, err)
+
+	repo, err := NewSQLRepository(db)
+	require.NoError(t, err)
+	booking, err := repo.GetBooking("legacy")
+	require.NoError(t, err)
+	assert.Equal(t, usd(60050), booking.Price)
+	assert.Equal(t, usd(60050), booking.PriceBreakdown.Total)
+
+	service, err := NewSQLServiceRepository(db)
+	require.NoError(t, err)
+	found, err := service.GetService("service1")
+	require.NoError(t, err)
+	assert.Equal(t, usd(1999), found.BasePrice)
+}
+
+func TestSQLRepositoryCRUD(t *testing.T) {
+	repo := setupSQLRepository(t)
+
+	booking := &models.Booking{
+		ID:        "booking-1",
+		UserID:    "user1",
+		ServiceID: "service1",
+		Price:     usd(6000000),
+		Status:    models.StatusPending,
+		CreatedAt: time.Now().Truncate(time.Microsecond),
+		Version:   1,
+	}
+	require.NoError(t, repo.SaveBooking(booking))
+
+	found, err := repo.GetBooking(booking.ID)
+	require.NoError(t, err)
+	assert.Equal(t, booking.UserID, found.UserID)
+	assert.Equal(t, booking.Price, found.Price)
+	assert.Equal(t, models.StatusPending, found.Status)
+	assert.True(t, booking.CreatedAt.Equal(found.CreatedAt), "Expected created_at to round-trip")
+
+	// Saving again upserts instead of failing on the primary key
+	booking.Price = usd(7000000)
+	require.NoError(t, repo.SaveBooking(booking))
+
+	confirm := models.StatusChange{
+		BookingID: booking.ID,
+		From:      models.StatusPending,
+		To:        models.StatusConfirmed,
+		Actor:     models.ActorCreditCheck,
+		Reason:    "credit check approved",
+		ChangedAt: time.Now().Truncate(time.Microsecond),
+	}
+	require.NoError(t, repo.UpdateBookingStatus(confirm, booking.Version))
+	found, err = repo.GetBooking(booking.ID)
+	require.NoError(t, err)
+	assert.Equal(t, usd(7000000), found.Price)
+	assert.Equal(t, models.StatusConfirmed, found.Status)
+	assert.Equal(t, booking.Version+1, found.Version)
+
+	// A stale version no longer matches
+	cancel := models.StatusChange{BookingID: booking.ID, From: models.StatusPending, To: models.StatusCanceled, ChangedAt: time.Now()}
+	assert.ErrorIs(t, repo.UpdateBookingStatus(cancel, booking.Version), ErrVersionConflict)
+
+	// Only the applied change is recorded
+	history, err := repo.GetStatusHistory(booking.ID)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	confirm.Version = booking.Version + 1
+	assert.Equal(t, confirm.Version, history[0].Version)
+	assert.True(t, confirm.ChangedAt.Equal(history[0].ChangedAt))
+	history[0].ChangedAt = confirm.ChangedAt
+	assert.Equal(t, confirm, history[0])
+
+	page, err := repo.ListBookings(models.BookingQuery{})
+	require.NoError(t, err)
+	assert.Len(t, page.Data, 1)
+
+	require.NoError(t, repo.DeleteBooking(booking.ID))
+	_, err = repo.GetBooking(booking.ID)
+	assert.ErrorIs(t, err, ErrBookingNotFound)
+}
+
+func TestSQLRepositoryStoresPriceBreakdown(t *testing.T) {
+	repo := setupSQLRepository(t)
+
+	breakdown := &models.PriceBreakdown{
+		UnitPrice:   usd(1200000),
+		Quantity:    5,
+		Subtotal:    usd(6000000),
+		Adjustments: []models.PriceAdjustment{{Rule: "volume_discount", Description: "10% off 5 or more", Amount: usd(-600000)}},
+		Total:       usd(5400000),
+	}
+	priced := &models.Booking{ID: "priced", UserID: "user1", ServiceID: "service1", Quantity: 5, Price: usd(5400000),
+		Status: models.StatusPending, CreatedAt: time.Now().Truncate(time.Microsecond), Version: 1, PriceBreakdown: breakdown}
+	legacy := &models.Booking{ID: "legacy", UserID: "user1", ServiceID: "service1", Quantity: 1, Price: models.NewMoney(100, "JPY"),
+		Status: models.StatusPending, CreatedAt: time.Now().Truncate(time.Microsecond), Version: 1}
+	require.NoError(t, repo.SaveBooking(priced))
+	require.NoError(t, repo.SaveBooking(legacy))
+
+	found, err := repo.GetBooking("priced")
+	require.NoError(t, err)
+	assert.Equal(t, 5, found.Quantity)
+	assert.Equal(t, breakdown, found.PriceBreakdown)
+
+	found, err = repo.GetBooking("legacy")
+	require.NoError(t, err)
+	assert.Equal(t, 1, found.Quantity)
+	assert.Equal(t, models.NewMoney(100, "JPY"), found.Price)
+	assert.Nil(t, found.PriceBreakdown)
+}
+
+func TestSQLRepositoryNotFound(t *testing.T) {
+	repo := setupSQLRepository(t)
+
+	_, err := repo.GetBooking("missing")
+	assert.ErrorIs(t, err, ErrBookingNotFound)
+	assert.ErrorIs(t, repo.UpdateBookingStatus(models.StatusChange{BookingID: "missing", To: models.StatusCanceled}, 1), ErrBookingNotFound)
+	_, err = repo.GetStatusHistory("missing")
+	assert.ErrorIs(t, err, ErrBookingNotFound)
+	assert.ErrorIs(t, repo.DeleteBooking("missing"), ErrBookingNotFound)
+}
+
+func TestSQLRepositoryPagination(t *testing.T) {
+	repo := setupSQLRepository(t)
+
+	baseTime := time.Now().Truncate(time.Microsecond)
+	prices := []int64{30000, 10000, 20000, 10000, 50000}
+	for i, price := range prices {
+		require.NoError(t, repo.SaveBooking(&models.Booking{
+			ID:        fmt.Sprintf("booking-%d", i+1),
+			UserID:    "user1",
+			ServiceID: "service1",
+			Price:     usd(price),
+			Status:    models.StatusConfirmed,
+			CreatedAt: baseTime.Add(time.Duration(i) * time.Minute),
+		}))
+	}
+
+	// Offset pagination
+	page, err := repo.ListBookings(models.BookingQuery{Limit: 2, Offset: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 5, page.Total)
+	assert.Equal(t, []string{"booking-2", "booking-3"}, ids(page.Data))
+
+	// Offset without a limit
+	page, err = repo.ListBookings(models.BookingQuery{Offset: 3})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"booking-4", "booking-5"}, ids(page.Data))
+
+	// Cursor pagination by price, with ties broken by ID
+	query := models.BookingQuery{Sort: []models.SortKey{{Field: models.SortByPrice}}, Limit: 2}
+	seen := make([]string, 0, len(prices))
+	for {
+		page, err := repo.ListBookings(query)
+		require.NoError(t, err)
+		seen = append(seen, ids(page.Data)...)
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+	assert.Equal(t, []string{"booking-2", "booking-4", "booking-3", "booking-1", "booking-5"}, seen)
+
+	// Cursor pagination by date
+	sortByDate := []models.SortKey{{Field: models.SortByDate}}
+	page, err = repo.ListBookings(models.BookingQuery{Sort: sortByDate, Limit: 3})
+	require.NoError(t, err)
+	page, err = repo.ListBookings(models.BookingQuery{Sort: sortByDate, Limit: 3, Cursor: page.NextCursor})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"booking-4", "booking-5"}, ids(page.Data))
+	assert.Empty(t, page.NextCursor)
+
+	// Descending multi-key sort walked with cursors
+	query = models.BookingQuery{Sort: []models.SortKey{{Field: models.SortByPrice, Desc: true}, {Field: models.SortByDate, Desc: true}}, Limit: 2}
+	seen = seen[:0]
+	for {
+		page, err := repo.ListBookings(query)
+		require.NoError(t, err)
+		seen = append(seen, ids(page.Data)...)
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+	assert.Equal(t, []string{"booking-5", "booking-1", "booking-3", "booking-4", "booking-2"}, seen)
+
+	_, err = repo.ListBookings(models.BookingQuery{Cursor: "not-a-cursor"})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+
+	_, err = repo.ListBookings(models.BookingQuery{Sort: []models.SortKey{{Field: "price; DROP TABLE bookings"}}})
+	assert.ErrorIs(t, err, ErrInvalidSort)
+}
+
+func TestSQLRepositoryFilters(t *testing.T) {
+	repo := setupSQLRepository(t)
+
+	baseTime := time.Now().Truncate(time.Microsecond)
+	statuses := []models.BookingStatus{models.StatusPending, models.StatusConfirmed, models.StatusCanceled, models.StatusPending}
+	for i, status := range statuses {
+		require.NoError(t, repo.SaveBooking(&models.Booking{
+			ID:        fmt.Sprintf("booking-%d", i+1),
+			UserID:    fmt.Sprintf("user%d", i%2+1),
+			ServiceID: "service1",
+			Price:     usd(int64(i+1) * 10000),
+			Status:    status,
+			CreatedAt: baseTime.Add(time.Duration(i) * time.Hour),
+		}))
+	}
+
+	minPrice, maxPrice := usd(20000), usd(40000)
+	from, before := baseTime.Add(time.Hour), baseTime.Add(3*time.Hour)
+	page, err := repo.ListBookings(models.BookingQuery{Filter: models.BookingFilter{
+		UserID:   "user2",
+		Statuses: []models.BookingStatus{models.StatusPending, models.StatusConfirmed},
+		MinPrice: &minPrice,
+		MaxPrice: &maxPrice,
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, 2, page.Total)
+	assert.Equal(t, []string{"booking-2", "booking-4"}, ids(page.Data))
+
+	page, err = repo.ListBookings(models.BookingQuery{Filter: models.BookingFilter{CreatedFrom: &from, CreatedBefore: &before}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"booking-2", "booking-3"}, ids(page.Data))
+
+	// Filters still apply when paging with a cursor
+	query := models.BookingQuery{Filter: models.BookingFilter{Statuses: []models.BookingStatus{models.StatusPending}}, Limit: 1}
+	page, err = repo.ListBookings(query)
+	require.NoError(t, err)
+	query.Cursor = page.NextCursor
+	page, err = repo.ListBookings(query)
+	require.NoError(t, err)
+	assert.Equal(t, 2, page.Total)
+	assert.Equal(t, []string{"booking-4"}, ids(page.Data))
+	assert.Empty(t, page.NextCursor)
+}
+
+func ids(bookings []*models.Booking) []string {
+	result := make([]string, len(bookings))
+	for i, booking := range bookings {
+		result[i] = booking.ID
+	}
+	return result
+}
+
+func TestSQLRepositoryOutbox(t *testing.T) {
+	repo := setupSQLRepository(t)
+	require.NoError(t, repo.SaveBooking(&models.Booking{
+		ID: "booking-1", Status: models.StatusPending, CreatedAt: time.Now(), Version: 1,
+	}))
+
+	now := time.Now()
+	event := models.DomainEvent{
+		ID:         "event-1",
+		Type:       models.DomainEventBookingCanceled,
+		BookingID:  "booking-1",
+		Actor:      models.ActorUser,
+		OccurredAt: now,
+		Booking:    models.Booking{ID: "booking-1", Status: models.StatusCanceled, Version: 2},
+	}
+	change := models.StatusChange{BookingID: "booking-1", To: models.StatusCanceled, Actor: models.ActorUser, ChangedAt: now}
+
+	// A conflicting update must not leave its event behind
+	assert.ErrorIs(t, repo.UpdateBookingStatus(change, 5, event), ErrVersionConflict)
+	pending, err := repo.PendingEvents(now, 10)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	require.NoError(t, repo.UpdateBookingStatus(change, 1, event))
+	pending, err = repo.PendingEvents(now, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, "event-1", pending[0].Event.ID)
+	assert.Equal(t, models.StatusCanceled, pending[0].Event.Booking.Status)
+
+	retryAt := now.Add(time.Minute)
+	require.NoError(t, repo.MarkFailed("event-1", "broker down", retryAt))
+	pending, err = repo.PendingEvents(now, 10)
+	require.NoError(t, err)
+	assert.Empty(t, pending, "the event is not due before retryAt")
+
+	// A later event of the same booking waits for the failed one
+	later := event
+	later.ID = "event-2"
+	later.Booking.Version = 3
+	require.NoError(t, repo.UpdateBookingStatus(change, 2, later))
+	pending, err = repo.PendingEvents(now, 10)
+	require.NoError(t, err)
+	assert.Empty(t, pending, "event-2 is held back behind event-1")
+
+	pending, err = repo.PendingEvents(retryAt, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	assert.Equal(t, "event-1", pending[0].Event.ID)
+	assert.Equal(t, 1, pending[0].Attempts)
+	assert.Equal(t, "broker down", pending[0].LastError)
+	assert.Equal(t, "event-2", pending[1].Event.ID)
+
+	require.NoError(t, repo.MarkPublished("event-1", retryAt))
+	require.NoError(t, repo.MarkPublished("event-2", retryAt))
+	pending, err = repo.PendingEvents(retryAt, 10)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	assert.ErrorIs(t, repo.MarkPublished("missing", now), ErrOutboxMessageNotFound)
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/touchsung/spd-fiber-booking-system/models"
+)
+
+// Ensure SQLServiceRepository satisfies ServiceRepository
+var _ ServiceRepository = (*SQLServiceRepository)(nil)
+
+// SQLServiceRepository stores the service catalog in the services table
+type SQLServiceRepository struct {
+	db *sql.DB
+}
+
+// NewSQLServiceRepository runs pending migrations and returns a service repository backed by db
+func NewSQLServiceRepository(db *sql.DB) (*SQLServiceRepository, error) {
+	if err := Migrate(db); err != nil {
+		return nil, err
+	}
+	return &SQLServiceRepository{db: db}, nil
+}
+
+const serviceColumns = `id, name, base_price, currency, active, credit_check, created_at, updated_at`
+
+func (r *SQLServiceRepository) CreateService(service *models.Service) error {
+	result, err := r.db.Exec(`INSERT INTO services (`+serviceColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (id) DO NOTHING`,
+		service.ID, service.Name, service.BasePrice.Amount, service.BasePrice.Currency, service.Active, string(service.CreditCheck),
+		service.CreatedAt.UTC(), service.UpdatedAt.UTC())
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result); err != nil {
+		if errors.Is(err, ErrBookingNotFound) {
+			return ErrServiceExists
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *SQLServiceRepository) UpdateService(service *models.Service) error {
+	result, err := r.db.Exec(`UPDATE services SET
+			name = $1, base_price = $2, currency = $3, active = $4, credit_check = $5, updated_at = $6
+		WHERE id = $7`,
+		service.Name, service.BasePrice.Amount, service.BasePrice.Currency, service.Active, string(service.CreditCheck), service.UpdatedAt.UTC(), service.ID)
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result); err != nil {
+		if errors.Is(err, ErrBookingNotFound) {
+			return ErrServiceNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *SQLServiceRepository) GetService(serviceID string) (*models.Service, error) {
+	row := r.db.QueryRow(`SELECT `+serviceColumns+` FROM services WHERE id = $1`, serviceID)
+	service, err := scanService(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrServiceNotFound
+	}
+	return service, err
+}
+
+func (r *SQLServiceRepository) ListServices(activeOnly bool) ([]*models.Service, error) {
+	query := `SELECT ` + serviceColumns + ` FROM services`
+	var args []any
+	if activeOnly {
+		query += ` WHERE active = $1`
+		args = append(args, true)
+	}
+	rows, err := r.db.Query(query+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	services := make([]*models.Service, 0)
+	for rows.Next() {
+		service, err := scanService(rows)
+		if err != nil {
+			return nil, err
+		}
+		services = append(services, service)
+	}
+	return services, rows.Err()
+}
+
+func (r *SQLServiceRepository) DeleteService(serviceID string) error {
+	result, err := r.db.Exec(`DELETE FROM services WHERE id = $1`, serviceID)
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result); err != nil {
+		if errors.Is(err, ErrBookingNotFound) {
+			return ErrServiceNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func scanService(row rowScanner) (*models.Service, error) {
+	var (
+		service              models.Service
+		creditCheck          string
+		createdAt, updatedAt time.Time
+	)
+	if err := row.Scan(&service.ID, &service.Name, &service.BasePrice.Amount, &service.BasePrice.Currency, &service.Active, &creditCheck,
+		&createdAt, &updatedAt); err != nil {
+		return nil, err
+	}
+	service.CreditCheck = models.CreditCheckPolicy(creditCheck)
+	service.CreatedAt = createdAt.Local()
+	service.UpdatedAt = updatedAt.Local()
+	return &service, nil
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/touchsung/spd-fiber-booking-system/models"
+)
+
+// serviceRepositories runs each test against the mock and the SQLite repository
+func serviceRepositories(t *testing.T) map[string]func(t *testing.T) ServiceRepository {
+	return map[string]func(t *testing.T) ServiceRepository{
+		"mock": func(t *testing.T) ServiceRepository {
+			repo := NewMockServiceRepository()
+			repo.ClearServices()
+			return repo
+		},
+		"sqlite": func(t *testing.T) ServiceRepository {
+			repo, err := NewSQLServiceRepository(setupSQLRepository(t).db)
+			require.NoError(t, err)
+			return repo
+		},
+	}
+}
+
+func TestServiceRepository(t *testing.T) {
+	for name, newRepo := range serviceRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			repo := newRepo(t)
+			now := time.Now().Truncate(time.Microsecond)
+			transfer := &models.Service{ID: "transfer", Name: "Airport transfer", BasePrice: usd(120050), Active: true,
+				CreditCheck: models.CreditCheckAlways, CreatedAt: now, UpdatedAt: now}
+			tour := &models.Service{ID: "tour", Name: "City tour", BasePrice: models.NewMoney(300, "JPY"), Active: false,
+				CreditCheck: models.CreditCheckNever, CreatedAt: now, UpdatedAt: now}
+			require.NoError(t, repo.CreateService(transfer))
+			require.NoError(t, repo.CreateService(tour))
+			assert.ErrorIs(t, repo.CreateService(&models.Service{ID: "tour", Name: "Other", CreatedAt: now, UpdatedAt: now}), ErrServiceExists)
+
+			found, err := repo.GetService("transfer")
+			require.NoError(t, err)
+			assert.Equal(t, "Airport transfer", found.Name)
+			assert.Equal(t, usd(120050), found.BasePrice)
+			assert.True(t, found.Active)
+			assert.Equal(t, models.CreditCheckAlways, found.CreditCheck)
+			assert.True(t, now.Equal(found.CreatedAt))
+
+			all, err := repo.ListServices(false)
+			require.NoError(t, err)
+			require.Len(t, all, 2)
+			assert.Equal(t, "tour", all[0].ID)
+			active, err := repo.ListServices(true)
+			require.NoError(t, err)
+			require.Len(t, active, 1)
+			assert.Equal(t, "transfer", active[0].ID)
+
+			tour.Active = true
+			tour.BasePrice = models.NewMoney(350, "JPY")
+			tour.UpdatedAt = now.Add(time.Minute)
+			require.NoError(t, repo.UpdateService(tour))
+			found, err = repo.GetService("tour")
+			require.NoError(t, err)
+			assert.True(t, found.Active)
+			assert.Equal(t, models.NewMoney(350, "JPY"), found.BasePrice)
+			assert.True(t, tour.UpdatedAt.Equal(found.UpdatedAt))
+
+			require.NoError(t, repo.DeleteService("tour"))
+			assert.ErrorIs(t, repo.DeleteService("tour"), ErrServiceNotFound)
+			assert.ErrorIs(t, repo.UpdateService(tour), ErrServiceNotFound)
+			_, err = repo.GetService("tour")
+			assert.ErrorIs(t, err, ErrServiceNotFound)
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/touchsung/spd-fiber-booking-system/models"
+)
+
+// Ensure SQLWebhookRepository satisfies WebhookRepository
+var _ WebhookRepository = (*SQLWebhookRepository)(nil)
+
+// SQLWebhookRepository stores subscriptions in webhook_subscriptions and the
+// delivery log in webhook_deliveries
+type SQLWebhookRepository struct {
+	db *sql.DB
+}
+
+// NewSQLWebhookRepository runs pending migrations and returns a webhook repository backed by db
+func NewSQLWebhookRepository(db *sql.DB) (*SQLWebhookRepository, error) {
+	if err := Migrate(db); err != nil {
+		return nil, err
+	}
+	return &SQLWebhookRepository{db: db}, nil
+}
+
+const subscriptionColumns = `id, user_id, url, event_types, secret, active, created_at`
+
+func (r *SQLWebhookRepository) SaveSubscription(subscription *models.WebhookSubscription) error {
+	eventTypes := make([]string, len(subscription.EventTypes))
+	for i, eventType := range subscription.EventTypes {
+		eventTypes[i] = string(eventType)
+	}
+	_, err := r.db.Exec(`INSERT INTO webhook_subscriptions (`+subscriptionColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (id) DO UPDATE SET
+			user_id = EXCLUDED.user_id,
+			url = EXCLUDED.url,
+			event_types = EXCLUDED.event_types,
+			secret = EXCLUDED.secret,
+			active = EXCLUDED.active`,
+		subscription.ID, subscription.UserID, subscription.URL, strings.Join(eventTypes, ","),
+		subscription.Secret, subscription.Active, subscription.CreatedAt.UTC())
+	return err
+}
+
+func (r *SQLWebhookRepository) GetSubscription(subscriptionID string) (*models.WebhookSubscription, error) {
+	row := r.db.QueryRow(`SELECT `+subscriptionColumns+` FROM webhook_subscriptions WHERE id = $1`, subscriptionID)
+	subscription, err := scanSubscription(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSubscriptionNotFound
+	}
+	return subscription, err
+}
+
+func (r *SQLWebhookRepository) ListSubscriptions(userID string) ([]*models.WebhookSubscription, error) {
+	query := `SELECT ` + subscriptionColumns + ` FROM webhook_subscriptions`
+	var args []any
+	if userID != "" {
+		query += ` WHERE user_id = '' OR user_id = $1`
+		args = append(args, userID)
+	}
+	rows, err := r.db.Query(query+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subscriptions := make([]*models.WebhookSubscription, 0)
+	for rows.Next() {
+		subscription, err := scanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+	return subscriptions, rows.Err()
+}
+
+func (r *SQLWebhookRepository) DeleteSubscription(subscriptionID string) error {
+	result, err := r.db.Exec(`DELETE FROM webhook_subscriptions WHERE id = $1`, subscriptionID)
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result); err != nil {
+		if errors.Is(err, ErrBookingNotFound) {
+			return ErrSubscriptionNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func scanSubscription(row rowScanner) (*models.WebhookSubscription, error) {
+	var (
+		subscription models.WebhookSubscription
+		eventTypes   string
+		createdAt    time.Time
+	)
+	if err := row.Scan(&subscription.ID, &subscription.UserID, &subscription.URL, &eventTypes,
+		&subscription.Secret, &subscription.Active, &createdAt); err != nil {
+		return nil, err
+	}
+	if eventTypes != "" {
+		for _, eventType := range strings.Split(eventTypes, ",") {
+			subscription.EventTypes = append(subscription.EventTypes, models.DomainEventType(eventType))
+		}
+	}
+	subscription.CreatedAt = createdAt.Local()
+	return &subscription, nil
+}
+
+const deliveryColumns = `id, subscription_id, event_id, event_type, booking_id, payload, status, attempts,
+	last_status_code, last_error, created_at, updated_at, delivered_at`
+
+func (r *SQLWebhookRepository) CreateDelivery(delivery *models.WebhookDelivery) error {
+	result, err := r.db.Exec(`INSERT INTO webhook_deliveries (`+deliveryColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		ON CONFLICT (subscription_id, event_id) DO NOTHING`, deliveryValues(delivery)...)
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result); err != nil {
+		if errors.Is(err, ErrBookingNotFound) {
+			return ErrDeliveryExists
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *SQLWebhookRepository) UpdateDelivery(delivery *models.WebhookDelivery) error {
+	result, err := r.db.Exec(`UPDATE webhook_deliveries SET
+			status = $1, attempts = $2, last_status_code = $3, last_error = $4, updated_at = $5, delivered_at = $6
+		WHERE id = $7`,
+		string(delivery.Status), delivery.Attempts, delivery.LastStatusCode, delivery.LastError,
+		delivery.UpdatedAt.UTC(), utcOrNil(delivery.DeliveredAt), delivery.ID)
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result); err != nil {
+		if errors.Is(err, ErrBookingNotFound) {
+			return ErrDeliveryNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *SQLWebhookRepository) GetDelivery(deliveryID string) (*models.WebhookDelivery, error) {
+	row := r.db.QueryRow(`SELECT `+deliveryColumns+` FROM webhook_deliveries WHERE id = $1`, deliveryID)
+	delivery, err := scanDelivery(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDeliveryNotFound
+	}
+	return delivery, err
+}
+
+func (r *SQLWebhookRepository) FindDelivery(subscriptionID, eventID string) (*models.WebhookDelivery, error) {
+	row := r.db.QueryRow(`SELECT `+deliveryColumns+` FROM webhook_deliveries
+		WHERE subscription_id = $1 AND event_id = $2`, subscriptionID, eventID)
+	delivery, err := scanDelivery(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDeliveryNotFound
+	}
+	return delivery, err
+}
+
+func (r *SQLWebhookRepository) ListDeliveries(subscriptionID string, statuses ...models.DeliveryStatus) ([]*models.WebhookDelivery, error) {
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries WHERE subscription_id = $1`
+	args := []any{subscriptionID}
+	if len(statuses) > 0 {
+		placeholders := make([]string, len(statuses))
+		for i, status := range statuses {
+			placeholders[i] = fmt.Sprintf("$%d", i+2)
+			args = append(args, string(status))
+		}
+		query += ` AND status IN (` + strings.Join(placeholders, ", ") + `)`
+	}
+
+	rows, err := r.db.Query(query+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := make([]*models.WebhookDelivery, 0)
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+func deliveryValues(delivery *models.WebhookDelivery) []any {
+	return []any{
+		delivery.ID, delivery.SubscriptionID, delivery.EventID, string(delivery.EventType), delivery.BookingID,
+		delivery.Payload, string(delivery.Status), delivery.Attempts, delivery.LastStatusCode, delivery.LastError,
+		delivery.CreatedAt.UTC(), delivery.UpdatedAt.UTC(), utcOrNil(delivery.DeliveredAt),
+	}
+}
+
+func scanDelivery(row rowScanner) (*models.WebhookDelivery, error) {
+	var (
+		delivery             models.WebhookDelivery
+		eventType, status    string
+		createdAt, updatedAt time.Time
+		deliveredAt          sql.NullTime
+	)
+	if err := row.Scan(&delivery.ID, &delivery.SubscriptionID, &delivery.EventID, &eventType, &delivery.BookingID,
+		&delivery.Payload, &status, &delivery.Attempts, &delivery.LastStatusCode, &delivery.LastError,
+		&createdAt, &updatedAt, &deliveredAt); err != nil {
+		return nil, err
+	}
+	delivery.EventType = models.DomainEventType(eventType)
+	delivery.Status = models.DeliveryStatus(status)
+	delivery.CreatedAt = createdAt.Local()
+	delivery.UpdatedAt = updatedAt.Local()
+	if deliveredAt.Valid {
+		at := deliveredAt.Time.Local()
+		delivery.DeliveredAt = &at
+	}
+	return &delivery, nil
+}
+
+// utcOrNil stores an optional timestamp as UTC or NULL
+func utcOrNil(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.UTC()
+}
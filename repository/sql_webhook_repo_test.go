@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/touchsung/spd-fiber-booking-system/models"
+)
+
+// webhookRepositories runs each test against the mock and the SQLite repository
+func webhookRepositories(t *testing.T) map[string]func(t *testing.T) WebhookRepository {
+	return map[string]func(t *testing.T) WebhookRepository{
+		"mock": func(t *testing.T) WebhookRepository { return NewMockWebhookRepository() },
+		"sqlite": func(t *testing.T) WebhookRepository {
+			repo, err := NewSQLWebhookRepository(setupSQLRepository(t).db)
+			require.NoError(t, err)
+			return repo
+		},
+	}
+}
+
+func TestWebhookRepositorySubscriptions(t *testing.T) {
+	for name, newRepo := range webhookRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			repo := newRepo(t)
+			now := time.Now().Truncate(time.Microsecond)
+			global := &models.WebhookSubscription{ID: "sub-1", URL: "https://example.com/all", Secret: "secret-1", Active: true, CreatedAt: now}
+			mine := &models.WebhookSubscription{ID: "sub-2", UserID: "user1", URL: "https://example.com/mine", Secret: "secret-2",
+				EventTypes: []models.DomainEventType{models.DomainEventBookingConfirmed, models.DomainEventBookingRejected}, Active: true, CreatedAt: now}
+			theirs := &models.WebhookSubscription{ID: "sub-3", UserID: "user2", URL: "https://example.com/theirs", Secret: "secret-3", Active: true, CreatedAt: now}
+			for _, subscription := range []*models.WebhookSubscription{global, mine, theirs} {
+				require.NoError(t, repo.SaveSubscription(subscription))
+			}
+
+			found, err := repo.GetSubscription("sub-2")
+			require.NoError(t, err)
+			assert.Equal(t, mine.EventTypes, found.EventTypes)
+			assert.Equal(t, "secret-2", found.Secret)
+			assert.True(t, now.Equal(found.CreatedAt))
+
+			found, err = repo.GetSubscription("sub-1")
+			require.NoError(t, err)
+			assert.Empty(t, found.EventTypes)
+
+			forUser, err := repo.ListSubscriptions("user1")
+			require.NoError(t, err)
+			require.Len(t, forUser, 2)
+			assert.Equal(t, "sub-1", forUser[0].ID)
+			assert.Equal(t, "sub-2", forUser[1].ID)
+
+			all, err := repo.ListSubscriptions("")
+			require.NoError(t, err)
+			assert.Len(t, all, 3)
+
+			require.NoError(t, repo.DeleteSubscription("sub-3"))
+			assert.ErrorIs(t, repo.DeleteSubscription("sub-3"), ErrSubscriptionNotFound)
+			_, err = repo.GetSubscription("sub-3")
+			assert.ErrorIs(t, err, ErrSubscriptionNotFound)
+		})
+	}
+}
+
+func TestWebhookRepositoryDeliveries(t *testing.T) {
+	for name, newRepo := range webhookRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			repo := newRepo(t)
+			now := time.Now().Truncate(time.Microsecond)
+			first := &models.WebhookDelivery{ID: "delivery-1", SubscriptionID: "sub-1", EventID: "event-1",
+				EventType: models.DomainEventBookingConfirmed, BookingID: "booking-1", Payload: `{"id":"event-1"}`,
+				Status: models.DeliveryPending, CreatedAt: now, UpdatedAt: now}
+			second := &models.WebhookDelivery{ID: "delivery-2", SubscriptionID: "sub-1", EventID: "event-2",
+				EventType: models.DomainEventBookingCanceled, BookingID: "booking-2", Payload: `{"id":"event-2"}`,
+				Status: models.DeliveryPending, CreatedAt: now, UpdatedAt: now}
+			require.NoError(t, repo.CreateDelivery(first))
+			require.NoError(t, repo.CreateDelivery(second))
+
+			duplicate := *first
+			duplicate.ID = "delivery-3"
+			assert.ErrorIs(t, repo.CreateDelivery(&duplicate), ErrDeliveryExists)
+
+			found, err := repo.FindDelivery("sub-1", "event-1")
+			require.NoError(t, err)
+			assert.Equal(t, "delivery-1", found.ID)
+			assert.Nil(t, found.DeliveredAt)
+			_, err = repo.FindDelivery("sub-2", "event-1")
+			assert.ErrorIs(t, err, ErrDeliveryNotFound)
+
+			deliveredAt := now.Add(time.Second)
+			first.Status = models.DeliverySucceeded
+			first.Attempts = 2
+			first.LastStatusCode = 204
+			first.UpdatedAt = deliveredAt
+			first.DeliveredAt = &deliveredAt
+			require.NoError(t, repo.UpdateDelivery(first))
+
+			found, err = repo.GetDelivery("delivery-1")
+			require.NoError(t, err)
+			assert.Equal(t, models.DeliverySucceeded, found.Status)
+			assert.Equal(t, 2, found.Attempts)
+			assert.Equal(t, 204, found.LastStatusCode)
+			assert.Equal(t, `{"id":"event-1"}`, found.Payload)
+			require.NotNil(t, found.DeliveredAt)
+			assert.True(t, deliveredAt.Equal(*found.DeliveredAt))
+
+			all, err := repo.ListDeliveries("sub-1")
+			require.NoError(t, err)
+			require.Len(t, all, 2)
+			assert.Equal(t, "delivery-1", all[0].ID)
+
+			pending, err := repo.ListDeliveries("sub-1", models.DeliveryPending, models.DeliveryFailed)
+			require.NoError(t, err)
+			require.Len(t, pending, 1)
+			assert.Equal(t, "delivery-2", pending[0].ID)
+
+			missing := &models.WebhookDelivery{ID: "missing"}
+			assert.ErrorIs(t, repo.UpdateDelivery(missing), ErrDeliveryNotFound)
+			_, err = repo.GetDelivery("missing")
+			assert.ErrorIs(t, err, ErrDeliveryNotFound)
+		})
+	}
+}
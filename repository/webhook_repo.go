@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"errors"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/touchsung/spd-fiber-booking-system/models"
+)
+
+var (
+	// ErrSubscriptionNotFound is returned when a webhook subscription does not exist
+	ErrSubscriptionNotFound = errors.New("webhook subscription not found")
+	// ErrDeliveryNotFound is returned when a webhook delivery does not exist
+	ErrDeliveryNotFound = errors.New("webhook delivery not found")
+	// ErrDeliveryExists is returned when an event was already recorded for a subscription
+	ErrDeliveryExists = errors.New("webhook delivery already exists")
+)
+
+// WebhookRepository persists webhook subscriptions and their delivery log
+type WebhookRepository interface {
+	SaveSubscription(subscription *models.WebhookSubscription) error
+	GetSubscription(subscriptionID string) (*models.WebhookSubscription, error)
+	// ListSubscriptions returns the subscriptions of userID plus the global
+	// ones, oldest first. An empty userID returns every subscription.
+	ListSubscriptions(userID string) ([]*models.WebhookSubscription, error)
+	DeleteSubscription(subscriptionID string) error
+
+	// CreateDelivery records a new delivery, failing with ErrDeliveryExists when
+	// the subscription already has one for the same event
+	CreateDelivery(delivery *models.WebhookDelivery) error
+	UpdateDelivery(delivery *models.WebhookDelivery) error
+	GetDelivery(deliveryID string) (*models.WebhookDelivery, error)
+	// FindDelivery returns the subscription's delivery of an event
+	FindDelivery(subscriptionID, eventID string) (*models.WebhookDelivery, error)
+	// ListDeliveries returns the subscription's deliveries in any of the given
+	// statuses, oldest first. No statuses means all deliveries.
+	ListDeliveries(subscriptionID string, statuses ...models.DeliveryStatus) ([]*models.WebhookDelivery, error)
+}
+
+// Ensure MockWebhookRepository satisfies WebhookRepository
+var _ WebhookRepository = (*MockWebhookRepository)(nil)
+
+// MockWebhookRepository keeps subscriptions and deliveries in memory
+type MockWebhookRepository struct {
+	mutex         sync.RWMutex
+	subscriptions map[string]models.WebhookSubscription
+	deliveries    map[string]models.WebhookDelivery
+}
+
+func NewMockWebhookRepository() *MockWebhookRepository {
+	return &MockWebhookRepository{
+		subscriptions: make(map[string]models.WebhookSubscription),
+		deliveries:    make(map[string]models.WebhookDelivery),
+	}
+}
+
+func (m *MockWebhookRepository) SaveSubscription(subscription *models.WebhookSubscription) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	stored := *subscription
+	stored.EventTypes = slices.Clone(subscription.EventTypes)
+	m.subscriptions[subscription.ID] = stored
+	return nil
+}
+
+func (m *MockWebhookRepository) GetSubscription(subscriptionID string) (*models.WebhookSubscription, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	subscription, exists := m.subscriptions[subscriptionID]
+	if !exists {
+		return nil, ErrSubscriptionNotFound
+	}
+	subscription.EventTypes = slices.Clone(subscription.EventTypes)
+	return &subscription, nil
+}
+
+func (m *MockWebhookRepository) ListSubscriptions(userID string) ([]*models.WebhookSubscription, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	subscriptions := make([]*models.WebhookSubscription, 0)
+	for _, subscription := range m.subscriptions {
+		if userID != "" && subscription.UserID != "" && subscription.UserID != userID {
+			continue
+		}
+		subscription.EventTypes = slices.Clone(subscription.EventTypes)
+		subscriptions = append(subscriptions, &subscription)
+	}
+	sort.Slice(subscriptions, func(i, j int) bool {
+		return subscriptions[i].ID < subscriptions[j].ID
+	})
+	return subscriptions, nil
+}
+
+func (m *MockWebhookRepository) DeleteSubscription(subscriptionID string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, exists := m.subscriptions[subscriptionID]; !exists {
+		return ErrSubscriptionNotFound
+	}
+	delete(m.subscriptions, subscriptionID)
+	return nil
+}
+
+func (m *MockWebhookRepository) CreateDelivery(delivery *models.WebhookDelivery) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, existing := range m.deliveries {
+		if existing.SubscriptionID == delivery.SubscriptionID && existing.EventID == delivery.EventID {
+			return ErrDeliveryExists
+		}
+	}
+	m.deliveries[delivery.ID] = *delivery
+	return nil
+}
+
+func (m *MockWebhookRepository) UpdateDelivery(delivery *models.WebhookDelivery) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, exists := m.deliveries[delivery.ID]; !exists {
+		return ErrDeliveryNotFound
+	}
+	m.deliveries[delivery.ID] = *delivery
+	return nil
+}
+
+func (m *MockWebhookRepository) GetDelivery(deliveryID string) (*models.WebhookDelivery, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	delivery, exists := m.deliveries[deliveryID]
+	if !exists {
+		return nil, ErrDeliveryNotFound
+	}
+	return &delivery, nil
+}
+
+func (m *MockWebhookRepository) FindDelivery(subscriptionID, eventID string) (*models.WebhookDelivery, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	for _, delivery := range m.deliveries {
+		if delivery.SubscriptionID == subscriptionID && delivery.EventID == eventID {
+			return &delivery, nil
+		}
+	}
+	return nil, ErrDeliveryNotFound
+}
+
+func (m *MockWebhookRepository) ListDeliveries(subscriptionID string, statuses ...models.DeliveryStatus) ([]*models.WebhookDelivery, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	deliveries := make([]*models.WebhookDelivery, 0)
+	for _, delivery := range m.deliveries {
+		if delivery.SubscriptionID != subscriptionID {
+			continue
+		}
+		if len(statuses) > 0 && !slices.Contains(statuses, delivery.Status) {
+			continue
+		}
+		deliveries = append(deliveries, &delivery)
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].ID < deliveries[j].ID
+	})
+	return deliveries, nil
+}
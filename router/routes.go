@@ -3,20 +3,56 @@ package router
 import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/swagger"
+	"github.com/touchsung/spd-fiber-booking-system/authz"
 	"github.com/touchsung/spd-fiber-booking-system/handler"
 	"github.com/touchsung/spd-fiber-booking-system/middleware"
 )
 
-func SetupRoutes(app *fiber.App, bookingHandler *handler.BookingHandler) {
+func SetupRoutes(app *fiber.App, bookingHandler *handler.BookingHandler, jobHandler *handler.JobHandler,
+	webhookHandler *handler.WebhookHandler, serviceHandler *handler.ServiceHandler, configHandler *handler.ConfigHandler, idempotencyStore middleware.IdempotencyStore,
+	authenticate fiber.Handler, policy *authz.Policy) {
 	// Add global middleware
 	app.Use(middleware.RequestLogger())
 
 	// Swagger route
 	app.Get("/swagger/*", swagger.HandlerDefault)
 
+	// Every route below needs a bearer token when authentication is configured
+	if authenticate != nil {
+		app.Use(authenticate)
+	}
+
 	// Booking routes
 	app.Get("/bookings", bookingHandler.ListBookings)
-	app.Post("/bookings", bookingHandler.Create)
+	app.Post("/bookings", middleware.Idempotency(idempotencyStore), bookingHandler.Create)
 	app.Get("/bookings/:id", bookingHandler.GetBooking)
+	app.Get("/bookings/:id/history", bookingHandler.GetBookingHistory)
 	app.Delete("/bookings/:id", bookingHandler.CancelBooking)
+
+	// Service catalog routes; anyone may browse, only admins change the catalog
+	manageServices := middleware.RequirePermission(policy, authz.PermissionManageServices)
+	app.Get("/services", serviceHandler.ListServices)
+	app.Post("/services", manageServices, serviceHandler.CreateService)
+	app.Get("/services/:id", serviceHandler.GetService)
+	app.Put("/services/:id", manageServices, serviceHandler.UpdateService)
+	app.Delete("/services/:id", manageServices, serviceHandler.DeleteService)
+
+	// Runtime configuration routes
+	manageConfig := middleware.RequirePermission(policy, authz.PermissionManageConfig)
+	app.Get("/config", manageConfig, configHandler.GetConfig)
+	app.Patch("/config", manageConfig, configHandler.UpdateConfig)
+
+	// Background job routes
+	manageJobs := middleware.RequirePermission(policy, authz.PermissionManageJobs)
+	app.Get("/jobs", manageJobs, jobHandler.ListJobs)
+	app.Get("/jobs/stats", manageJobs, jobHandler.Stats)
+	app.Post("/jobs/:id/retry", manageJobs, jobHandler.RetryJob)
+
+	// Webhook routes; callers manage their own subscriptions, see WebhookHandler
+	app.Post("/webhooks", webhookHandler.CreateSubscription)
+	app.Get("/webhooks", webhookHandler.ListSubscriptions)
+	app.Post("/webhooks/deliveries/:id/replay", webhookHandler.ReplayDelivery)
+	app.Get("/webhooks/:id", webhookHandler.GetSubscription)
+	app.Delete("/webhooks/:id", webhookHandler.DeleteSubscription)
+	app.Get("/webhooks/:id/deliveries", webhookHandler.ListDeliveries)
 }
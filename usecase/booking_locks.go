@@ -0,0 +1,41 @@
+package usecase
+
+import "sync"
+
+// bookingLocks hands out one mutex per booking ID. A lock is dropped once no
+// goroutine holds or waits for it, so the map only grows with concurrency.
+type bookingLocks struct {
+	mutex sync.Mutex
+	locks map[string]*bookingLock
+}
+
+type bookingLock struct {
+	sync.Mutex
+	waiters int // goroutines holding or waiting for the lock
+}
+
+// lock blocks until the booking's lock is held and returns its release function
+func (l *bookingLocks) lock(bookingID string) (unlock func()) {
+	l.mutex.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*bookingLock)
+	}
+	lock, ok := l.locks[bookingID]
+	if !ok {
+		lock = &bookingLock{}
+		l.locks[bookingID] = lock
+	}
+	lock.waiters++
+	l.mutex.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		l.mutex.Lock()
+		lock.waiters--
+		if lock.waiters == 0 {
+			delete(l.locks, bookingID)
+		}
+		l.mutex.Unlock()
+	}
+}
@@ -1,70 +1,481 @@
 package usecase
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"math/rand"
-	"sort"
-	"strconv"
+	"log"
+	"sync"
 	"time"
 
+	"github.com/touchsung/spd-fiber-booking-system/authz"
 	"github.com/touchsung/spd-fiber-booking-system/models"
+	"github.com/touchsung/spd-fiber-booking-system/pricing"
 	"github.com/touchsung/spd-fiber-booking-system/repository"
 	"github.com/touchsung/spd-fiber-booking-system/utils"
 )
 
+const (
+	// DefaultHighValueThreshold is the price, in major units of the service's
+	// currency, above which bookings need a credit check
+	DefaultHighValueThreshold = 50000
+	// DefaultExpiryWindow is how long a booking may stay pending before the sweeper cancels it
+	DefaultExpiryWindow = 5 * time.Minute
+
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+
+	sweepBatchSize = 100
+)
+
+// restrictedTransitions are the status changes that need a permission on top
+// of the state machine allowing them
+var restrictedTransitions = map[[2]models.BookingStatus]authz.Permission{
+	{models.StatusConfirmed, models.StatusCanceled}: authz.PermissionCancelConfirmedBooking,
+}
+
 type BookingService struct {
-	cache          *utils.InMemoryCache
-	mockRepository *repository.MockRepository
+	cache         utils.BookingCache
+	repository    repository.BookingRepository
+	idGenerator   utils.IDGenerator
+	stateMachine  *models.StateMachine
+	policy        *authz.Policy
+	creditChecker CreditChecker
+	jobQueue      JobQueue
+	services      repository.ServiceRepository
+	pricing       PricingEngine
+	currency      string
+	settingsMutex sync.RWMutex // guards highValue and expiryWindow, see UpdateSettings
+	highValue     models.Money
+	expiryWindow  time.Duration
+	statusLocks   bookingLocks   // orders status changes of the same booking within this process
+	inFlight      sync.WaitGroup // credit checks run without a job queue
+}
+
+// JobQueue schedules durable background work
+type JobQueue interface {
+	Enqueue(jobType models.JobType, payload string) (*models.Job, error)
+}
+
+// PricingEngine computes the price of booking quantity units of a service
+type PricingEngine interface {
+	Quote(service *models.Service, quantity int) (*models.PriceBreakdown, error)
 }
 
-func NewBookingService(cache *utils.InMemoryCache, mockRepo *repository.MockRepository) *BookingService {
-	return &BookingService{
-		cache:          cache,
-		mockRepository: mockRepo,
+// ServiceOption customizes optional BookingService dependencies
+type ServiceOption func(*BookingService)
+
+// WithIDGenerator overrides the default ULID generator, e.g. for deterministic IDs in tests
+func WithIDGenerator(generator utils.IDGenerator) ServiceOption {
+	return func(s *BookingService) {
+		s.idGenerator = generator
 	}
 }
 
-func (s *BookingService) requiresCreditCheck(price float64) bool {
-	return price > 50000
+// WithCreditChecker replaces the default simulated credit check
+func WithCreditChecker(checker CreditChecker) ServiceOption {
+	return func(s *BookingService) {
+		s.creditChecker = checker
+	}
 }
 
-func (s *BookingService) generateRandomStatus() models.BookingStatus {
-	if rand.Float64() < 0.5 {
-		return models.StatusRejected
+// WithCurrency sets the currency bookings are priced in, models.DefaultCurrency by default
+func WithCurrency(currency string) ServiceOption {
+	return func(s *BookingService) {
+		s.currency = currency
 	}
-	return models.StatusConfirmed
 }
 
-func (s *BookingService) simulateCreditCheck(bookingID string) models.CreditCheckResult {
-	time.Sleep(2 * time.Second)
-	return models.CreditCheckResult{
-		BookingID: bookingID,
-		Status:    s.generateRandomStatus(),
+// WithHighValueThreshold sets the price above which bookings need a credit
+// check and count as high-value in listings. It must be in the service's
+// currency; the default is DefaultHighValueThreshold.
+func WithHighValueThreshold(threshold models.Money) ServiceOption {
+	return func(s *BookingService) {
+		s.highValue = threshold
+	}
+}
+
+// WithExpiryWindow sets how long a booking may stay pending before
+// CancelExpiredBookings cancels it
+func WithExpiryWindow(window time.Duration) ServiceOption {
+	return func(s *BookingService) {
+		s.expiryWindow = window
+	}
+}
+
+// WithJobQueue runs credit checks through a durable job queue instead of
+// fire-and-forget goroutines. Register HandleCreditCheckJob for
+// models.JobTypeCreditCheck on the same queue.
+func WithJobQueue(queue JobQueue) ServiceOption {
+	return func(s *BookingService) {
+		s.jobQueue = queue
+	}
+}
+
+// WithServiceCatalog makes CreateBooking reject services that are not in the
+// catalog or inactive, and applies each service's credit check policy
+func WithServiceCatalog(services repository.ServiceRepository) ServiceOption {
+	return func(s *BookingService) {
+		s.services = services
+	}
+}
+
+// WithPricingEngine replaces the default engine, which charges the service's
+// base price per unit without any rules. Prices are only computed for
+// services from the catalog set with WithServiceCatalog.
+func WithPricingEngine(engine PricingEngine) ServiceOption {
+	return func(s *BookingService) {
+		s.pricing = engine
+	}
+}
+
+// WithPolicy replaces authz.DefaultPolicy for the status changes that need a permission
+func WithPolicy(policy *authz.Policy) ServiceOption {
+	return func(s *BookingService) {
+		s.policy = policy
+	}
+}
+
+func NewBookingService(cache utils.BookingCache, repo repository.BookingRepository, options ...ServiceOption) *BookingService {
+	service := &BookingService{
+		cache:         cache,
+		repository:    repo,
+		idGenerator:   utils.NewULIDGenerator(),
+		stateMachine:  models.BookingStateMachine,
+		policy:        authz.DefaultPolicy,
+		creditChecker: NewSimulatedCreditChecker(2 * time.Second),
+		pricing:       pricing.NewEngine(),
+		currency:      models.DefaultCurrency,
+		expiryWindow:  DefaultExpiryWindow,
+	}
+	for _, option := range options {
+		option(service)
+	}
+	if service.highValue.Currency == "" {
+		service.highValue = models.FromMajorUnits(DefaultHighValueThreshold, service.currency)
+	}
+	return service
+}
+
+// Currency returns the currency bookings are priced in
+func (s *BookingService) Currency() string {
+	return s.currency
+}
+
+// Settings returns the booking rules in effect
+func (s *BookingService) Settings() models.BookingSettings {
+	s.settingsMutex.RLock()
+	defer s.settingsMutex.RUnlock()
+	return models.BookingSettings{HighValueThreshold: s.highValue, ExpiryWindow: s.expiryWindow.String()}
+}
+
+// UpdateSettings changes the booking rules while the service runs. The change
+// is not persisted; the configuration applies again after a restart.
+func (s *BookingService) UpdateSettings(request models.BookingSettingsRequest) (models.BookingSettings, error) {
+	s.settingsMutex.Lock()
+	defer s.settingsMutex.Unlock()
+
+	highValue, window := s.highValue, s.expiryWindow
+	if threshold := request.HighValueThreshold; threshold != nil {
+		if threshold.Currency != s.currency {
+			return models.BookingSettings{}, validationError(fmt.Sprintf("high value threshold is in %s but bookings are priced in %s",
+				threshold.Currency, s.currency))
+		}
+		if threshold.IsNegative() {
+			return models.BookingSettings{}, validationError("high value threshold must not be negative")
+		}
+		highValue = *threshold
+	}
+	if request.ExpiryWindow != "" {
+		parsed, err := time.ParseDuration(request.ExpiryWindow)
+		if err != nil || parsed <= 0 {
+			return models.BookingSettings{}, validationError("expiry window must be a positive duration such as 10m")
+		}
+		window = parsed
+	}
+
+	s.highValue, s.expiryWindow = highValue, window
+	return models.BookingSettings{HighValueThreshold: highValue, ExpiryWindow: window.String()}, nil
+}
+
+func (s *BookingService) currentHighValue() models.Money {
+	s.settingsMutex.RLock()
+	defer s.settingsMutex.RUnlock()
+	return s.highValue
+}
+
+func (s *BookingService) currentExpiryWindow() time.Duration {
+	s.settingsMutex.RLock()
+	defer s.settingsMutex.RUnlock()
+	return s.expiryWindow
+}
+
+// Policy returns the role policy status changes are checked against
+func (s *BookingService) Policy() *authz.Policy {
+	return s.policy
+}
+
+// requiresCreditCheck applies the service's credit check policy; without a
+// catalog, only bookings above the high-value threshold are checked
+func (s *BookingService) requiresCreditCheck(booking *models.Booking, service *models.Service) bool {
+	if service != nil {
+		switch service.CreditCheck {
+		case models.CreditCheckAlways:
+			return true
+		case models.CreditCheckNever:
+			return false
+		}
+	}
+	// A price that cannot be compared with the threshold is checked to be safe
+	result, err := booking.Price.Cmp(s.currentHighValue())
+	return err != nil || result > 0
+}
+
+// bookableService looks up the requested service in the catalog, or returns
+// nil when no catalog is configured
+func (s *BookingService) bookableService(serviceID string) (*models.Service, error) {
+	if s.services == nil {
+		return nil, nil
+	}
+	service, err := s.services.GetService(serviceID)
+	if err != nil {
+		if errors.Is(err, repository.ErrServiceNotFound) {
+			return nil, validationError(fmt.Sprintf("service %s does not exist", serviceID))
+		}
+		return nil, fmt.Errorf("failed to load service: %w", err)
+	}
+	if !service.Active {
+		return nil, validationError(fmt.Sprintf("service %s is not available for booking", serviceID))
+	}
+	if service.BasePrice.Currency != s.currency {
+		return nil, validationError(fmt.Sprintf("service %s is priced in %s but bookings are priced in %s",
+			serviceID, service.BasePrice.Currency, s.currency))
+	}
+	return service, nil
+}
+
+// priceBooking computes the price of a booking and checks it against the
+// price the client expects, if any. Without a catalog the requested price is
+// taken as is.
+func (s *BookingService) priceBooking(service *models.Service, quantity int, requested *models.Money) (models.Money, *models.PriceBreakdown, error) {
+	if requested != nil && requested.Currency != s.currency {
+		return models.Money{}, nil, validationError(fmt.Sprintf("price is in %s but bookings are priced in %s",
+			requested.Currency, s.currency))
+	}
+	if service == nil {
+		if requested == nil {
+			return models.NewMoney(0, s.currency), nil, nil
+		}
+		return *requested, nil, nil
+	}
+	breakdown, err := s.pricing.Quote(service, quantity)
+	if err != nil {
+		if errors.Is(err, pricing.ErrInvalidQuantity) {
+			return models.Money{}, nil, &Error{Kind: ErrValidation, Message: err.Error(), Err: err}
+		}
+		return models.Money{}, nil, fmt.Errorf("failed to price booking: %w", err)
+	}
+	if requested != nil && *requested != breakdown.Total {
+		return models.Money{}, nil, validationError(fmt.Sprintf("price %s does not match the current price %s",
+			requested, breakdown.Total))
+	}
+	return breakdown.Total, breakdown, nil
+}
+
+// RunCreditCheck performs the credit check for a pending booking and applies
+// the result. Only failures worth retrying are returned.
+func (s *BookingService) RunCreditCheck(ctx context.Context, bookingID string) error {
+	booking, err := s.GetBooking(bookingID)
+	if err != nil {
+		log.Printf("credit check for booking %s skipped: %v", bookingID, err)
+		return nil
+	}
+	if booking.Status != models.StatusPending {
+		// Canceled or expired before the check ran
+		return nil
+	}
+
+	result, err := s.creditChecker.Check(ctx, booking)
+	if err != nil {
+		return fmt.Errorf("credit check for booking %s failed: %w", bookingID, err)
+	}
+
+	change := models.StatusChange{
+		BookingID: result.BookingID,
+		To:        result.Status,
+		Actor:     models.ActorCreditCheck,
+		Reason:    creditCheckReason(result.Status),
+	}
+	if err := s.transitionStatus(change, 0, authz.Principal{}); err != nil {
+		// The booking may have been canceled or expired while the check was running
+		log.Printf("credit check result for booking %s discarded: %v", result.BookingID, err)
 	}
+	return nil
+}
+
+func creditCheckReason(status models.BookingStatus) string {
+	if status == models.StatusConfirmed {
+		return "credit check approved"
+	}
+	return "credit check declined"
+}
+
+// HandleCreditCheckJob adapts RunCreditCheck to the job queue handler signature
+func (s *BookingService) HandleCreditCheckJob(ctx context.Context, job *models.Job) error {
+	return s.RunCreditCheck(ctx, job.Payload)
 }
 
 func (s *BookingService) processCreditCheck(bookingID string) {
-	result := s.simulateCreditCheck(bookingID)
-	s.cache.UpdateBookingStatus(result.BookingID, result.Status)
+	if err := s.RunCreditCheck(context.Background(), bookingID); err != nil {
+		// The booking stays pending and is eventually canceled by the expiry sweeper
+		log.Print(err)
+	}
 }
 
-func checkExpiredTime(date time.Time) bool {
-	return date.Before(time.Now().Add(-5 * time.Minute))
+// scheduleCreditCheck hands the check to the job queue when one is configured
+func (s *BookingService) scheduleCreditCheck(bookingID string) {
+	if s.jobQueue == nil {
+		s.inFlight.Add(1)
+		go func() {
+			defer s.inFlight.Done()
+			s.processCreditCheck(bookingID)
+		}()
+		return
+	}
+	if _, err := s.jobQueue.Enqueue(models.JobTypeCreditCheck, bookingID); err != nil {
+		// The booking stays pending and is eventually canceled by the expiry sweeper
+		log.Printf("failed to enqueue credit check for booking %s: %v", bookingID, err)
+	}
+}
+
+// Wait blocks until credit checks started without a job queue have finished,
+// or until ctx expires. Checks handed to a job queue are drained by the queue.
+func (s *BookingService) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.inFlight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// transitionStatus is the single entry point for status writes. It validates
+// the change against the booking state machine and applies it to both the
+// repository and the cache with compare-and-swap on the booking version. A
+// non-zero expectedVersion must match the current version. The caller fills in
+// BookingID, To, Actor and Reason; the rest of the history entry is set here.
+// Changes listed in restrictedTransitions need the permission from principal;
+// system actors pass the zero Principal, which holds none.
+func (s *BookingService) transitionStatus(change models.StatusChange, expectedVersion int64, principal authz.Principal) error {
+	// The version check in the repository keeps changes from other processes
+	// apart; the per-booking lock also covers bookings only present in the
+	// cache and saves concurrent local changes a retry
+	defer s.statusLocks.lock(change.BookingID)()
+
+	bookingID, to := change.BookingID, change.To
+	for attempt := 1; ; attempt++ {
+		booking, err := s.GetBooking(bookingID)
+		if err != nil {
+			return err
+		}
+		version := booking.Version
+		if expectedVersion != 0 && version != expectedVersion {
+			return preconditionFailedError(fmt.Sprintf("booking is at version %d, not %d", version, expectedVersion))
+		}
+		if err := s.stateMachine.Transition(booking.Status, to); err != nil {
+			return invalidTransitionError(err)
+		}
+		if permission, ok := restrictedTransitions[[2]models.BookingStatus{booking.Status, to}]; ok {
+			if err := s.policy.Require(principal, permission); err != nil {
+				return forbiddenError(err)
+			}
+		}
+
+		change.From = booking.Status
+		change.ChangedAt = time.Now()
+		err = s.repository.UpdateBookingStatus(change, version, s.domainEvents(booking, change)...)
+		if errors.Is(err, repository.ErrVersionConflict) {
+			// The cached copy was stale; decide again on the stored booking
+			s.cache.DeleteBooking(bookingID)
+			if attempt < 2 {
+				continue
+			}
+			return &Error{Kind: ErrConflict, Message: "booking was modified concurrently", Err: err}
+		}
+		// A booking only present in the cache has nothing to update in the repository
+		if err != nil && !errors.Is(err, repository.ErrBookingNotFound) {
+			return fmt.Errorf("failed to update booking status: %w", err)
+		}
+
+		if !s.cache.UpdateBookingStatus(bookingID, to, version) {
+			// The cache holds some other version; let the next read reload it
+			s.cache.DeleteBooking(bookingID)
+		}
+		return nil
+	}
+}
+
+// domainEvents returns the events announcing change, which is about to be
+// applied to booking
+func (s *BookingService) domainEvents(booking *models.Booking, change models.StatusChange) []models.DomainEvent {
+	eventType, ok := models.DomainEventTypeFor(change.To)
+	if !ok {
+		return nil
+	}
+	after := *booking
+	after.Status = change.To
+	after.Version++
+	return []models.DomainEvent{{
+		ID:         s.idGenerator.NewID(),
+		Type:       eventType,
+		BookingID:  booking.ID,
+		Actor:      change.Actor,
+		Reason:     change.Reason,
+		OccurredAt: change.ChangedAt,
+		Booking:    after,
+	}}
+}
+
+func (s *BookingService) checkExpiredTime(date time.Time) bool {
+	return date.Before(time.Now().Add(-s.currentExpiryWindow()))
 }
 
 func (s *BookingService) CreateBooking(request models.BookingRequest) (*models.Booking, error) {
+	service, err := s.bookableService(request.ServiceID)
+	if err != nil {
+		return nil, err
+	}
+	quantity := max(request.Quantity, 1)
+	price, breakdown, err := s.priceBooking(service, quantity, request.Price)
+	if err != nil {
+		return nil, err
+	}
+
 	booking := &models.Booking{
-		ID:        utils.GenerateID(),
-		UserID:    request.UserID,
-		ServiceID: request.ServiceID,
-		Price:     request.Price,
-		Status:    models.StatusPending,
+		ID:             s.idGenerator.NewID(),
+		UserID:         request.UserID,
+		ServiceID:      request.ServiceID,
+		Quantity:       quantity,
+		Price:          price,
+		Status:         models.StatusPending,
+		CreatedAt:      time.Now().Truncate(time.Microsecond), // SQL timestamps keep microseconds
+		Version:        1,
+		PriceBreakdown: breakdown,
 	}
 
+	if err := s.repository.SaveBooking(booking); err != nil {
+		return nil, fmt.Errorf("failed to save booking: %w", err)
+	}
 	s.cache.SaveBooking(booking)
 
-	if s.requiresCreditCheck(booking.Price) {
-		go s.processCreditCheck(booking.ID)
+	if s.requiresCreditCheck(booking, service) {
+		s.scheduleCreditCheck(booking.ID)
 	}
 
 	return booking, nil
@@ -76,100 +487,135 @@ func (s *BookingService) GetBooking(bookingID string) (*models.Booking, error) {
 		return booking, nil
 	}
 
-	// If not in cache, try to get from mock repository
-	if booking, exists := s.mockRepository.GetBooking(bookingID); exists {
-		// Save to cache for future use
-		s.cache.SaveBooking(booking)
-		return booking, nil
+	// If not in cache, try to get from repository
+	booking, err := s.repository.GetBooking(bookingID)
+	if err != nil {
+		if errors.Is(err, repository.ErrBookingNotFound) {
+			return nil, notFoundError("booking not found")
+		}
+		return nil, fmt.Errorf("failed to load booking: %w", err)
 	}
 
-	return nil, fmt.Errorf("booking not found")
+	// Save to cache for future use
+	s.cache.SaveBooking(booking)
+	return booking, nil
 }
 
-func (s *BookingService) ListBookings(sortBy *models.SortOption, highValueOnly *bool) []*models.Booking {
-	// Get all bookings from cache and mock repository
-	allBookings := make([]*models.Booking, 0)
-	allBookings = append(allBookings, s.cache.GetAllBookings()...)
-	allBookings = append(allBookings, s.mockRepository.GetAllBookings()...)
+// GetBookingAt returns the booking as it was at the given time. Only
+// event-sourced storage keeps enough history to answer this.
+func (s *BookingService) GetBookingAt(bookingID string, at time.Time) (*models.Booking, error) {
+	reader, ok := s.repository.(repository.PointInTimeReader)
+	if !ok {
+		return nil, unsupportedError("point-in-time reads need event-sourced storage")
+	}
+	booking, err := reader.GetBookingAt(bookingID, at)
+	if err != nil {
+		if errors.Is(err, repository.ErrBookingNotFound) {
+			return nil, notFoundError("booking not found at the requested time")
+		}
+		return nil, fmt.Errorf("failed to rebuild booking: %w", err)
+	}
+	return booking, nil
+}
 
-	// Remove duplicates
-	uniqueBookings := make(map[string]*models.Booking)
-	for _, booking := range allBookings {
-		uniqueBookings[booking.ID] = booking
+// ListBookings returns one page of bookings. Limit defaults to DefaultPageSize
+// and is capped at MaxPageSize.
+func (s *BookingService) ListBookings(query models.BookingQuery) (*models.BookingPage, error) {
+	if query.Limit <= 0 {
+		query.Limit = DefaultPageSize
 	}
+	query.Limit = min(query.Limit, MaxPageSize)
 
-	allBookings = make([]*models.Booking, 0, len(uniqueBookings))
-	for _, booking := range uniqueBookings {
-		allBookings = append(allBookings, booking)
+	if query.Filter.HighValueOnly {
+		threshold := s.currentHighValue()
+		query.Filter.PriceAbove = &threshold
 	}
 
-	// Filter high-value bookings if requested
-	if highValueOnly != nil && *highValueOnly {
-		filteredBookings := make([]*models.Booking, 0)
-		for _, booking := range allBookings {
-			if booking.Price > 50000 {
-				filteredBookings = append(filteredBookings, booking)
-			}
+	page, err := s.repository.ListBookings(query)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidCursor) || errors.Is(err, repository.ErrInvalidSort) {
+			return nil, err
 		}
-		allBookings = filteredBookings
-	}
-
-	// Sort bookings
-	if sortBy != nil {
-		switch *sortBy {
-		case models.SortByPrice:
-			sort.Slice(allBookings, func(i, j int) bool {
-				return allBookings[i].Price < allBookings[j].Price
-			})
-		case models.SortByDate:
-			sort.Slice(allBookings, func(i, j int) bool {
-				return allBookings[i].CreatedAt.Before(allBookings[j].CreatedAt)
-			})
-		}
-	} else {
-		// Default sort by ID if sortBy is nil
-		sort.Slice(allBookings, func(i, j int) bool {
-			id1, err1 := strconv.Atoi(allBookings[i].ID)
-			id2, err2 := strconv.Atoi(allBookings[j].ID)
-			if err1 != nil || err2 != nil {
-				// Fallback to string comparison if conversion fails
-				return allBookings[i].ID < allBookings[j].ID
-			}
-			return id1 < id2
-		})
+		return nil, fmt.Errorf("failed to list bookings: %w", err)
 	}
-
-	return allBookings
+	return page, nil
 }
 
-func (s *BookingService) CancelBooking(bookingID string) error {
-	// Try to get from cache first
-	if booking, exists := s.cache.GetBooking(bookingID); exists {
-		if booking.Status == models.StatusConfirmed {
-			return fmt.Errorf("cannot cancel a confirmed booking")
+// CancelBooking cancels a booking on behalf of principal, the zero Principal
+// when the caller is not authenticated. Confirmed bookings can only be
+// canceled with authz.PermissionCancelConfirmedBooking. A non-zero
+// expectedVersion makes the cancel conditional on the booking still being at
+// that version.
+func (s *BookingService) CancelBooking(bookingID string, expectedVersion int64, principal authz.Principal) error {
+	change := models.StatusChange{
+		BookingID: bookingID,
+		To:        models.StatusCanceled,
+		Actor:     models.ActorUser,
+		Reason:    "canceled by user",
+	}
+	if principal.UserID != "" {
+		booking, err := s.GetBooking(bookingID)
+		if err != nil {
+			return err
 		}
-		// Check if booking exists in mock repository before deleting
-		if _, repoExists := s.mockRepository.GetBooking(bookingID); !repoExists {
-			return fmt.Errorf("booking not found in repository")
+		if booking.UserID != principal.UserID {
+			change.Actor = models.ActorStaff
+			change.Reason = "canceled by " + principal.UserID
 		}
-		// Delete from cache
-		s.cache.DeleteBooking(bookingID)
 	}
+	return s.transitionStatus(change, expectedVersion, principal)
+}
 
-	// Update status in mock repository
-	if exists := s.mockRepository.UpdateBookingStatus(bookingID, models.StatusCanceled); !exists {
-		return fmt.Errorf("booking not found")
+// GetBookingHistory returns the booking's status changes, oldest first
+func (s *BookingService) GetBookingHistory(bookingID string) ([]models.StatusChange, error) {
+	history, err := s.repository.GetStatusHistory(bookingID)
+	if errors.Is(err, repository.ErrBookingNotFound) {
+		// Bookings only present in the cache have no stored history
+		if _, err := s.GetBooking(bookingID); err != nil {
+			return nil, err
+		}
+		return []models.StatusChange{}, nil
 	}
-
-	return nil
+	if err != nil {
+		return nil, fmt.Errorf("failed to load booking history: %w", err)
+	}
+	return history, nil
 }
 
 func (s *BookingService) CancelExpiredBookings() {
-	pendingBookings := s.cache.GetAllBookings()
-	for _, booking := range pendingBookings {
-		if booking.Status == models.StatusPending && checkExpiredTime(booking.CreatedAt) {
-			s.cache.UpdateBookingStatus(booking.ID, models.StatusCanceled)
-			s.mockRepository.UpdateBookingStatus(booking.ID, models.StatusCanceled)
+	// The cache may hold bookings that were never persisted
+	for _, booking := range s.cache.GetAllBookings() {
+		s.cancelIfExpired(booking)
+	}
+
+	query := models.BookingQuery{
+		Filter: models.BookingFilter{Statuses: []models.BookingStatus{models.StatusPending}},
+		Limit:  sweepBatchSize,
+	}
+	for {
+		page, err := s.repository.ListBookings(query)
+		if err != nil {
+			log.Printf("expiry sweep failed: %v", err)
+			return
+		}
+		for _, booking := range page.Data {
+			s.cancelIfExpired(booking)
 		}
+		if page.NextCursor == "" {
+			return
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+func (s *BookingService) cancelIfExpired(booking *models.Booking) {
+	if booking.Status == models.StatusPending && s.checkExpiredTime(booking.CreatedAt) {
+		// Errors mean the booking changed state since it was listed, which is fine to skip
+		s.transitionStatus(models.StatusChange{
+			BookingID: booking.ID,
+			To:        models.StatusCanceled,
+			Actor:     models.ActorExpirySweeper,
+			Reason:    fmt.Sprintf("not confirmed within %s", s.currentExpiryWindow()),
+		}, 0, authz.Principal{})
 	}
 }
@@ -1,11 +1,16 @@
 package usecase
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/touchsung/spd-fiber-booking-system/authz"
 	"github.com/touchsung/spd-fiber-booking-system/models"
+	"github.com/touchsung/spd-fiber-booking-system/pricing"
 	"github.com/touchsung/spd-fiber-booking-system/repository"
 	"github.com/touchsung/spd-fiber-booking-system/utils"
 )
@@ -13,44 +18,73 @@ import (
 func setupTestService() *BookingService {
 	cache := utils.NewInMemoryCache()
 	mockRepo := repository.NewMockRepository()
-	service := NewBookingService(cache, mockRepo)
-	service.mockRepository = mockRepo
-	service.mockRepository.ClearBookings()
-	return service
+	mockRepo.ClearBookings()
+	return NewBookingService(cache, mockRepo)
+}
+
+// dollars returns a whole amount of models.DefaultCurrency
+func dollars(amount int64) models.Money {
+	return models.NewMoney(amount*100, models.DefaultCurrency)
+}
+
+// price returns a requested booking price of a whole amount of models.DefaultCurrency
+func price(amount int64) *models.Money {
+	money := dollars(amount)
+	return &money
 }
 
 func TestCheckExpiredTime(t *testing.T) {
+	service := setupTestService()
+
 	// Test with a time that is not expired
 	nonExpiredTime := time.Now().Add(-4 * time.Minute)
-	assert.False(t, checkExpiredTime(nonExpiredTime), "Expected non-expired time to return false")
+	assert.False(t, service.checkExpiredTime(nonExpiredTime), "Expected non-expired time to return false")
 
 	// Test with a time that is expired
 	expiredTime := time.Now().Add(-6 * time.Minute)
-	assert.True(t, checkExpiredTime(expiredTime), "Expected expired time to return true")
+	assert.True(t, service.checkExpiredTime(expiredTime), "Expected expired time to return true")
+
+	// Test with a configured window
+	service = NewBookingService(utils.NewInMemoryCache(), repository.NewMockRepository(), WithExpiryWindow(10*time.Minute))
+	assert.False(t, service.checkExpiredTime(expiredTime), "Expected time within a 10 minute window to return false")
 }
 
 func TestRequiresCreditCheck(t *testing.T) {
 	service := setupTestService()
 
 	// Test with a price below the threshold
-	assert.False(t, service.requiresCreditCheck(40000), "Expected no credit check for price below threshold")
+	assert.False(t, service.requiresCreditCheck(&models.Booking{Price: dollars(40000)}, nil), "Expected no credit check for price below threshold")
 
 	// Test with a price at the threshold
-	assert.False(t, service.requiresCreditCheck(50000), "Expected no credit check for price at threshold")
+	assert.False(t, service.requiresCreditCheck(&models.Booking{Price: dollars(50000)}, nil), "Expected no credit check for price at threshold")
 
 	// Test with a price above the threshold
-	assert.True(t, service.requiresCreditCheck(60000), "Expected credit check for price above threshold")
+	assert.True(t, service.requiresCreditCheck(&models.Booking{Price: dollars(60000)}, nil), "Expected credit check for price above threshold")
+
+	// Test with a configured threshold
+	service = NewBookingService(utils.NewInMemoryCache(), repository.NewMockRepository(), WithHighValueThreshold(dollars(1000)))
+	assert.True(t, service.requiresCreditCheck(&models.Booking{Price: dollars(1500)}, nil), "Expected credit check above a configured threshold")
+
+	// Test with the service's credit check policy
+	cheap, expensive := &models.Booking{Price: dollars(100)}, &models.Booking{Price: dollars(60000)}
+	always := &models.Service{CreditCheck: models.CreditCheckAlways}
+	never := &models.Service{CreditCheck: models.CreditCheckNever}
+	aboveThreshold := &models.Service{CreditCheck: models.CreditCheckAboveThreshold}
+	assert.True(t, service.requiresCreditCheck(cheap, always), "Expected credit check for every booking of an always-checked service")
+	assert.False(t, service.requiresCreditCheck(expensive, never), "Expected no credit check for a never-checked service")
+	assert.True(t, service.requiresCreditCheck(&models.Booking{Price: dollars(1500)}, aboveThreshold), "Expected the threshold to apply")
+	assert.False(t, service.requiresCreditCheck(&models.Booking{Price: dollars(500)}, aboveThreshold), "Expected the threshold to apply")
 }
 
 func TestGenerateRandomStatus(t *testing.T) {
-	service := setupTestService()
+	checker := NewSimulatedCreditChecker(0)
 
 	// Run multiple times to ensure both statuses are generated
 	statusCounts := make(map[models.BookingStatus]int)
 	iterations := 1000
 
 	for i := 0; i < iterations; i++ {
-		status := service.generateRandomStatus()
+		status := checker.generateRandomStatus()
 		statusCounts[status]++
 	}
 
@@ -71,7 +105,7 @@ func TestCreateBooking(t *testing.T) {
 			request: models.BookingRequest{
 				UserID:    "user1",
 				ServiceID: "service1",
-				Price:     40000,
+				Price:     price(40000),
 			},
 		},
 		{
@@ -79,7 +113,7 @@ func TestCreateBooking(t *testing.T) {
 			request: models.BookingRequest{
 				UserID:    "user2",
 				ServiceID: "service2",
-				Price:     60000,
+				Price:     price(60000),
 			},
 		},
 	}
@@ -92,7 +126,7 @@ func TestCreateBooking(t *testing.T) {
 			assert.NotEmpty(t, booking.ID)
 			assert.Equal(t, tt.request.UserID, booking.UserID)
 			assert.Equal(t, tt.request.ServiceID, booking.ServiceID)
-			assert.Equal(t, tt.request.Price, booking.Price)
+			assert.Equal(t, *tt.request.Price, booking.Price)
 			assert.Equal(t, models.StatusPending, booking.Status)
 		})
 	}
@@ -105,7 +139,7 @@ func TestGetBooking(t *testing.T) {
 	request := models.BookingRequest{
 		UserID:    "user1",
 		ServiceID: "service1",
-		Price:     40000,
+		Price:     price(40000),
 	}
 	booking, _ := service.CreateBooking(request)
 
@@ -128,46 +162,177 @@ func TestListBookings(t *testing.T) {
 	// Setup mock data
 	service := setupTestService()
 
-	// Add bookings to cache
-	service.cache.SaveBooking(&models.Booking{
+	// Add bookings to repository; listing reads from the repository, not the cache
+	service.repository.SaveBooking(&models.Booking{
 		ID:        "1",
 		UserID:    "user1",
 		ServiceID: "service1",
-		Price:     60000,
+		Price:     dollars(60000),
 		CreatedAt: time.Now().Add(-24 * time.Hour),
 		Status:    models.StatusPending,
 	})
 
 	// Add bookings to mock repository
-	service.mockRepository.SaveBooking(&models.Booking{
+	service.repository.SaveBooking(&models.Booking{
 		ID:        "2",
 		UserID:    "user2",
 		ServiceID: "service2",
-		Price:     40000,
+		Price:     dollars(40000),
 		CreatedAt: time.Now().Add(-48 * time.Hour),
 		Status:    models.StatusPending,
 	})
 
 	// Test without filters
-	bookings := service.ListBookings(nil, nil)
-	assert.Equal(t, 2, len(bookings), "Expected 2 bookings")
-	assert.Equal(t, "1", bookings[0].ID, "Expected booking ID 1")
+	page, err := service.ListBookings(models.BookingQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(page.Data), "Expected 2 bookings")
+	assert.Equal(t, 2, page.Total)
+	assert.Equal(t, "1", page.Data[0].ID, "Expected booking ID 1")
 
 	// Test high value filter
-	highValueOnly := true
-	bookings = service.ListBookings(nil, &highValueOnly)
-	assert.Equal(t, 1, len(bookings), "Expected 1 high-value booking")
-	assert.Equal(t, "1", bookings[0].ID, "Expected booking ID 1")
+	page, _ = service.ListBookings(models.BookingQuery{Filter: models.BookingFilter{HighValueOnly: true}})
+	assert.Equal(t, 1, len(page.Data), "Expected 1 high-value booking")
+	assert.Equal(t, "1", page.Data[0].ID, "Expected booking ID 1")
 
 	// Test sorting by price
-	sortByPrice := models.SortByPrice
-	bookings = service.ListBookings(&sortByPrice, nil)
-	assert.Equal(t, "2", bookings[0].ID, "Expected booking ID 2 to be first when sorted by price")
+	page, _ = service.ListBookings(models.BookingQuery{Sort: []models.SortKey{{Field: models.SortByPrice}}})
+	assert.Equal(t, "2", page.Data[0].ID, "Expected booking ID 2 to be first when sorted by price")
 
 	// Test sorting by date
-	sortByDate := models.SortByDate
-	bookings = service.ListBookings(&sortByDate, nil)
-	assert.Equal(t, "2", bookings[0].ID, "Expected booking ID 2 to be first when sorted by date")
+	page, _ = service.ListBookings(models.BookingQuery{Sort: []models.SortKey{{Field: models.SortByDate}}})
+	assert.Equal(t, "2", page.Data[0].ID, "Expected booking ID 2 to be first when sorted by date")
+}
+
+func TestListBookingsPagination(t *testing.T) {
+	service := setupTestService()
+	for i := 1; i <= 5; i++ {
+		service.repository.SaveBooking(&models.Booking{
+			ID:        fmt.Sprintf("%d", i),
+			Price:     dollars(int64(i) * 1000),
+			Status:    models.StatusConfirmed,
+			CreatedAt: time.Now(),
+		})
+	}
+
+	// Offset pagination
+	page, err := service.ListBookings(models.BookingQuery{Limit: 2, Offset: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, 5, page.Total)
+	assert.Equal(t, []string{"3", "4"}, bookingIDs(page.Data))
+
+	// Cursor pagination walks every booking exactly once
+	seen := make([]string, 0, 5)
+	query := models.BookingQuery{Limit: 2}
+	for {
+		page, err := service.ListBookings(query)
+		assert.NoError(t, err)
+		seen = append(seen, bookingIDs(page.Data)...)
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+	assert.Equal(t, []string{"1", "2", "3", "4", "5"}, seen)
+
+	// A cursor is only valid for the sort order it was issued for
+	page, _ = service.ListBookings(models.BookingQuery{Limit: 2})
+	_, err = service.ListBookings(models.BookingQuery{Limit: 2, Sort: []models.SortKey{{Field: models.SortByPrice}}, Cursor: page.NextCursor})
+	assert.ErrorIs(t, err, repository.ErrInvalidCursor)
+}
+
+func TestListBookingsMultiKeySort(t *testing.T) {
+	service := setupTestService()
+	baseTime := time.Now()
+	bookings := []*models.Booking{
+		{ID: "1", UserID: "user2", Price: dollars(20000), Status: models.StatusPending, CreatedAt: baseTime.Add(2 * time.Minute)},
+		{ID: "2", UserID: "user1", Price: dollars(30000), Status: models.StatusConfirmed, CreatedAt: baseTime},
+		{ID: "3", UserID: "user1", Price: dollars(20000), Status: models.StatusCanceled, CreatedAt: baseTime.Add(time.Minute)},
+		{ID: "4", UserID: "user2", Price: dollars(20000), Status: models.StatusPending, CreatedAt: baseTime.Add(2 * time.Minute)},
+	}
+	for _, booking := range bookings {
+		service.repository.SaveBooking(booking)
+	}
+
+	sortKeys := func(raw string) []models.SortKey {
+		keys, err := models.ParseSort(raw)
+		assert.NoError(t, err)
+		return keys
+	}
+
+	page, err := service.ListBookings(models.BookingQuery{Sort: sortKeys("-price,created_at")})
+	assert.NoError(t, err)
+	// 1 and 4 tie on both keys and fall back to ID order
+	assert.Equal(t, []string{"2", "3", "1", "4"}, bookingIDs(page.Data))
+
+	page, err = service.ListBookings(models.BookingQuery{Sort: sortKeys("user_id,-status")})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2", "3", "1", "4"}, bookingIDs(page.Data))
+
+	page, err = service.ListBookings(models.BookingQuery{Sort: sortKeys("-id")})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"4", "3", "2", "1"}, bookingIDs(page.Data))
+
+	// Cursors carry every sort key, including descending ones
+	seen := make([]string, 0, len(bookings))
+	query := models.BookingQuery{Sort: sortKeys("-price,created_at"), Limit: 1}
+	for {
+		page, err := service.ListBookings(query)
+		assert.NoError(t, err)
+		seen = append(seen, bookingIDs(page.Data)...)
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+	assert.Equal(t, []string{"2", "3", "1", "4"}, seen)
+
+	_, err = service.ListBookings(models.BookingQuery{Sort: []models.SortKey{{Field: "nickname"}}})
+	assert.ErrorIs(t, err, repository.ErrInvalidSort)
+}
+
+func bookingIDs(bookings []*models.Booking) []string {
+	ids := make([]string, len(bookings))
+	for i, booking := range bookings {
+		ids[i] = booking.ID
+	}
+	return ids
+}
+
+func TestListBookingsFilters(t *testing.T) {
+	service := setupTestService()
+	baseTime := time.Now().Truncate(time.Second)
+	bookings := []*models.Booking{
+		{ID: "1", UserID: "user1", ServiceID: "service1", Price: dollars(10000), Status: models.StatusPending, CreatedAt: baseTime},
+		{ID: "2", UserID: "user1", ServiceID: "service2", Price: dollars(60000), Status: models.StatusConfirmed, CreatedAt: baseTime.Add(time.Hour)},
+		{ID: "3", UserID: "user2", ServiceID: "service1", Price: dollars(30000), Status: models.StatusCanceled, CreatedAt: baseTime.Add(2 * time.Hour)},
+		{ID: "4", UserID: "user2", ServiceID: "service2", Price: dollars(80000), Status: models.StatusRejected, CreatedAt: baseTime.Add(3 * time.Hour)},
+	}
+	for _, booking := range bookings {
+		service.repository.SaveBooking(booking)
+	}
+
+	minPrice, maxPrice := dollars(30000), dollars(60000)
+	from, before := baseTime.Add(time.Hour), baseTime.Add(3*time.Hour)
+	tests := []struct {
+		name     string
+		filter   models.BookingFilter
+		expected []string
+	}{
+		{"user", models.BookingFilter{UserID: "user2"}, []string{"3", "4"}},
+		{"service", models.BookingFilter{ServiceID: "service1"}, []string{"1", "3"}},
+		{"statuses", models.BookingFilter{Statuses: []models.BookingStatus{models.StatusPending, models.StatusRejected}}, []string{"1", "4"}},
+		{"price range is inclusive", models.BookingFilter{MinPrice: &minPrice, MaxPrice: &maxPrice}, []string{"2", "3"}},
+		{"created range excludes the upper bound", models.BookingFilter{CreatedFrom: &from, CreatedBefore: &before}, []string{"2", "3"}},
+		{"filters combine", models.BookingFilter{UserID: "user1", HighValueOnly: true}, []string{"2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := service.ListBookings(models.BookingQuery{Filter: tt.filter})
+			assert.NoError(t, err)
+			assert.Equal(t, len(tt.expected), page.Total)
+			assert.Equal(t, tt.expected, bookingIDs(page.Data))
+		})
+	}
 }
 
 func TestCancelBooking(t *testing.T) {
@@ -178,34 +343,53 @@ func TestCancelBooking(t *testing.T) {
 		ID:        "pending-booking",
 		UserID:    "user1",
 		ServiceID: "service1",
-		Price:     40000,
+		Price:     dollars(40000),
 		Status:    models.StatusPending,
 	}
 	service.cache.SaveBooking(pendingBooking)
-	service.mockRepository.SaveBooking(pendingBooking)
+	service.repository.SaveBooking(pendingBooking)
 
 	// Create a confirmed booking
 	confirmedBooking := &models.Booking{
 		ID:        "confirmed-booking",
 		UserID:    "user2",
 		ServiceID: "service2",
-		Price:     60000,
+		Price:     dollars(60000),
 		Status:    models.StatusConfirmed,
 	}
 	service.cache.SaveBooking(confirmedBooking)
-	service.mockRepository.SaveBooking(confirmedBooking)
+	service.repository.SaveBooking(confirmedBooking)
 
 	// Test canceling a pending booking
-	err := service.CancelBooking(pendingBooking.ID)
+	err := service.CancelBooking(pendingBooking.ID, 0, authz.Principal{})
 	assert.NoError(t, err, "Expected no error when canceling a pending booking")
 
 	// Test canceling a confirmed booking
-	err = service.CancelBooking(confirmedBooking.ID)
+	err = service.CancelBooking(confirmedBooking.ID, 0, authz.Principal{})
 	assert.Error(t, err, "Expected an error when canceling a confirmed booking")
+	assert.ErrorIs(t, err, ErrForbidden)
+	var forbidden *authz.ForbiddenError
+	if assert.ErrorAs(t, err, &forbidden) {
+		assert.Equal(t, authz.PermissionCancelConfirmedBooking, forbidden.Permission)
+	}
+	owner := authz.Principal{UserID: "user2", Roles: []authz.Role{authz.RoleCustomer}}
+	assert.ErrorIs(t, service.CancelBooking(confirmedBooking.ID, 0, owner), ErrForbidden)
+
+	// Support staff may cancel confirmed bookings, which is recorded as a staff action
+	support := authz.Principal{UserID: "agent1", Roles: []authz.Role{authz.RoleSupport}}
+	assert.NoError(t, service.CancelBooking(confirmedBooking.ID, 0, support))
+	history, err := service.GetBookingHistory(confirmedBooking.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, history, 1) {
+		assert.Equal(t, models.StatusConfirmed, history[0].From)
+		assert.Equal(t, models.ActorStaff, history[0].Actor)
+	}
+	assert.ErrorIs(t, service.CancelBooking(confirmedBooking.ID, 0, support), ErrInvalidTransition)
 
 	// Test canceling a non-existent booking
-	err = service.CancelBooking("non-existent-booking")
+	err = service.CancelBooking("non-existent-booking", 0, authz.Principal{})
 	assert.Error(t, err, "Expected an error when canceling a non-existent booking")
+	assert.ErrorIs(t, err, ErrNotFound)
 }
 
 func TestCancelExpiredBookings(t *testing.T) {
@@ -216,7 +400,7 @@ func TestCancelExpiredBookings(t *testing.T) {
 		ID:        "non-expired-booking",
 		UserID:    "user1",
 		ServiceID: "service1",
-		Price:     40000,
+		Price:     dollars(40000),
 		Status:    models.StatusPending,
 		CreatedAt: time.Now(),
 	}
@@ -224,7 +408,7 @@ func TestCancelExpiredBookings(t *testing.T) {
 		ID:        "expired-booking",
 		UserID:    "user2",
 		ServiceID: "service2",
-		Price:     60000,
+		Price:     dollars(60000),
 		Status:    models.StatusPending,
 		CreatedAt: time.Now().Add(-10 * time.Minute), // Set to expired
 	}
@@ -244,3 +428,308 @@ func TestCancelExpiredBookings(t *testing.T) {
 	updatedExpiredBooking, _ := service.cache.GetBooking(expiredBooking.ID)
 	assert.Equal(t, models.StatusCanceled, updatedExpiredBooking.Status, "Expected expired booking to be canceled")
 }
+
+type sequenceIDGenerator struct {
+	next int
+}
+
+func (g *sequenceIDGenerator) NewID() string {
+	g.next++
+	return fmt.Sprintf("test-%03d", g.next)
+}
+
+func TestCreateBookingUsesInjectedIDGenerator(t *testing.T) {
+	mockRepo := repository.NewMockRepository()
+	mockRepo.ClearBookings()
+	service := NewBookingService(utils.NewInMemoryCache(), mockRepo, WithIDGenerator(&sequenceIDGenerator{}))
+
+	first, err := service.CreateBooking(models.BookingRequest{UserID: "user1", ServiceID: "service1", Price: price(100)})
+	assert.NoError(t, err)
+	second, err := service.CreateBooking(models.BookingRequest{UserID: "user1", ServiceID: "service1", Price: price(100)})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "test-001", first.ID)
+	assert.Equal(t, "test-002", second.ID)
+}
+
+func TestListBookingsDefaultSortFollowsCreationOrder(t *testing.T) {
+	service := setupTestService()
+
+	// Bookings created within the same second must not collide and must list in creation order
+	created := make([]string, 0, 5)
+	for i := 0; i < 5; i++ {
+		booking, err := service.CreateBooking(models.BookingRequest{UserID: "user1", ServiceID: "service1", Price: price(100)})
+		assert.NoError(t, err)
+		created = append(created, booking.ID)
+	}
+
+	page, err := service.ListBookings(models.BookingQuery{})
+	assert.NoError(t, err)
+	assert.Len(t, page.Data, len(created))
+	for i, booking := range page.Data {
+		assert.Equal(t, created[i], booking.ID)
+	}
+}
+
+func TestLateCreditCheckCannotReviveCanceledBooking(t *testing.T) {
+	service := setupTestService()
+
+	booking, err := service.CreateBooking(models.BookingRequest{UserID: "user1", ServiceID: "service1", Price: price(100)})
+	assert.NoError(t, err)
+	assert.NoError(t, service.CancelBooking(booking.ID, 0, authz.Principal{}))
+
+	// A credit check finishing after the cancel must be rejected by the state machine
+	err = service.transitionStatus(models.StatusChange{BookingID: booking.ID, To: models.StatusConfirmed, Actor: models.ActorCreditCheck}, 0, authz.Principal{})
+	var transitionErr *models.InvalidTransitionError
+	assert.True(t, errors.As(err, &transitionErr), "Expected an InvalidTransitionError")
+
+	found, err := service.GetBooking(booking.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.StatusCanceled, found.Status)
+}
+
+func TestWaitForInFlightCreditChecks(t *testing.T) {
+	service := setupTestService()
+	service.creditChecker = NewSimulatedCreditChecker(50 * time.Millisecond)
+
+	booking, err := service.CreateBooking(models.BookingRequest{UserID: "user1", ServiceID: "service1", Price: price(60000)})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, service.Wait(ctx), context.DeadlineExceeded)
+
+	assert.NoError(t, service.Wait(context.Background()))
+	found, err := service.GetBooking(booking.ID)
+	assert.NoError(t, err)
+	assert.NotEqual(t, models.StatusPending, found.Status, "Expected the credit check to have completed")
+}
+
+func TestCancelBookingChecksVersion(t *testing.T) {
+	service := setupTestService()
+
+	booking, err := service.CreateBooking(models.BookingRequest{UserID: "user1", ServiceID: "service1", Price: price(100)})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), booking.Version)
+
+	err = service.CancelBooking(booking.ID, 2, authz.Principal{})
+	assert.ErrorIs(t, err, ErrPreconditionFailed)
+
+	assert.NoError(t, service.CancelBooking(booking.ID, 1, authz.Principal{}))
+	found, err := service.GetBooking(booking.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.StatusCanceled, found.Status)
+	assert.Equal(t, int64(2), found.Version)
+}
+
+func TestTransitionStatusRecoversFromStaleCache(t *testing.T) {
+	service := setupTestService()
+	createdAt := time.Now()
+
+	// Another instance already moved the stored booking to version 2
+	service.repository.SaveBooking(&models.Booking{ID: "1", Status: models.StatusPending, CreatedAt: createdAt, Version: 2})
+	service.cache.SaveBooking(&models.Booking{ID: "1", Status: models.StatusPending, CreatedAt: createdAt, Version: 1})
+
+	assert.NoError(t, service.CancelBooking("1", 0, authz.Principal{}))
+	stored, err := service.repository.GetBooking("1")
+	assert.NoError(t, err)
+	assert.Equal(t, models.StatusCanceled, stored.Status)
+	assert.Equal(t, int64(3), stored.Version)
+
+	// A caller holding the stale version is told so instead of overwriting
+	service.repository.SaveBooking(&models.Booking{ID: "2", Status: models.StatusPending, CreatedAt: createdAt, Version: 2})
+	service.cache.SaveBooking(&models.Booking{ID: "2", Status: models.StatusPending, CreatedAt: createdAt, Version: 1})
+	assert.ErrorIs(t, service.CancelBooking("2", 1, authz.Principal{}), ErrPreconditionFailed)
+}
+
+func TestBookingHistoryRecordsActorAndReason(t *testing.T) {
+	service := setupTestService()
+	service.expiryWindow = time.Minute
+
+	canceled, err := service.CreateBooking(models.BookingRequest{UserID: "user1", ServiceID: "service1", Price: price(100)})
+	assert.NoError(t, err)
+	assert.NoError(t, service.CancelBooking(canceled.ID, 0, authz.Principal{}))
+
+	expired := &models.Booking{ID: "expired", Status: models.StatusPending, CreatedAt: time.Now().Add(-time.Hour), Version: 1}
+	service.repository.SaveBooking(expired)
+	service.CancelExpiredBookings()
+
+	untouched, err := service.CreateBooking(models.BookingRequest{UserID: "user1", ServiceID: "service1", Price: price(100)})
+	assert.NoError(t, err)
+
+	history, err := service.GetBookingHistory(canceled.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, history, 1) {
+		assert.Equal(t, models.StatusPending, history[0].From)
+		assert.Equal(t, models.StatusCanceled, history[0].To)
+		assert.Equal(t, models.ActorUser, history[0].Actor)
+		assert.Equal(t, int64(2), history[0].Version)
+		assert.False(t, history[0].ChangedAt.IsZero())
+	}
+
+	history, err = service.GetBookingHistory(expired.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, history, 1) {
+		assert.Equal(t, models.ActorExpirySweeper, history[0].Actor)
+		assert.Equal(t, "not confirmed within 1m0s", history[0].Reason)
+	}
+
+	history, err = service.GetBookingHistory(untouched.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, history)
+
+	_, err = service.GetBookingHistory("missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestCreditCheckIsRecordedInHistory(t *testing.T) {
+	service := setupTestService()
+	service.creditChecker = NewSimulatedCreditChecker(0)
+
+	booking, err := service.CreateBooking(models.BookingRequest{UserID: "user1", ServiceID: "service1", Price: price(60000)})
+	assert.NoError(t, err)
+	assert.NoError(t, service.Wait(context.Background()))
+
+	history, err := service.GetBookingHistory(booking.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, history, 1) {
+		assert.Equal(t, models.ActorCreditCheck, history[0].Actor)
+		assert.Equal(t, creditCheckReason(history[0].To), history[0].Reason)
+	}
+}
+
+func TestGetBookingAtNeedsEventSourcing(t *testing.T) {
+	service := setupTestService()
+	_, err := service.GetBookingAt("1", time.Now())
+	assert.ErrorIs(t, err, ErrUnsupported)
+
+	service = NewBookingService(utils.NewInMemoryCache(),
+		repository.NewEventSourcedRepository(repository.NewMemoryEventStore()))
+	booking, err := service.CreateBooking(models.BookingRequest{UserID: "user1", ServiceID: "service1", Price: price(100)})
+	assert.NoError(t, err)
+	assert.NoError(t, service.CancelBooking(booking.ID, 0, authz.Principal{}))
+
+	past, err := service.GetBookingAt(booking.ID, booking.CreatedAt)
+	if assert.NoError(t, err) {
+		assert.Equal(t, models.StatusPending, past.Status)
+	}
+
+	current, err := service.GetBookingAt(booking.ID, time.Now())
+	if assert.NoError(t, err) {
+		assert.Equal(t, models.StatusCanceled, current.Status)
+	}
+
+	_, err = service.GetBookingAt(booking.ID, booking.CreatedAt.Add(-time.Second))
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	history, err := service.GetBookingHistory(booking.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, history, 1) {
+		assert.Equal(t, models.ActorUser, history[0].Actor)
+		assert.Equal(t, "canceled by user", history[0].Reason)
+	}
+}
+
+func TestCreateBookingChecksServiceCatalog(t *testing.T) {
+	services := repository.NewMockServiceRepository()
+	assert.NoError(t, services.CreateService(&models.Service{ID: "retired", Name: "Retired", Active: false, CreditCheck: models.CreditCheckAboveThreshold}))
+	assert.NoError(t, services.CreateService(&models.Service{ID: "vetted", Name: "Vetted", BasePrice: dollars(100), Active: true, CreditCheck: models.CreditCheckAlways}))
+
+	repo := repository.NewMockRepository()
+	repo.ClearBookings()
+	service := NewBookingService(utils.NewInMemoryCache(), repo,
+		WithServiceCatalog(services), WithCreditChecker(NewSimulatedCreditChecker(0)))
+
+	_, err := service.CreateBooking(models.BookingRequest{UserID: "user1", ServiceID: "service_does_not_exist", Price: price(100)})
+	assert.ErrorIs(t, err, ErrValidation)
+	assert.EqualError(t, err, "service service_does_not_exist does not exist")
+
+	_, err = service.CreateBooking(models.BookingRequest{UserID: "user1", ServiceID: "retired", Price: price(100)})
+	assert.ErrorIs(t, err, ErrValidation)
+
+	yen := NewBookingService(utils.NewInMemoryCache(), repo, WithServiceCatalog(services), WithCurrency("JPY"))
+	_, err = yen.CreateBooking(models.BookingRequest{UserID: "user1", ServiceID: "vetted"})
+	assert.EqualError(t, err, "service vetted is priced in USD but bookings are priced in JPY")
+
+	page, err := service.ListBookings(models.BookingQuery{})
+	assert.NoError(t, err)
+	assert.Zero(t, page.Total, "rejected requests store nothing")
+
+	// A low price still gets a credit check when the service always needs one
+	booking, err := service.CreateBooking(models.BookingRequest{UserID: "user1", ServiceID: "vetted", Price: price(100)})
+	assert.NoError(t, err)
+	assert.NoError(t, service.Wait(context.Background()))
+	history, err := service.GetBookingHistory(booking.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, history, 1) {
+		assert.Equal(t, models.ActorCreditCheck, history[0].Actor)
+	}
+}
+
+func TestCreateBookingPricesOnServer(t *testing.T) {
+	services := repository.NewMockServiceRepository()
+	assert.NoError(t, services.CreateService(&models.Service{ID: "transfer", Name: "Transfer", BasePrice: dollars(12000), Active: true, CreditCheck: models.CreditCheckAboveThreshold}))
+	repo := repository.NewMockRepository()
+	repo.ClearBookings()
+	service := NewBookingService(utils.NewInMemoryCache(), repo,
+		WithServiceCatalog(services),
+		WithPricingEngine(pricing.NewEngine(pricing.VolumeDiscount{Tiers: []pricing.VolumeTier{{MinQuantity: 5, Percent: 10}}})),
+		WithCreditChecker(NewSimulatedCreditChecker(0)))
+
+	single, err := service.CreateBooking(models.BookingRequest{UserID: "user1", ServiceID: "transfer"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, single.Quantity)
+	assert.Equal(t, dollars(12000), single.Price)
+	assert.Empty(t, single.PriceBreakdown.Adjustments)
+
+	// A client cannot dodge the credit check by sending a lower price
+	_, err = service.CreateBooking(models.BookingRequest{UserID: "user1", ServiceID: "transfer", Quantity: 5, Price: price(100)})
+	assert.ErrorIs(t, err, ErrValidation)
+	assert.EqualError(t, err, "price 100.00 USD does not match the current price 54000.00 USD")
+	euros := models.NewMoney(5400000, "EUR")
+	_, err = service.CreateBooking(models.BookingRequest{UserID: "user1", ServiceID: "transfer", Quantity: 5, Price: &euros})
+	assert.EqualError(t, err, "price is in EUR but bookings are priced in USD")
+
+	bulk, err := service.CreateBooking(models.BookingRequest{UserID: "user1", ServiceID: "transfer", Quantity: 5, Price: price(54000)})
+	assert.NoError(t, err)
+	assert.Equal(t, dollars(54000), bulk.Price)
+	if assert.NotNil(t, bulk.PriceBreakdown) {
+		assert.Equal(t, dollars(60000), bulk.PriceBreakdown.Subtotal)
+		assert.Len(t, bulk.PriceBreakdown.Adjustments, 1)
+	}
+
+	// The computed price is above the threshold, so the credit check runs
+	assert.NoError(t, service.Wait(context.Background()))
+	history, err := service.GetBookingHistory(bulk.ID)
+	assert.NoError(t, err)
+	assert.Len(t, history, 1)
+	history, err = service.GetBookingHistory(single.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, history)
+}
+
+func TestUpdateSettings(t *testing.T) {
+	service := setupTestService()
+	assert.Equal(t, models.BookingSettings{HighValueThreshold: dollars(DefaultHighValueThreshold), ExpiryWindow: "5m0s"}, service.Settings())
+
+	settings, err := service.UpdateSettings(models.BookingSettingsRequest{HighValueThreshold: price(1000)})
+	assert.NoError(t, err)
+	assert.Equal(t, models.BookingSettings{HighValueThreshold: dollars(1000), ExpiryWindow: "5m0s"}, settings)
+	assert.True(t, service.requiresCreditCheck(&models.Booking{Price: dollars(1500)}, nil), "Expected the new threshold to apply")
+
+	_, err = service.UpdateSettings(models.BookingSettingsRequest{ExpiryWindow: "10m"})
+	assert.NoError(t, err)
+	assert.False(t, service.checkExpiredTime(time.Now().Add(-6*time.Minute)), "Expected the new window to apply")
+
+	for _, request := range []models.BookingSettingsRequest{
+		{HighValueThreshold: &models.Money{Amount: 100, Currency: "EUR"}},
+		{HighValueThreshold: &models.Money{Amount: -100, Currency: models.DefaultCurrency}},
+		{ExpiryWindow: "soon"},
+		{ExpiryWindow: "-1m"},
+	} {
+		_, err := service.UpdateSettings(request)
+		assert.ErrorIs(t, err, ErrValidation)
+	}
+	assert.Equal(t, models.BookingSettings{HighValueThreshold: dollars(1000), ExpiryWindow: "10m0s"}, service.Settings(),
+		"Expected rejected changes to leave the settings alone")
+}
@@ -0,0 +1,125 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/touchsung/spd-fiber-booking-system/models"
+	"github.com/touchsung/spd-fiber-booking-system/repository"
+	"github.com/touchsung/spd-fiber-booking-system/utils"
+)
+
+// CatalogService manages the services that bookings can be made for
+type CatalogService struct {
+	repository  repository.ServiceRepository
+	currency    string
+	idGenerator utils.IDGenerator
+	now         func() time.Time
+}
+
+// NewCatalogService returns a catalog whose services are priced in currency
+func NewCatalogService(repo repository.ServiceRepository, currency string) *CatalogService {
+	return &CatalogService{
+		repository:  repo,
+		currency:    currency,
+		idGenerator: utils.NewULIDGenerator(),
+		now:         time.Now,
+	}
+}
+
+// CreateService adds a service to the catalog under the requested ID, or a
+// generated one when the request has none
+func (s *CatalogService) CreateService(request models.ServiceRequest) (*models.Service, error) {
+	now := s.now().Truncate(time.Microsecond) // SQL timestamps keep microseconds
+	service := &models.Service{
+		ID:        request.ID,
+		CreatedAt: now,
+	}
+	if service.ID == "" {
+		service.ID = s.idGenerator.NewID()
+	}
+	if err := s.applyServiceRequest(service, request, now); err != nil {
+		return nil, err
+	}
+
+	if err := s.repository.CreateService(service); err != nil {
+		if errors.Is(err, repository.ErrServiceExists) {
+			return nil, &Error{Kind: ErrConflict, Message: fmt.Sprintf("service %s already exists", service.ID), Err: err}
+		}
+		return nil, fmt.Errorf("failed to save service: %w", err)
+	}
+	return service, nil
+}
+
+// UpdateService replaces the editable fields of a service. Bookings made
+// before the change keep their price.
+func (s *CatalogService) UpdateService(serviceID string, request models.ServiceRequest) (*models.Service, error) {
+	service, err := s.GetService(serviceID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.applyServiceRequest(service, request, s.now().Truncate(time.Microsecond)); err != nil {
+		return nil, err
+	}
+
+	if err := s.repository.UpdateService(service); err != nil {
+		if errors.Is(err, repository.ErrServiceNotFound) {
+			return nil, notFoundError("service not found")
+		}
+		return nil, fmt.Errorf("failed to save service: %w", err)
+	}
+	return service, nil
+}
+
+// applyServiceRequest copies the request onto service, filling in the defaults
+func (s *CatalogService) applyServiceRequest(service *models.Service, request models.ServiceRequest, now time.Time) error {
+	if request.BasePrice.Currency == "" {
+		return validationError("base price is required")
+	}
+	if request.BasePrice.Currency != s.currency {
+		return validationError(fmt.Sprintf("base price is in %s but services are priced in %s",
+			request.BasePrice.Currency, s.currency))
+	}
+	service.Name = request.Name
+	service.BasePrice = request.BasePrice
+	service.Active = request.Active == nil || *request.Active
+	service.CreditCheck = request.CreditCheck
+	if service.CreditCheck == "" {
+		service.CreditCheck = models.CreditCheckAboveThreshold
+	}
+	service.UpdatedAt = now
+	return nil
+}
+
+func (s *CatalogService) GetService(serviceID string) (*models.Service, error) {
+	service, err := s.repository.GetService(serviceID)
+	if err != nil {
+		if errors.Is(err, repository.ErrServiceNotFound) {
+			return nil, notFoundError("service not found")
+		}
+		return nil, fmt.Errorf("failed to load service: %w", err)
+	}
+	return service, nil
+}
+
+// ListServices returns the catalog ordered by ID, only the active services when activeOnly is set
+func (s *CatalogService) ListServices(activeOnly bool) ([]*models.Service, error) {
+	services, err := s.repository.ListServices(activeOnly)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list services: %w", err)
+	}
+	return services, nil
+}
+
+// DeleteService removes a service from the catalog. Existing bookings keep
+// their service_id; deactivate the service instead to keep it readable.
+func (s *CatalogService) DeleteService(serviceID string) error {
+	if err := s.repository.DeleteService(serviceID); err != nil {
+		if errors.Is(err, repository.ErrServiceNotFound) {
+			return notFoundError("service not found")
+		}
+		return fmt.Errorf("failed to delete service: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/touchsung/spd-fiber-booking-system/models"
+	"github.com/touchsung/spd-fiber-booking-system/repository"
+)
+
+func setupCatalogService() *CatalogService {
+	repo := repository.NewMockServiceRepository()
+	repo.ClearServices()
+	return NewCatalogService(repo, models.DefaultCurrency)
+}
+
+func TestCatalogServiceLifecycle(t *testing.T) {
+	catalog := setupCatalogService()
+
+	created, err := catalog.CreateService(models.ServiceRequest{ID: "transfer", Name: "Airport transfer", BasePrice: dollars(1200)})
+	require.NoError(t, err)
+	assert.Equal(t, "transfer", created.ID)
+	assert.True(t, created.Active, "services are active by default")
+	assert.Equal(t, models.CreditCheckAboveThreshold, created.CreditCheck)
+
+	_, err = catalog.CreateService(models.ServiceRequest{ID: "transfer", Name: "Duplicate", BasePrice: dollars(1200)})
+	assert.ErrorIs(t, err, ErrConflict)
+
+	generated, err := catalog.CreateService(models.ServiceRequest{Name: "Tour", BasePrice: dollars(300)})
+	require.NoError(t, err)
+	assert.NotEmpty(t, generated.ID)
+
+	inactive := false
+	updated, err := catalog.UpdateService("transfer", models.ServiceRequest{
+		Name: "Airport transfer", BasePrice: dollars(1500), Active: &inactive, CreditCheck: models.CreditCheckAlways,
+	})
+	require.NoError(t, err)
+	assert.False(t, updated.Active)
+	assert.Equal(t, dollars(1500), updated.BasePrice)
+	assert.Equal(t, created.CreatedAt, updated.CreatedAt)
+
+	// Services are priced in the catalog's currency only
+	_, err = catalog.UpdateService("transfer", models.ServiceRequest{Name: "Airport transfer", BasePrice: models.NewMoney(1500, "EUR")})
+	assert.ErrorIs(t, err, ErrValidation)
+	_, err = catalog.CreateService(models.ServiceRequest{Name: "Free"})
+	assert.ErrorIs(t, err, ErrValidation)
+
+	found, err := catalog.GetService("transfer")
+	require.NoError(t, err)
+	assert.Equal(t, updated, found)
+
+	all, err := catalog.ListServices(false)
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+	active, err := catalog.ListServices(true)
+	require.NoError(t, err)
+	if assert.Len(t, active, 1) {
+		assert.Equal(t, generated.ID, active[0].ID)
+	}
+
+	require.NoError(t, catalog.DeleteService("transfer"))
+	assert.ErrorIs(t, catalog.DeleteService("transfer"), ErrNotFound)
+	_, err = catalog.GetService("transfer")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = catalog.UpdateService("transfer", models.ServiceRequest{Name: "Gone"})
+	assert.ErrorIs(t, err, ErrNotFound)
+}
@@ -0,0 +1,195 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/touchsung/spd-fiber-booking-system/authz"
+	"github.com/touchsung/spd-fiber-booking-system/jobs"
+	"github.com/touchsung/spd-fiber-booking-system/models"
+	"github.com/touchsung/spd-fiber-booking-system/repository"
+	"github.com/touchsung/spd-fiber-booking-system/utils"
+)
+
+// These tests exercise the usecase from many goroutines at once and are meant
+// to be run with -race. Besides the race detector they check that every
+// booking took at most one transition out of pending, which shows up as the
+// version (1 while pending, 2 once final) and in the length of its history.
+
+const concurrentBookings = 40
+
+// runConcurrentFlows creates bookings from many goroutines while readers,
+// cancels and the expiry sweeper run alongside, and returns the created IDs
+func runConcurrentFlows(t *testing.T, service *BookingService) []string {
+	t.Helper()
+
+	ids := make(chan string, concurrentBookings)
+	stopSweeper := make(chan struct{})
+	var sweeper sync.WaitGroup
+	sweeper.Add(1)
+	go func() {
+		defer sweeper.Done()
+		for {
+			select {
+			case <-stopSweeper:
+				return
+			default:
+				service.CancelExpiredBookings()
+			}
+		}
+	}()
+
+	var clients sync.WaitGroup
+	for i := 0; i < concurrentBookings; i++ {
+		clients.Add(1)
+		go func(i int) {
+			defer clients.Done()
+			amount := price(1000)
+			if i%2 == 0 {
+				amount = price(60000) // needs a credit check
+			}
+			booking, err := service.CreateBooking(models.BookingRequest{
+				UserID:    fmt.Sprintf("user%d", i%3),
+				ServiceID: "service1",
+				Price:     amount,
+			})
+			if !assert.NoError(t, err) {
+				return
+			}
+			ids <- booking.ID
+
+			// Callers own what they get back; writing to it must not race with storage
+			booking.Status = "tampered"
+
+			if i%4 == 1 {
+				// Losing to the sweeper or a credit check is expected
+				_ = service.CancelBooking(booking.ID, 0, authz.Principal{})
+			}
+			found, err := service.GetBooking(booking.ID)
+			if assert.NoError(t, err) {
+				assert.NotEqual(t, models.BookingStatus("tampered"), found.Status)
+			}
+			_, err = service.ListBookings(models.BookingQuery{Filter: models.BookingFilter{UserID: booking.UserID}})
+			assert.NoError(t, err)
+		}(i)
+	}
+	clients.Wait()
+	close(stopSweeper)
+	sweeper.Wait()
+	close(ids)
+
+	result := make([]string, 0, concurrentBookings)
+	for id := range ids {
+		result = append(result, id)
+	}
+	return result
+}
+
+func assertSingleTransition(t *testing.T, service *BookingService, ids []string) {
+	t.Helper()
+	require.Len(t, ids, concurrentBookings)
+	for _, id := range ids {
+		stored, err := service.repository.GetBooking(id)
+		require.NoError(t, err)
+		assert.True(t, stored.Status.Valid(), "booking %s has status %q", id, stored.Status)
+		if stored.Status == models.StatusPending {
+			assert.Equal(t, int64(1), stored.Version, "booking %s", id)
+		} else {
+			assert.Equal(t, int64(2), stored.Version, "booking %s changed status more than once", id)
+		}
+
+		history, err := service.repository.GetStatusHistory(id)
+		require.NoError(t, err)
+		assert.Len(t, history, int(stored.Version-1), "booking %s history does not match its version", id)
+
+		if cached, ok := service.cache.GetBooking(id); ok {
+			assert.Equal(t, *stored, *cached, "cache disagrees with repository for booking %s", id)
+		}
+	}
+}
+
+func TestConcurrentFlowsWithGoroutineCreditChecks(t *testing.T) {
+	repo := repository.NewMockRepository()
+	repo.ClearBookings()
+	service := NewBookingService(utils.NewInMemoryCache(), repo,
+		WithCreditChecker(NewSimulatedCreditChecker(time.Millisecond)),
+		// Everything pending is immediately expired, so the sweeper races the checks
+		WithExpiryWindow(time.Nanosecond),
+	)
+
+	ids := runConcurrentFlows(t, service)
+	require.NoError(t, service.Wait(context.Background()))
+	assertSingleTransition(t, service, ids)
+}
+
+func TestConcurrentFlowsWithJobQueue(t *testing.T) {
+	repo := repository.NewMockRepository()
+	repo.ClearBookings()
+	config := jobs.DefaultConfig()
+	config.InitialBackoff = time.Millisecond
+	queue := jobs.NewQueue(repository.NewMockJobRepository(), config)
+	service := NewBookingService(utils.NewInMemoryCache(), repo,
+		WithCreditChecker(NewSimulatedCreditChecker(time.Millisecond)),
+		WithExpiryWindow(time.Nanosecond),
+		WithJobQueue(queue),
+	)
+	queue.Register(models.JobTypeCreditCheck, service.HandleCreditCheckJob)
+	require.NoError(t, queue.Start())
+
+	ids := runConcurrentFlows(t, service)
+
+	// Let the queue drain before shutting it down
+	require.Eventually(t, func() bool {
+		stats := queue.Stats()
+		return stats.Queued == 0 && stats.InFlight == 0
+	}, 5*time.Second, time.Millisecond)
+	require.NoError(t, queue.Shutdown(context.Background()))
+	assertSingleTransition(t, service, ids)
+}
+
+func TestConcurrentFlowsWithEventSourcedStorage(t *testing.T) {
+	service := NewBookingService(utils.NewInMemoryCache(),
+		repository.NewEventSourcedRepository(repository.NewMemoryEventStore()),
+		WithCreditChecker(NewSimulatedCreditChecker(time.Millisecond)),
+		WithExpiryWindow(time.Nanosecond),
+	)
+
+	ids := runConcurrentFlows(t, service)
+	require.NoError(t, service.Wait(context.Background()))
+	assertSingleTransition(t, service, ids)
+}
+
+func TestBookingLocksAreIndependentPerBooking(t *testing.T) {
+	var locks bookingLocks
+	unlockA := locks.lock("a")
+
+	// Another booking is not held up by a
+	done := make(chan struct{})
+	go func() {
+		locks.lock("b")()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected booking b to be lockable while a is locked")
+	}
+
+	// The same booking waits for the holder
+	acquired := make(chan func())
+	go func() { acquired <- locks.lock("a") }()
+	select {
+	case <-acquired:
+		t.Fatal("Expected the second lock of a to wait")
+	case <-time.After(20 * time.Millisecond):
+	}
+	unlockA()
+	(<-acquired)()
+
+	assert.Empty(t, locks.locks, "Expected released locks to be dropped")
+}
@@ -0,0 +1,46 @@
+package usecase
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/touchsung/spd-fiber-booking-system/models"
+)
+
+// CreditChecker decides whether a high-value booking may be confirmed
+type CreditChecker interface {
+	Check(ctx context.Context, booking *models.Booking) (models.CreditCheckResult, error)
+}
+
+// Ensure SimulatedCreditChecker satisfies CreditChecker
+var _ CreditChecker = (*SimulatedCreditChecker)(nil)
+
+// SimulatedCreditChecker waits for Delay and then approves or rejects at random
+type SimulatedCreditChecker struct {
+	Delay time.Duration
+}
+
+func NewSimulatedCreditChecker(delay time.Duration) *SimulatedCreditChecker {
+	return &SimulatedCreditChecker{Delay: delay}
+}
+
+func (c *SimulatedCreditChecker) Check(ctx context.Context, booking *models.Booking) (models.CreditCheckResult, error) {
+	select {
+	case <-time.After(c.Delay):
+	case <-ctx.Done():
+		return models.CreditCheckResult{}, ctx.Err()
+	}
+
+	return models.CreditCheckResult{
+		BookingID: booking.ID,
+		Status:    c.generateRandomStatus(),
+	}, nil
+}
+
+func (c *SimulatedCreditChecker) generateRandomStatus() models.BookingStatus {
+	if rand.Float64() < 0.5 {
+		return models.StatusRejected
+	}
+	return models.StatusConfirmed
+}
@@ -0,0 +1,61 @@
+package usecase
+
+import "errors"
+
+// Error kinds returned by the usecase layer. Match them with errors.Is; the
+// HTTP layer maps each kind to a status code.
+var (
+	ErrNotFound          = errors.New("not found")
+	ErrInvalidTransition = errors.New("invalid status transition")
+	ErrConflict          = errors.New("conflict")
+	ErrValidation        = errors.New("validation failed")
+	// ErrPreconditionFailed means the caller's expected version is no longer current
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrUnsupported means the configured storage cannot serve the request
+	ErrUnsupported = errors.New("unsupported")
+	// ErrForbidden means the caller lacks a permission; the cause is an *authz.ForbiddenError
+	ErrForbidden = errors.New("forbidden")
+)
+
+// Error is a domain error of one of the kinds above, optionally wrapping the cause
+type Error struct {
+	Kind    error
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Unwrap exposes both the kind and the cause to errors.Is and errors.As
+func (e *Error) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+func notFoundError(message string) error {
+	return &Error{Kind: ErrNotFound, Message: message}
+}
+
+func validationError(message string) error {
+	return &Error{Kind: ErrValidation, Message: message}
+}
+
+func preconditionFailedError(message string) error {
+	return &Error{Kind: ErrPreconditionFailed, Message: message}
+}
+
+func unsupportedError(message string) error {
+	return &Error{Kind: ErrUnsupported, Message: message}
+}
+
+func invalidTransitionError(err error) error {
+	return &Error{Kind: ErrInvalidTransition, Message: err.Error(), Err: err}
+}
+
+func forbiddenError(err error) error {
+	return &Error{Kind: ErrForbidden, Message: err.Error(), Err: err}
+}
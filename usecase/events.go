@@ -0,0 +1,68 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/touchsung/spd-fiber-booking-system/models"
+)
+
+// Publisher delivers domain events to whoever is interested in them
+type Publisher interface {
+	Publish(ctx context.Context, event models.DomainEvent) error
+}
+
+// EventHandler reacts to a published domain event
+type EventHandler func(ctx context.Context, event models.DomainEvent) error
+
+// Ensure EventBus satisfies Publisher
+var _ Publisher = (*EventBus)(nil)
+
+// EventBus is the in-process Publisher. It hands every event to the handlers
+// subscribed to its type, one after another.
+type EventBus struct {
+	mutex    sync.RWMutex
+	handlers map[models.DomainEventType][]EventHandler
+	all      []EventHandler
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{handlers: make(map[models.DomainEventType][]EventHandler)}
+}
+
+// Subscribe registers handler for the given event types, or for every event
+// when no type is given
+func (b *EventBus) Subscribe(handler EventHandler, eventTypes ...models.DomainEventType) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if len(eventTypes) == 0 {
+		b.all = append(b.all, handler)
+		return
+	}
+	for _, eventType := range eventTypes {
+		b.handlers[eventType] = append(b.handlers[eventType], handler)
+	}
+}
+
+// Publish runs every matching handler, even when an earlier one fails, and
+// returns their joined errors. A failed event is published again later, so
+// handlers must tolerate seeing the same event twice.
+func (b *EventBus) Publish(ctx context.Context, event models.DomainEvent) error {
+	b.mutex.RLock()
+	handlers := append(append([]EventHandler{}, b.handlers[event.Type]...), b.all...)
+	b.mutex.RUnlock()
+
+	var errs []error
+	for _, handler := range handlers {
+		if err := handler(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("publishing %s event %s: %w", event.Type, event.ID, err)
+	}
+	return nil
+}
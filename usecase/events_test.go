@@ -0,0 +1,172 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/touchsung/spd-fiber-booking-system/authz"
+	"github.com/touchsung/spd-fiber-booking-system/models"
+	"github.com/touchsung/spd-fiber-booking-system/repository"
+	"github.com/touchsung/spd-fiber-booking-system/utils"
+)
+
+func TestEventBusRoutesByType(t *testing.T) {
+	bus := NewEventBus()
+	var confirmed, everything []string
+	bus.Subscribe(func(ctx context.Context, event models.DomainEvent) error {
+		confirmed = append(confirmed, event.ID)
+		return nil
+	}, models.DomainEventBookingConfirmed)
+	bus.Subscribe(func(ctx context.Context, event models.DomainEvent) error {
+		everything = append(everything, event.ID)
+		return nil
+	})
+	bus.Subscribe(func(ctx context.Context, event models.DomainEvent) error {
+		return errors.New("consumer down")
+	}, models.DomainEventBookingCanceled)
+
+	assert.NoError(t, bus.Publish(context.Background(), models.DomainEvent{ID: "1", Type: models.DomainEventBookingConfirmed}))
+	err := bus.Publish(context.Background(), models.DomainEvent{ID: "2", Type: models.DomainEventBookingCanceled})
+	assert.ErrorContains(t, err, "consumer down")
+
+	assert.Equal(t, []string{"1"}, confirmed)
+	assert.Equal(t, []string{"1", "2"}, everything, "a failing handler must not stop the others")
+}
+
+func TestStatusChangesRaiseDomainEvents(t *testing.T) {
+	repo := repository.NewMockRepository()
+	repo.ClearBookings()
+	service := NewBookingService(utils.NewInMemoryCache(), repo)
+
+	booking, err := service.CreateBooking(models.BookingRequest{UserID: "user1", ServiceID: "service1", Price: price(100)})
+	assert.NoError(t, err)
+	pending, err := repo.PendingEvents(time.Now(), 10)
+	assert.NoError(t, err)
+	assert.Empty(t, pending, "creating a booking raises no event")
+
+	assert.NoError(t, service.CancelBooking(booking.ID, 0, authz.Principal{}))
+	pending, err = repo.PendingEvents(time.Now(), 10)
+	assert.NoError(t, err)
+	if assert.Len(t, pending, 1) {
+		event := pending[0].Event
+		assert.NotEmpty(t, event.ID)
+		assert.Equal(t, models.DomainEventBookingCanceled, event.Type)
+		assert.Equal(t, booking.ID, event.BookingID)
+		assert.Equal(t, models.ActorUser, event.Actor)
+		assert.Equal(t, models.StatusCanceled, event.Booking.Status)
+		assert.Equal(t, int64(2), event.Booking.Version)
+	}
+
+	// A rejected change leaves nothing behind
+	assert.ErrorIs(t, service.CancelBooking(booking.ID, 0, authz.Principal{}), ErrInvalidTransition)
+	pending, err = repo.PendingEvents(time.Now(), 10)
+	assert.NoError(t, err)
+	assert.Len(t, pending, 1)
+}
+
+func TestOutboxRelayRetriesWithBackoff(t *testing.T) {
+	repo := repository.NewMockRepository()
+	repo.ClearBookings()
+	service := NewBookingService(utils.NewInMemoryCache(), repo)
+	booking, err := service.CreateBooking(models.BookingRequest{UserID: "user1", ServiceID: "service1", Price: price(100)})
+	assert.NoError(t, err)
+	assert.NoError(t, service.CancelBooking(booking.ID, 0, authz.Principal{}))
+
+	failing := true
+	var delivered []models.DomainEvent
+	bus := NewEventBus()
+	bus.Subscribe(func(ctx context.Context, event models.DomainEvent) error {
+		if failing {
+			return errors.New("consumer down")
+		}
+		delivered = append(delivered, event)
+		return nil
+	})
+
+	now := time.Now()
+	relay := NewOutboxRelay(repo, bus, RelayConfig{BatchSize: 10, InitialBackoff: time.Second, MaxBackoff: 4 * time.Second})
+	relay.now = func() time.Time { return now }
+
+	for attempt, delay := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		published, err := relay.PublishPending(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 0, published)
+
+		pending, err := repo.PendingEvents(now.Add(time.Hour), 10)
+		assert.NoError(t, err)
+		if assert.Len(t, pending, 1) {
+			assert.Equal(t, attempt+1, pending[0].Attempts)
+			assert.Equal(t, "publishing booking.canceled event "+pending[0].Event.ID+": consumer down", pending[0].LastError)
+			assert.Equal(t, now.Add(delay), pending[0].NextAttemptAt, "attempt %d", attempt+1)
+		}
+
+		// Nothing is due before the backoff has passed
+		published, err = relay.PublishPending(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 0, published)
+		now = now.Add(delay)
+	}
+
+	failing = false
+	published, err := relay.PublishPending(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, published)
+	if assert.Len(t, delivered, 1) {
+		assert.Equal(t, booking.ID, delivered[0].BookingID)
+	}
+
+	pending, err := repo.PendingEvents(now.Add(time.Hour), 10)
+	assert.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestOutboxRelayKeepsEachBookingsEventsInOrder(t *testing.T) {
+	repo := repository.NewMockRepository()
+	repo.ClearBookings()
+	service := NewBookingService(utils.NewInMemoryCache(), repo)
+	support := authz.Principal{UserID: "agent1", Roles: []authz.Role{authz.RoleSupport}}
+
+	first, err := service.CreateBooking(models.BookingRequest{UserID: "user1", ServiceID: "service1", Price: price(100)})
+	assert.NoError(t, err)
+	other, err := service.CreateBooking(models.BookingRequest{UserID: "user2", ServiceID: "service1", Price: price(100)})
+	assert.NoError(t, err)
+	assert.NoError(t, service.transitionStatus(models.StatusChange{BookingID: first.ID, To: models.StatusConfirmed, Actor: models.ActorCreditCheck}, 0, authz.Principal{}))
+	assert.NoError(t, service.CancelBooking(other.ID, 0, authz.Principal{}))
+	assert.NoError(t, service.CancelBooking(first.ID, 0, support))
+
+	// The first booking's confirmation fails once
+	failConfirmation := true
+	var delivered []models.DomainEventType
+	bus := NewEventBus()
+	bus.Subscribe(func(ctx context.Context, event models.DomainEvent) error {
+		if failConfirmation && event.Type == models.DomainEventBookingConfirmed {
+			failConfirmation = false
+			return errors.New("consumer down")
+		}
+		delivered = append(delivered, event.Type)
+		return nil
+	})
+	now := time.Now()
+	relay := NewOutboxRelay(repo, bus, RelayConfig{BatchSize: 10, InitialBackoff: time.Second, MaxBackoff: time.Second})
+	relay.now = func() time.Time { return now }
+
+	published, err := relay.PublishPending(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, published, "Expected only the other booking's event")
+	published, err = relay.PublishPending(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, published, "Expected the cancellation to wait for the confirmation's retry")
+
+	now = now.Add(time.Second)
+	published, err = relay.PublishPending(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, published)
+	assert.Equal(t, []models.DomainEventType{
+		models.DomainEventBookingCanceled, // the other booking
+		models.DomainEventBookingConfirmed,
+		models.DomainEventBookingCanceled,
+	}, delivered)
+}
@@ -0,0 +1,103 @@
+package usecase
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/touchsung/spd-fiber-booking-system/repository"
+)
+
+// RelayConfig controls how the outbox relay batches and retries
+type RelayConfig struct {
+	BatchSize      int
+	InitialBackoff time.Duration // delay before the first retry, doubled for each further retry
+	MaxBackoff     time.Duration
+}
+
+func DefaultRelayConfig() RelayConfig {
+	return RelayConfig{
+		BatchSize:      100,
+		InitialBackoff: time.Second,
+		MaxBackoff:     5 * time.Minute,
+	}
+}
+
+// OutboxRelay moves domain events from the outbox to a Publisher. Events stay
+// in the outbox until they are published, so none are lost when publishing
+// fails; they are retried with exponential backoff instead. A booking's events
+// are published in the order they were raised.
+type OutboxRelay struct {
+	outbox    repository.Outbox
+	publisher Publisher
+	config    RelayConfig
+	now       func() time.Time
+}
+
+func NewOutboxRelay(outbox repository.Outbox, publisher Publisher, config RelayConfig) *OutboxRelay {
+	return &OutboxRelay{
+		outbox:    outbox,
+		publisher: publisher,
+		config:    config,
+		now:       time.Now,
+	}
+}
+
+// PublishPending publishes one batch of due events and returns how many were published
+func (r *OutboxRelay) PublishPending(ctx context.Context) (int, error) {
+	messages, err := r.outbox.PendingEvents(r.now(), r.config.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	failed := make(map[string]bool) // bookings whose events wait for a retry
+	for _, message := range messages {
+		if ctx.Err() != nil {
+			return published, ctx.Err()
+		}
+		// A booking's events are published in order, so the rest of its
+		// events wait until the failed one goes through
+		if failed[message.Event.BookingID] {
+			continue
+		}
+		if err := r.publisher.Publish(ctx, message.Event); err != nil {
+			failed[message.Event.BookingID] = true
+			retryAt := r.now().Add(r.backoff(message.Attempts + 1))
+			log.Printf("outbox: %v; retrying at %s", err, retryAt.Format(time.RFC3339))
+			if err := r.outbox.MarkFailed(message.Event.ID, err.Error(), retryAt); err != nil {
+				return published, err
+			}
+			continue
+		}
+		if err := r.outbox.MarkPublished(message.Event.ID, r.now()); err != nil {
+			return published, err
+		}
+		published++
+	}
+	return published, nil
+}
+
+// Run publishes pending events every interval until ctx is canceled
+func (r *OutboxRelay) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := r.PublishPending(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("outbox: %v", err)
+			}
+		}
+	}
+}
+
+func (r *OutboxRelay) backoff(attempt int) time.Duration {
+	delay := r.config.InitialBackoff
+	for i := 1; i < attempt && delay < r.config.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, r.config.MaxBackoff)
+}
@@ -0,0 +1,296 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/touchsung/spd-fiber-booking-system/models"
+	"github.com/touchsung/spd-fiber-booking-system/repository"
+	"github.com/touchsung/spd-fiber-booking-system/utils"
+	"github.com/touchsung/spd-fiber-booking-system/webhook"
+)
+
+// WebhookSender sends one signed webhook request and returns the response status code
+type WebhookSender interface {
+	Send(ctx context.Context, request webhook.Request) (int, error)
+}
+
+// WebhookService manages webhook subscriptions and delivers booking domain
+// events to them. Deliveries run on the job queue, which retries failed
+// attempts with exponential backoff; every delivery is kept in a log.
+type WebhookService struct {
+	repository  repository.WebhookRepository
+	sender      WebhookSender
+	jobQueue    JobQueue
+	addresses   *webhook.AddressPolicy
+	idGenerator utils.IDGenerator
+	now         func() time.Time
+}
+
+// WebhookServiceOption customizes optional WebhookService dependencies
+type WebhookServiceOption func(*WebhookService)
+
+// WithAddressPolicy sets the addresses subscriptions may point at. It should
+// match the policy of the webhook client.
+func WithAddressPolicy(addresses *webhook.AddressPolicy) WebhookServiceOption {
+	return func(s *WebhookService) {
+		s.addresses = addresses
+	}
+}
+
+// addressCheckTimeout bounds resolving a subscription's host
+const addressCheckTimeout = 5 * time.Second
+
+func NewWebhookService(repo repository.WebhookRepository, sender WebhookSender, jobQueue JobQueue, options ...WebhookServiceOption) *WebhookService {
+	service := &WebhookService{
+		repository:  repo,
+		sender:      sender,
+		jobQueue:    jobQueue,
+		addresses:   webhook.DefaultAddressPolicy,
+		idGenerator: utils.NewULIDGenerator(),
+		now:         time.Now,
+	}
+	for _, option := range options {
+		option(service)
+	}
+	return service
+}
+
+// CreateSubscription registers a webhook. URLs pointing at internal addresses
+// are rejected. The returned subscription is the only one that includes the
+// signing secret.
+func (s *WebhookService) CreateSubscription(request models.WebhookSubscriptionRequest) (*models.WebhookSubscription, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), addressCheckTimeout)
+	defer cancel()
+	if err := s.addresses.CheckURL(ctx, request.URL); err != nil {
+		return nil, validationError("url is not allowed: " + err.Error())
+	}
+
+	secret := request.Secret
+	if secret == "" {
+		var err error
+		if secret, err = webhook.NewSecret(); err != nil {
+			return nil, fmt.Errorf("failed to generate webhook secret: %w", err)
+		}
+	}
+
+	subscription := &models.WebhookSubscription{
+		ID:         s.idGenerator.NewID(),
+		UserID:     request.UserID,
+		URL:        request.URL,
+		EventTypes: request.EventTypes,
+		Secret:     secret,
+		Active:     true,
+		CreatedAt:  s.now(),
+	}
+	if err := s.repository.SaveSubscription(subscription); err != nil {
+		return nil, fmt.Errorf("failed to save webhook subscription: %w", err)
+	}
+	return subscription, nil
+}
+
+// ListSubscriptions returns the subscriptions of userID plus the global ones,
+// or every subscription when userID is empty
+func (s *WebhookService) ListSubscriptions(userID string) ([]*models.WebhookSubscription, error) {
+	subscriptions, err := s.repository.ListSubscriptions(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook subscriptions: %w", err)
+	}
+	for _, subscription := range subscriptions {
+		subscription.Secret = ""
+	}
+	return subscriptions, nil
+}
+
+func (s *WebhookService) GetSubscription(subscriptionID string) (*models.WebhookSubscription, error) {
+	subscription, err := s.repository.GetSubscription(subscriptionID)
+	if err != nil {
+		if errors.Is(err, repository.ErrSubscriptionNotFound) {
+			return nil, notFoundError("webhook subscription not found")
+		}
+		return nil, fmt.Errorf("failed to load webhook subscription: %w", err)
+	}
+	subscription.Secret = ""
+	return subscription, nil
+}
+
+// DeleteSubscription stops deliveries to a webhook; its delivery log is kept
+func (s *WebhookService) DeleteSubscription(subscriptionID string) error {
+	if err := s.repository.DeleteSubscription(subscriptionID); err != nil {
+		if errors.Is(err, repository.ErrSubscriptionNotFound) {
+			return notFoundError("webhook subscription not found")
+		}
+		return fmt.Errorf("failed to delete webhook subscription: %w", err)
+	}
+	return nil
+}
+
+// ListDeliveries returns the subscription's delivery log, oldest first
+func (s *WebhookService) ListDeliveries(subscriptionID string, statuses ...models.DeliveryStatus) ([]*models.WebhookDelivery, error) {
+	if _, err := s.GetSubscription(subscriptionID); err != nil {
+		return nil, err
+	}
+	deliveries, err := s.repository.ListDeliveries(subscriptionID, statuses...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// HandleEvent records a delivery of event for every matching subscription and
+// queues it. It is an EventHandler for the event bus; the outbox may hand it
+// the same event twice, which does not create a second delivery.
+func (s *WebhookService) HandleEvent(ctx context.Context, event models.DomainEvent) error {
+	subscriptions, err := s.repository.ListSubscriptions(event.Booking.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to list webhook subscriptions: %w", err)
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, subscription := range subscriptions {
+		if !subscription.Matches(event) {
+			continue
+		}
+		if err := s.recordDelivery(subscription, event, string(payload)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *WebhookService) recordDelivery(subscription *models.WebhookSubscription, event models.DomainEvent, payload string) error {
+	now := s.now()
+	delivery := &models.WebhookDelivery{
+		ID:             s.idGenerator.NewID(),
+		SubscriptionID: subscription.ID,
+		EventID:        event.ID,
+		EventType:      event.Type,
+		BookingID:      event.BookingID,
+		Payload:        payload,
+		Status:         models.DeliveryPending,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	err := s.repository.CreateDelivery(delivery)
+	if errors.Is(err, repository.ErrDeliveryExists) {
+		// Seen before: queue it again only if it never got going
+		if delivery, err = s.repository.FindDelivery(subscription.ID, event.ID); err != nil {
+			return err
+		}
+		if delivery.Status != models.DeliveryPending || delivery.Attempts > 0 {
+			return nil
+		}
+	} else if err != nil {
+		return fmt.Errorf("failed to record webhook delivery: %w", err)
+	}
+
+	if _, err := s.jobQueue.Enqueue(models.JobTypeWebhookDelivery, delivery.ID); err != nil {
+		return fmt.Errorf("failed to queue webhook delivery %s: %w", delivery.ID, err)
+	}
+	return nil
+}
+
+// HandleDeliveryJob makes one delivery attempt. Errors are returned so the job
+// queue retries; the delivery is marked failed once the job runs out of attempts.
+func (s *WebhookService) HandleDeliveryJob(ctx context.Context, job *models.Job) error {
+	delivery, err := s.repository.GetDelivery(job.Payload)
+	if err != nil {
+		if errors.Is(err, repository.ErrDeliveryNotFound) {
+			log.Printf("webhook delivery %s skipped: %v", job.Payload, err)
+			return nil
+		}
+		return err
+	}
+	if delivery.Status != models.DeliveryPending {
+		return nil
+	}
+
+	subscription, err := s.repository.GetSubscription(delivery.SubscriptionID)
+	if errors.Is(err, repository.ErrSubscriptionNotFound) {
+		return s.finishDelivery(delivery, models.DeliveryFailed, 0, "subscription was deleted")
+	}
+	if err != nil {
+		return err
+	}
+
+	delivery.Attempts++
+	statusCode, sendErr := s.sender.Send(ctx, webhook.Request{
+		ID:        delivery.ID,
+		URL:       subscription.URL,
+		Secret:    subscription.Secret,
+		EventType: string(delivery.EventType),
+		Body:      []byte(delivery.Payload),
+	})
+	if sendErr == nil {
+		return s.finishDelivery(delivery, models.DeliverySucceeded, statusCode, "")
+	}
+
+	status := models.DeliveryPending
+	if job.Attempts >= job.MaxAttempts {
+		status = models.DeliveryFailed
+	}
+	// Subscribers read the delivery log, so it only gets a generic reason
+	log.Printf("webhook delivery %s attempt %d failed: %v", delivery.ID, delivery.Attempts, sendErr)
+	if err := s.finishDelivery(delivery, status, statusCode, webhook.FailureReason(statusCode, sendErr)); err != nil {
+		return err
+	}
+	return fmt.Errorf("webhook delivery %s: %w", delivery.ID, sendErr)
+}
+
+// finishDelivery records the outcome of an attempt in the delivery log
+func (s *WebhookService) finishDelivery(delivery *models.WebhookDelivery, status models.DeliveryStatus, statusCode int, lastError string) error {
+	now := s.now()
+	delivery.Status = status
+	delivery.LastStatusCode = statusCode
+	delivery.LastError = lastError
+	delivery.UpdatedAt = now
+	if status == models.DeliverySucceeded {
+		delivery.DeliveredAt = &now
+	}
+	if err := s.repository.UpdateDelivery(delivery); err != nil {
+		return fmt.Errorf("failed to update webhook delivery %s: %w", delivery.ID, err)
+	}
+	return nil
+}
+
+func (s *WebhookService) GetDelivery(deliveryID string) (*models.WebhookDelivery, error) {
+	delivery, err := s.repository.GetDelivery(deliveryID)
+	if err != nil {
+		if errors.Is(err, repository.ErrDeliveryNotFound) {
+			return nil, notFoundError("webhook delivery not found")
+		}
+		return nil, fmt.Errorf("failed to load webhook delivery: %w", err)
+	}
+	return delivery, nil
+}
+
+// ReplayDelivery queues a failed delivery again with a fresh set of attempts
+func (s *WebhookService) ReplayDelivery(deliveryID string) (*models.WebhookDelivery, error) {
+	delivery, err := s.GetDelivery(deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if delivery.Status != models.DeliveryFailed {
+		return nil, &Error{Kind: ErrConflict, Message: "only failed deliveries can be replayed"}
+	}
+
+	// Pending before queuing, so the job does not find it still failed
+	if err := s.finishDelivery(delivery, models.DeliveryPending, delivery.LastStatusCode, delivery.LastError); err != nil {
+		return nil, err
+	}
+	if _, err := s.jobQueue.Enqueue(models.JobTypeWebhookDelivery, delivery.ID); err != nil {
+		if revertErr := s.finishDelivery(delivery, models.DeliveryFailed, delivery.LastStatusCode, delivery.LastError); revertErr != nil {
+			log.Printf("webhook delivery %s left pending: %v", delivery.ID, revertErr)
+		}
+		return nil, err
+	}
+	return delivery, nil
+}
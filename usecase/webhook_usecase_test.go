@@ -0,0 +1,253 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/touchsung/spd-fiber-booking-system/authz"
+	"github.com/touchsung/spd-fiber-booking-system/jobs"
+	"github.com/touchsung/spd-fiber-booking-system/models"
+	"github.com/touchsung/spd-fiber-booking-system/repository"
+	"github.com/touchsung/spd-fiber-booking-system/utils"
+	"github.com/touchsung/spd-fiber-booking-system/webhook"
+)
+
+// fakeSender records webhook requests and fails the first failures of them,
+// with a 500 response or, when set, with transportErr
+type fakeSender struct {
+	mutex        sync.Mutex
+	failures     int
+	transportErr error
+	requests     []webhook.Request
+}
+
+func (s *fakeSender) Send(ctx context.Context, request webhook.Request) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.requests = append(s.requests, request)
+	if s.failures > 0 {
+		s.failures--
+		if s.transportErr != nil {
+			return 0, s.transportErr
+		}
+		return 500, errors.New("webhook endpoint responded with status 500")
+	}
+	return 200, nil
+}
+
+func (s *fakeSender) sent() []webhook.Request {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]webhook.Request{}, s.requests...)
+}
+
+type webhookFixture struct {
+	bookings *BookingService
+	webhooks *WebhookService
+	relay    *OutboxRelay
+	queue    *jobs.Queue
+	sender   *fakeSender
+}
+
+func setupWebhookFixture(t *testing.T, failures int) *webhookFixture {
+	repo := repository.NewMockRepository()
+	repo.ClearBookings()
+	config := jobs.DefaultConfig()
+	config.MaxAttempts = 3
+	config.InitialBackoff = time.Millisecond
+	queue := jobs.NewQueue(repository.NewMockJobRepository(), config)
+
+	sender := &fakeSender{failures: failures}
+	webhooks := NewWebhookService(repository.NewMockWebhookRepository(), sender, queue)
+	bookings := NewBookingService(utils.NewInMemoryCache(), repo, WithCreditChecker(NewSimulatedCreditChecker(0)))
+	queue.Register(models.JobTypeWebhookDelivery, webhooks.HandleDeliveryJob)
+	require.NoError(t, queue.Start())
+	t.Cleanup(func() { queue.Shutdown(context.Background()) })
+
+	bus := NewEventBus()
+	bus.Subscribe(webhooks.HandleEvent)
+	return &webhookFixture{
+		bookings: bookings,
+		webhooks: webhooks,
+		relay:    NewOutboxRelay(repo, bus, DefaultRelayConfig()),
+		queue:    queue,
+		sender:   sender,
+	}
+}
+
+// publish relays the outbox and waits for the resulting deliveries to settle
+func (f *webhookFixture) publish(t *testing.T) {
+	_, err := f.relay.PublishPending(context.Background())
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		stats := f.queue.Stats()
+		return stats.Queued == 0 && stats.InFlight == 0
+	}, 5*time.Second, time.Millisecond)
+}
+
+func TestWebhookDeliveredWhenCreditCheckResolves(t *testing.T) {
+	f := setupWebhookFixture(t, 0)
+	subscription, err := f.webhooks.CreateSubscription(models.WebhookSubscriptionRequest{URL: "https://example.com/hooks"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, subscription.Secret, "a secret is generated when none is given")
+
+	booking, err := f.bookings.CreateBooking(models.BookingRequest{UserID: "user1", ServiceID: "service1", Price: price(60000)})
+	require.NoError(t, err)
+	require.NoError(t, f.bookings.Wait(context.Background()))
+	f.publish(t)
+
+	sent := f.sender.sent()
+	require.Len(t, sent, 1)
+	assert.Equal(t, "https://example.com/hooks", sent[0].URL)
+	assert.Equal(t, subscription.Secret, sent[0].Secret)
+	var event models.DomainEvent
+	require.NoError(t, json.Unmarshal(sent[0].Body, &event))
+	assert.Equal(t, booking.ID, event.BookingID)
+	assert.Equal(t, models.ActorCreditCheck, event.Actor)
+	assert.Equal(t, string(event.Type), sent[0].EventType)
+
+	deliveries, err := f.webhooks.ListDeliveries(subscription.ID)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, sent[0].ID, deliveries[0].ID)
+	assert.Equal(t, models.DeliverySucceeded, deliveries[0].Status)
+	assert.Equal(t, 1, deliveries[0].Attempts)
+	assert.Equal(t, 200, deliveries[0].LastStatusCode)
+	assert.NotNil(t, deliveries[0].DeliveredAt)
+
+	// The outbox may hand over the same event again; it is not delivered twice
+	require.NoError(t, f.webhooks.HandleEvent(context.Background(), event))
+	f.publish(t)
+	assert.Len(t, f.sender.sent(), 1)
+}
+
+func TestWebhookSubscriptionsFilterEvents(t *testing.T) {
+	f := setupWebhookFixture(t, 0)
+	mine, err := f.webhooks.CreateSubscription(models.WebhookSubscriptionRequest{URL: "https://example.com/mine", UserID: "user1"})
+	require.NoError(t, err)
+	confirmedOnly, err := f.webhooks.CreateSubscription(models.WebhookSubscriptionRequest{
+		URL: "https://example.com/confirmed", EventTypes: []models.DomainEventType{models.DomainEventBookingConfirmed},
+	})
+	require.NoError(t, err)
+
+	for _, userID := range []string{"user1", "user2"} {
+		booking, err := f.bookings.CreateBooking(models.BookingRequest{UserID: userID, ServiceID: "service1", Price: price(100)})
+		require.NoError(t, err)
+		require.NoError(t, f.bookings.CancelBooking(booking.ID, 0, authz.Principal{}))
+	}
+	f.publish(t)
+
+	deliveries, err := f.webhooks.ListDeliveries(mine.ID)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1, "only user1's cancel goes to user1's webhook")
+	assert.Equal(t, models.DomainEventBookingCanceled, deliveries[0].EventType)
+
+	deliveries, err = f.webhooks.ListDeliveries(confirmedOnly.ID)
+	require.NoError(t, err)
+	assert.Empty(t, deliveries)
+
+	listed, err := f.webhooks.ListSubscriptions("user2")
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	assert.Equal(t, confirmedOnly.ID, listed[0].ID)
+	assert.Empty(t, listed[0].Secret, "secrets are only returned on create")
+}
+
+func TestWebhookRetriesThenReplays(t *testing.T) {
+	// Fails every attempt of the first round, then succeeds
+	f := setupWebhookFixture(t, 3)
+	subscription, err := f.webhooks.CreateSubscription(models.WebhookSubscriptionRequest{URL: "https://example.com/hooks", Secret: "a-long-shared-secret"})
+	require.NoError(t, err)
+
+	booking, err := f.bookings.CreateBooking(models.BookingRequest{UserID: "user1", ServiceID: "service1", Price: price(100)})
+	require.NoError(t, err)
+	require.NoError(t, f.bookings.CancelBooking(booking.ID, 0, authz.Principal{}))
+	f.publish(t)
+
+	failed, err := f.webhooks.ListDeliveries(subscription.ID, models.DeliveryFailed)
+	require.NoError(t, err)
+	require.Len(t, failed, 1)
+	assert.Equal(t, 3, failed[0].Attempts)
+	assert.Equal(t, 500, failed[0].LastStatusCode)
+	assert.Contains(t, failed[0].LastError, "status 500")
+	for _, request := range f.sender.sent() {
+		assert.Equal(t, failed[0].ID, request.ID, "retries keep the delivery ID")
+		assert.Equal(t, "a-long-shared-secret", request.Secret)
+	}
+
+	replayed, err := f.webhooks.ReplayDelivery(failed[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.DeliveryPending, replayed.Status)
+	f.publish(t)
+
+	deliveries, err := f.webhooks.ListDeliveries(subscription.ID)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, models.DeliverySucceeded, deliveries[0].Status)
+	assert.Equal(t, 4, deliveries[0].Attempts)
+	assert.Empty(t, deliveries[0].LastError)
+
+	_, err = f.webhooks.ReplayDelivery(failed[0].ID)
+	assert.ErrorIs(t, err, ErrConflict)
+	_, err = f.webhooks.ReplayDelivery("missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestWebhookSubscriptionsRejectInternalAddresses(t *testing.T) {
+	service := NewWebhookService(repository.NewMockWebhookRepository(), &fakeSender{}, nil)
+	for _, url := range []string{"http://127.0.0.1:8080/hooks", "http://169.254.169.254/latest/meta-data/", "http://localhost/hooks", "http://[::]/hooks"} {
+		_, err := service.CreateSubscription(models.WebhookSubscriptionRequest{URL: url})
+		assert.ErrorIs(t, err, ErrValidation, url)
+	}
+	listed, err := service.ListSubscriptions("")
+	require.NoError(t, err)
+	assert.Empty(t, listed)
+
+	// Internal networks can be allowed explicitly
+	service = NewWebhookService(repository.NewMockWebhookRepository(), &fakeSender{}, nil,
+		WithAddressPolicy(webhook.NewAddressPolicy(netip.MustParsePrefix("10.0.0.0/8"))))
+	_, err = service.CreateSubscription(models.WebhookSubscriptionRequest{URL: "http://10.1.2.3/hooks"})
+	assert.NoError(t, err)
+}
+
+func TestWebhookDeliveryLogHidesTransportErrors(t *testing.T) {
+	f := setupWebhookFixture(t, 3)
+	f.sender.transportErr = errors.New("dial tcp 10.0.0.7:5432: connect: connection refused")
+	subscription, err := f.webhooks.CreateSubscription(models.WebhookSubscriptionRequest{URL: "https://example.com/hooks"})
+	require.NoError(t, err)
+
+	booking, err := f.bookings.CreateBooking(models.BookingRequest{UserID: "user1", ServiceID: "service1", Price: price(100)})
+	require.NoError(t, err)
+	require.NoError(t, f.bookings.CancelBooking(booking.ID, 0, authz.Principal{}))
+	f.publish(t)
+
+	failed, err := f.webhooks.ListDeliveries(subscription.ID, models.DeliveryFailed)
+	require.NoError(t, err)
+	require.Len(t, failed, 1)
+	assert.Equal(t, "connection failed", failed[0].LastError)
+	assert.Zero(t, failed[0].LastStatusCode)
+}
+
+func TestWebhookSkipsDeletedSubscription(t *testing.T) {
+	f := setupWebhookFixture(t, 0)
+	subscription, err := f.webhooks.CreateSubscription(models.WebhookSubscriptionRequest{URL: "https://example.com/hooks"})
+	require.NoError(t, err)
+	require.NoError(t, f.webhooks.DeleteSubscription(subscription.ID))
+	assert.ErrorIs(t, f.webhooks.DeleteSubscription(subscription.ID), ErrNotFound)
+
+	booking, err := f.bookings.CreateBooking(models.BookingRequest{UserID: "user1", ServiceID: "service1", Price: price(100)})
+	require.NoError(t, err)
+	require.NoError(t, f.bookings.CancelBooking(booking.ID, 0, authz.Principal{}))
+	f.publish(t)
+
+	assert.Empty(t, f.sender.sent())
+	_, err = f.webhooks.ListDeliveries(subscription.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
@@ -1,50 +1,141 @@
 package utils
 
 import (
+	"container/list"
 	"sync"
+	"time"
 
 	"github.com/touchsung/spd-fiber-booking-system/models"
 )
 
+// BookingCache is the read-through cache used by the booking usecase.
+// Implementations copy bookings in and out, so callers never share state.
+type BookingCache interface {
+	SaveBooking(booking *models.Booking)
+	// UpdateBookingStatus sets the status and increments the version of a cached
+	// booking whose version equals expectedVersion, reporting whether it did
+	UpdateBookingStatus(bookingID string, status models.BookingStatus, expectedVersion int64) bool
+	GetBooking(bookingID string) (*models.Booking, bool)
+	GetAllBookings() []*models.Booking
+	DeleteBooking(bookingID string)
+	Stats() CacheStats
+}
+
+// CacheStats is a point-in-time snapshot of cache counters
+type CacheStats struct {
+	Entries     int    `json:"entries"`
+	Hits        uint64 `json:"hits"`
+	Misses      uint64 `json:"misses"`
+	Evictions   uint64 `json:"evictions"`
+	Expirations uint64 `json:"expirations"`
+}
+
+// CacheOption configures an InMemoryCache
+type CacheOption func(*InMemoryCache)
+
+// WithTTL expires entries the given duration after they were last saved. Zero disables expiry.
+func WithTTL(ttl time.Duration) CacheOption {
+	return func(c *InMemoryCache) {
+		c.ttl = ttl
+	}
+}
+
+// WithMaxEntries evicts the least recently used entry once the cache holds more
+// than maxEntries bookings. Zero means unbounded.
+func WithMaxEntries(maxEntries int) CacheOption {
+	return func(c *InMemoryCache) {
+		c.maxEntries = maxEntries
+	}
+}
+
+// Ensure InMemoryCache satisfies BookingCache
+var _ BookingCache = (*InMemoryCache)(nil)
+
+// cacheEntry holds a clone of the booking so callers never share it
+type cacheEntry struct {
+	booking   models.Booking
+	expiresAt time.Time
+}
+
 type InMemoryCache struct {
-	bookings map[string]*models.Booking
-	mutex    sync.RWMutex
+	bookings   map[string]*list.Element
+	lru        *list.List // front is most recently used
+	ttl        time.Duration
+	maxEntries int
+	stats      CacheStats
+	now        func() time.Time
+	mutex      sync.Mutex
 }
 
-func NewInMemoryCache() *InMemoryCache {
-	return &InMemoryCache{
-		bookings: make(map[string]*models.Booking),
+func NewInMemoryCache(options ...CacheOption) *InMemoryCache {
+	cache := &InMemoryCache{
+		bookings: make(map[string]*list.Element),
+		lru:      list.New(),
+		now:      time.Now,
+	}
+	for _, option := range options {
+		option(cache)
 	}
+	return cache
 }
 
+// SaveBooking stores a copy of booking unless a newer version is already cached
 func (c *InMemoryCache) SaveBooking(booking *models.Booking) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
-	c.bookings[booking.ID] = booking
+
+	entry := &cacheEntry{booking: *booking.Clone(), expiresAt: c.expiry()}
+	if element, exists := c.bookings[booking.ID]; exists {
+		// A slow writer must not replace a newer version with the copy it read earlier
+		if current := element.Value.(*cacheEntry); current.booking.Version <= booking.Version {
+			element.Value = entry
+		}
+		c.lru.MoveToFront(element)
+		return
+	}
+
+	c.bookings[booking.ID] = c.lru.PushFront(entry)
+	if c.maxEntries > 0 && c.lru.Len() > c.maxEntries {
+		c.removeElement(c.lru.Back())
+		c.stats.Evictions++
+	}
 }
 
-func (c *InMemoryCache) UpdateBookingStatus(bookingID string, status models.BookingStatus) {
+func (c *InMemoryCache) UpdateBookingStatus(bookingID string, status models.BookingStatus, expectedVersion int64) bool {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
-	if booking, exists := c.bookings[bookingID]; exists {
-		booking.Status = status
+	entry, exists := c.lookup(bookingID)
+	if !exists || entry.booking.Version != expectedVersion {
+		return false
 	}
+	entry.booking.Status = status
+	entry.booking.Version++
+	return true
 }
 
 func (c *InMemoryCache) GetBooking(bookingID string) (*models.Booking, bool) {
-	c.mutex.RLock()
-	defer c.mutex.RUnlock()
-	booking, exists := c.bookings[bookingID]
-	return booking, exists
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, exists := c.lookup(bookingID)
+	if !exists {
+		c.stats.Misses++
+		return nil, false
+	}
+	c.stats.Hits++
+	c.lru.MoveToFront(c.bookings[bookingID])
+	return entry.booking.Clone(), true
 }
 
 func (c *InMemoryCache) GetAllBookings() []*models.Booking {
-	c.mutex.RLock()
-	defer c.mutex.RUnlock()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 
 	bookings := make([]*models.Booking, 0, len(c.bookings))
-	for _, booking := range c.bookings {
-		bookings = append(bookings, booking)
+	for id := range c.bookings {
+		if entry, exists := c.lookup(id); exists {
+			bookings = append(bookings, entry.booking.Clone())
+		}
 	}
 	return bookings
 }
@@ -52,5 +143,42 @@ func (c *InMemoryCache) GetAllBookings() []*models.Booking {
 func (c *InMemoryCache) DeleteBooking(bookingID string) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
-	delete(c.bookings, bookingID)
+	if element, exists := c.bookings[bookingID]; exists {
+		c.removeElement(element)
+	}
+}
+
+func (c *InMemoryCache) Stats() CacheStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	stats := c.stats
+	stats.Entries = c.lru.Len()
+	return stats
+}
+
+// lookup returns a live entry, dropping it if its TTL has passed. Callers must hold the mutex.
+func (c *InMemoryCache) lookup(bookingID string) (*cacheEntry, bool) {
+	element, exists := c.bookings[bookingID]
+	if !exists {
+		return nil, false
+	}
+	entry := element.Value.(*cacheEntry)
+	if !entry.expiresAt.IsZero() && !c.now().Before(entry.expiresAt) {
+		c.removeElement(element)
+		c.stats.Expirations++
+		return nil, false
+	}
+	return entry, true
+}
+
+func (c *InMemoryCache) removeElement(element *list.Element) {
+	entry := c.lru.Remove(element).(*cacheEntry)
+	delete(c.bookings, entry.booking.ID)
+}
+
+func (c *InMemoryCache) expiry() time.Time {
+	if c.ttl <= 0 {
+		return time.Time{}
+	}
+	return c.now().Add(c.ttl)
 }
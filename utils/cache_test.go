@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/touchsung/spd-fiber-booking-system/models"
+)
+
+func TestInMemoryCacheTTL(t *testing.T) {
+	now := time.Now()
+	cache := NewInMemoryCache(WithTTL(time.Minute))
+	cache.now = func() time.Time { return now }
+
+	cache.SaveBooking(&models.Booking{ID: "1"})
+	_, exists := cache.GetBooking("1")
+	assert.True(t, exists, "Expected booking to be cached before TTL elapses")
+
+	now = now.Add(time.Minute)
+	_, exists = cache.GetBooking("1")
+	assert.False(t, exists, "Expected booking to expire once TTL elapses")
+	assert.Empty(t, cache.GetAllBookings())
+
+	stats := cache.Stats()
+	assert.Equal(t, uint64(1), stats.Hits)
+	assert.Equal(t, uint64(1), stats.Misses)
+	assert.Equal(t, uint64(1), stats.Expirations)
+	assert.Equal(t, 0, stats.Entries)
+}
+
+func TestInMemoryCacheLRUEviction(t *testing.T) {
+	cache := NewInMemoryCache(WithMaxEntries(2))
+
+	cache.SaveBooking(&models.Booking{ID: "1"})
+	cache.SaveBooking(&models.Booking{ID: "2"})
+
+	// Touch 1 so that 2 becomes the least recently used entry
+	cache.GetBooking("1")
+	cache.SaveBooking(&models.Booking{ID: "3"})
+
+	_, exists := cache.GetBooking("2")
+	assert.False(t, exists, "Expected least recently used booking to be evicted")
+	_, exists = cache.GetBooking("1")
+	assert.True(t, exists)
+	_, exists = cache.GetBooking("3")
+	assert.True(t, exists)
+
+	stats := cache.Stats()
+	assert.Equal(t, uint64(1), stats.Evictions)
+	assert.Equal(t, 2, stats.Entries)
+}
+
+func TestInMemoryCacheOverwriteDoesNotEvict(t *testing.T) {
+	cache := NewInMemoryCache(WithMaxEntries(1))
+
+	cache.SaveBooking(&models.Booking{ID: "1", Status: models.StatusPending})
+	cache.SaveBooking(&models.Booking{ID: "1", Status: models.StatusConfirmed})
+
+	booking, exists := cache.GetBooking("1")
+	assert.True(t, exists)
+	assert.Equal(t, models.StatusConfirmed, booking.Status)
+	assert.Equal(t, uint64(0), cache.Stats().Evictions)
+}
+
+func TestInMemoryCacheCompareAndSwapStatus(t *testing.T) {
+	cache := NewInMemoryCache()
+	cache.SaveBooking(&models.Booking{ID: "1", Status: models.StatusPending, Version: 1})
+
+	assert.False(t, cache.UpdateBookingStatus("1", models.StatusCanceled, 2), "Expected a stale version to be rejected")
+	assert.False(t, cache.UpdateBookingStatus("missing", models.StatusCanceled, 1))
+
+	assert.True(t, cache.UpdateBookingStatus("1", models.StatusCanceled, 1))
+	booking, _ := cache.GetBooking("1")
+	assert.Equal(t, models.StatusCanceled, booking.Status)
+	assert.Equal(t, int64(2), booking.Version)
+}
+
+func TestInMemoryCacheCopiesBookings(t *testing.T) {
+	cache := NewInMemoryCache()
+	booking := &models.Booking{ID: "1", Status: models.StatusPending, Version: 1}
+	cache.SaveBooking(booking)
+
+	// Neither the saved value nor returned copies alias the cached booking
+	booking.Status = models.StatusCanceled
+	found, _ := cache.GetBooking("1")
+	assert.Equal(t, models.StatusPending, found.Status)
+	found.Status = models.StatusConfirmed
+	all := cache.GetAllBookings()
+	assert.Equal(t, models.StatusPending, all[0].Status)
+
+	// Nor does the price breakdown
+	cache.SaveBooking(&models.Booking{ID: "2", Version: 1, PriceBreakdown: &models.PriceBreakdown{
+		Adjustments: []models.PriceAdjustment{{Rule: "volume_discount"}},
+	}})
+	found, _ = cache.GetBooking("2")
+	found.PriceBreakdown.Adjustments[0].Rule = "coupon"
+	found, _ = cache.GetBooking("2")
+	assert.Equal(t, "volume_discount", found.PriceBreakdown.Adjustments[0].Rule)
+}
+
+func TestInMemoryCacheKeepsNewerVersion(t *testing.T) {
+	cache := NewInMemoryCache()
+	cache.SaveBooking(&models.Booking{ID: "1", Status: models.StatusCanceled, Version: 2})
+
+	// A writer holding an older copy must not roll the cache back
+	cache.SaveBooking(&models.Booking{ID: "1", Status: models.StatusPending, Version: 1})
+	found, _ := cache.GetBooking("1")
+	assert.Equal(t, models.StatusCanceled, found.Status)
+	assert.Equal(t, int64(2), found.Version)
+}
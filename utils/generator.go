@@ -1,7 +1,100 @@
 package utils
 
-import "time"
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"sync"
+	"time"
+)
 
-func GenerateID() string {
-	return time.Now().Format("20060102150405")
+// IDGenerator produces unique IDs for bookings, jobs and other records
+type IDGenerator interface {
+	NewID() string
+}
+
+// crockford is the Crockford base32 alphabet used by ULIDs; it sorts in ASCII order
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// Ensure ULIDGenerator satisfies IDGenerator
+var _ IDGenerator = (*ULIDGenerator)(nil)
+
+// ULIDGenerator generates 26 character ULIDs: a 48-bit millisecond timestamp
+// followed by 80 random bits. IDs generated within the same millisecond reuse
+// the previous random part incremented by one, so IDs are strictly increasing
+// and sort lexicographically in creation order.
+type ULIDGenerator struct {
+	mutex    sync.Mutex
+	now      func() time.Time
+	entropy  io.Reader
+	lastTime uint64
+	lastRand [10]byte
+}
+
+func NewULIDGenerator() *ULIDGenerator {
+	return &ULIDGenerator{
+		now:     time.Now,
+		entropy: rand.Reader,
+	}
+}
+
+func (g *ULIDGenerator) NewID() string {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	ms := uint64(g.now().UnixMilli())
+	if ms <= g.lastTime {
+		// Same millisecond, or the clock went backwards: stay monotonic
+		ms = g.lastTime
+		if !increment(&g.lastRand) {
+			ms++
+			g.fillRandom()
+		}
+	} else {
+		g.fillRandom()
+	}
+	g.lastTime = ms
+
+	return encodeULID(ms, g.lastRand)
+}
+
+func (g *ULIDGenerator) fillRandom() {
+	if _, err := io.ReadFull(g.entropy, g.lastRand[:]); err != nil {
+		panic("utils: failed to read entropy for ULID: " + err.Error())
+	}
+}
+
+// increment adds one to the big-endian random part, reporting false on overflow
+func increment(b *[10]byte) bool {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i]++
+		if b[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func encodeULID(ms uint64, random [10]byte) string {
+	var raw [16]byte
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], ms)
+	copy(raw[:6], ts[2:])
+	copy(raw[6:], random[:])
+
+	// 128 bits encode to 26 base32 characters; the first character holds the top 3 bits
+	var out [26]byte
+	var acc uint32
+	bits := 2 // pad the front so the total bit count (130) is a multiple of 5
+	pos := 0
+	for _, value := range raw {
+		acc = acc<<8 | uint32(value)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			out[pos] = crockford[(acc>>uint(bits))&0x1f]
+			pos++
+		}
+	}
+	return string(out[:])
 }
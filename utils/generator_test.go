@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestULIDGeneratorFormat(t *testing.T) {
+	id := NewULIDGenerator().NewID()
+
+	assert.Len(t, id, 26)
+	for _, char := range id {
+		assert.Contains(t, crockford, string(char), "Expected Crockford base32 characters only")
+	}
+}
+
+func TestULIDGeneratorMonotonicWithinMillisecond(t *testing.T) {
+	fixed := time.UnixMilli(1700000000000)
+	generator := NewULIDGenerator()
+	generator.now = func() time.Time { return fixed }
+
+	ids := make([]string, 1000)
+	seen := make(map[string]bool, len(ids))
+	for i := range ids {
+		ids[i] = generator.NewID()
+		assert.False(t, seen[ids[i]], "Expected unique IDs")
+		seen[ids[i]] = true
+	}
+	assert.True(t, sort.StringsAreSorted(ids), "Expected IDs generated in the same millisecond to sort in creation order")
+}
+
+func TestULIDGeneratorSortsAcrossTime(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	generator := NewULIDGenerator()
+	generator.now = func() time.Time { return now }
+
+	first := generator.NewID()
+	now = now.Add(time.Millisecond)
+	second := generator.NewID()
+	now = now.Add(-time.Hour) // Clock skew must not break ordering
+	third := generator.NewID()
+
+	assert.Less(t, first, second)
+	assert.Less(t, second, third)
+}
+
+func TestULIDGeneratorRandomOverflow(t *testing.T) {
+	fixed := time.UnixMilli(1700000000000)
+	generator := NewULIDGenerator()
+	generator.now = func() time.Time { return fixed }
+	generator.entropy = bytes.NewReader(bytes.Repeat([]byte{0xff}, 20))
+
+	first := generator.NewID()
+	second := generator.NewID()
+	assert.Less(t, first, second, "Expected overflow to advance the timestamp part")
+}
@@ -0,0 +1,120 @@
+// Package validation enforces `validate` struct tags on incoming requests.
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/touchsung/spd-fiber-booking-system/models"
+)
+
+// FieldError describes a single invalid field
+// @Description Validation failure for a single field
+type FieldError struct {
+	Field   string `json:"field" example:"price"`
+	Rule    string `json:"rule" example:"gt"`
+	Param   string `json:"param,omitempty" example:"0"`
+	Message string `json:"message" example:"price must be greater than 0"`
+}
+
+// Errors is returned when a struct fails validation
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldErr := range e {
+		messages[i] = fieldErr.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// defaultMessages renders a human readable message per rule; %[1]s is the field, %[2]s the param
+var defaultMessages = map[string]string{
+	"required": "%[1]s is required",
+	"gt":       "%[1]s must be greater than %[2]s",
+	"gte":      "%[1]s must be at least %[2]s",
+	"lt":       "%[1]s must be less than %[2]s",
+	"lte":      "%[1]s must be at most %[2]s",
+	"min":      "%[1]s must be at least %[2]s",
+	"max":      "%[1]s must be at most %[2]s",
+	"oneof":    "%[1]s must be one of [%[2]s]",
+	"http_url": "%[1]s must be an http or https URL",
+}
+
+type Validator struct {
+	validate *validator.Validate
+	messages map[string]string
+}
+
+func New() *Validator {
+	validate := validator.New(validator.WithRequiredStructEnabled())
+
+	// Report fields by their JSON names so errors match the request body
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+
+	// Money is checked by its amount in major units, so limits in tags read like prices
+	validate.RegisterCustomTypeFunc(func(field reflect.Value) any {
+		money := field.Interface().(models.Money)
+		return float64(money.Amount) / math.Pow10(models.MinorUnitDigits(money.Currency))
+	}, models.Money{})
+
+	v := &Validator{validate: validate, messages: make(map[string]string, len(defaultMessages))}
+	for rule, format := range defaultMessages {
+		v.messages[rule] = format
+	}
+	return v
+}
+
+// RegisterRule adds a custom `validate` tag together with its error message format.
+// It must be called before the validator is used.
+func (v *Validator) RegisterRule(tag, messageFormat string, rule func(value string) bool) {
+	v.validate.RegisterValidation(tag, func(fl validator.FieldLevel) bool {
+		return rule(fl.Field().String())
+	})
+	v.messages[tag] = messageFormat
+}
+
+// Struct validates s, returning Errors listing every invalid field
+func (v *Validator) Struct(s any) error {
+	err := v.validate.Struct(s)
+	if err == nil {
+		return nil
+	}
+
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		return err
+	}
+
+	result := make(Errors, 0, len(fieldErrs))
+	for _, fieldErr := range fieldErrs {
+		result = append(result, FieldError{
+			Field:   fieldErr.Field(),
+			Rule:    fieldErr.Tag(),
+			Param:   fieldErr.Param(),
+			Message: v.message(fieldErr),
+		})
+	}
+	return result
+}
+
+func (v *Validator) message(fieldErr validator.FieldError) string {
+	format, known := v.messages[fieldErr.Tag()]
+	if !known {
+		return fmt.Sprintf("%s failed the %s rule", fieldErr.Field(), fieldErr.Tag())
+	}
+	return fmt.Sprintf(format, fieldErr.Field(), fieldErr.Param())
+}
@@ -0,0 +1,56 @@
+package validation
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/touchsung/spd-fiber-booking-system/models"
+)
+
+func TestValidateBookingRequest(t *testing.T) {
+	v := New()
+
+	price := models.NewMoney(6000000, "USD")
+	assert.NoError(t, v.Struct(models.BookingRequest{UserID: "user1", ServiceID: "service1", Price: &price}))
+	assert.NoError(t, v.Struct(models.BookingRequest{UserID: "user1", ServiceID: "service1"}), "price is optional")
+
+	negative := models.NewMoney(-500, "USD")
+	err := v.Struct(models.BookingRequest{ServiceID: "service1", Price: &negative})
+	var fieldErrs Errors
+	require.True(t, errors.As(err, &fieldErrs), "Expected validation.Errors")
+	require.Len(t, fieldErrs, 2)
+
+	assert.Equal(t, FieldError{Field: "user_id", Rule: "required", Message: "user_id is required"}, fieldErrs[0])
+	assert.Equal(t, FieldError{Field: "price", Rule: "gt", Param: "0", Message: "price must be greater than 0"}, fieldErrs[1])
+
+	// Limits apply to major units whatever the currency's minor unit
+	tooHigh := models.NewMoney(100000001, "JPY")
+	err = v.Struct(models.BookingRequest{UserID: "user1", ServiceID: "service1", Price: &tooHigh})
+	require.True(t, errors.As(err, &fieldErrs))
+	assert.Equal(t, "price must be at most 100000000", fieldErrs[0].Message)
+	withinLimit := models.NewMoney(10000000000, "USD")
+	assert.NoError(t, v.Struct(models.BookingRequest{UserID: "user1", ServiceID: "service1", Price: &withinLimit}))
+}
+
+func TestValidateCustomRules(t *testing.T) {
+	type request struct {
+		Code string `json:"code" validate:"even_length"`
+		Name string `json:"name" validate:"even_length"`
+	}
+
+	v := New()
+	v.RegisterRule("even_length", "%[1]s must have an even length", func(value string) bool {
+		return len(value)%2 == 0
+	})
+
+	assert.NoError(t, v.Struct(request{Code: "ab", Name: "abcd"}))
+
+	err := v.Struct(request{Code: "abc", Name: "abcde"})
+	var fieldErrs Errors
+	require.True(t, errors.As(err, &fieldErrs))
+	require.Len(t, fieldErrs, 2)
+	assert.Equal(t, "code must have an even length", fieldErrs[0].Message)
+	assert.Equal(t, "name must have an even length", fieldErrs[1].Message)
+}
@@ -0,0 +1,103 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"net/url"
+	"syscall"
+)
+
+// ErrForbiddenAddress is returned for webhook URLs and connections to an
+// address the AddressPolicy refuses
+var ErrForbiddenAddress = errors.New("webhook address is loopback, private, link-local or unspecified")
+
+// internalPrefixes are internal ranges netip has no predicate for
+var internalPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+}
+
+// AddressPolicy decides which addresses webhooks may be sent to. Loopback,
+// private, link-local, multicast and unspecified addresses are refused unless
+// one of the allowed networks contains them, so subscribers cannot use
+// webhooks to reach the service's own network.
+type AddressPolicy struct {
+	allowed []netip.Prefix
+	lookup  func(ctx context.Context, host string) ([]netip.Addr, error)
+}
+
+// DefaultAddressPolicy refuses every internal address
+var DefaultAddressPolicy = NewAddressPolicy()
+
+// NewAddressPolicy allows the given internal networks on top of the public internet
+func NewAddressPolicy(allowed ...netip.Prefix) *AddressPolicy {
+	return &AddressPolicy{allowed: allowed, lookup: lookupHost}
+}
+
+func lookupHost(ctx context.Context, host string) ([]netip.Addr, error) {
+	return net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+}
+
+// Allows reports whether webhooks may be sent to addr
+func (p *AddressPolicy) Allows(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range p.allowed {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() || addr.IsMulticast() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, prefix := range internalPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return addr.IsValid()
+}
+
+// CheckURL rejects webhook URLs whose host is, or currently resolves to, an
+// address the policy refuses. Hosts that do not resolve are accepted: every
+// connection is checked again when it is made.
+func (p *AddressPolicy) CheckURL(ctx context.Context, raw string) error {
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Hostname() == "" {
+		return errors.New("webhook URL has no host")
+	}
+	host := parsed.Hostname()
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if !p.Allows(addr) {
+			return ErrForbiddenAddress
+		}
+		return nil
+	}
+
+	addrs, err := p.lookup(ctx, host)
+	if err != nil {
+		return nil
+	}
+	for _, addr := range addrs {
+		if !p.Allows(addr) {
+			return ErrForbiddenAddress
+		}
+	}
+	return nil
+}
+
+// control is a net.Dialer Control function. It runs after DNS resolution, for
+// the address actually dialed, so a host name that resolves to an internal
+// address is refused however it was resolved when the subscription was made.
+func (p *AddressPolicy) control(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return &net.AddrError{Err: "unparsable address", Addr: address}
+	}
+	if !p.Allows(addrPort.Addr()) {
+		return ErrForbiddenAddress
+	}
+	return nil
+}
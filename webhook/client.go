@@ -0,0 +1,103 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DefaultTimeout bounds a single delivery attempt
+const DefaultTimeout = 10 * time.Second
+
+// Request is one signed callback
+type Request struct {
+	ID        string // delivery ID, the same on every retry
+	URL       string
+	Secret    string
+	EventType string
+	Body      []byte
+}
+
+type Client struct {
+	httpClient *http.Client
+	timeout    time.Duration
+	now        func() time.Time
+}
+
+// NewClient sends webhooks only to addresses the policy allows, or with a nil
+// policy only to public addresses. Redirects are not followed: a 3xx response
+// is a failed delivery.
+func NewClient(timeout time.Duration, addresses *AddressPolicy) *Client {
+	if addresses == nil {
+		addresses = DefaultAddressPolicy
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: addresses.control}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil // a proxy would dial the endpoint for us, unchecked
+	transport.DialContext = dialer.DialContext
+	httpClient := &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return &Client{httpClient: httpClient, timeout: timeout, now: time.Now}
+}
+
+// Send POSTs the signed request and returns the response status code. Any
+// status outside 2xx is returned as an error together with the code.
+func (c *Client) Send(ctx context.Context, request Request) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, request.URL, bytes.NewReader(request.Body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := c.now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "booking-webhooks/1.0")
+	req.Header.Set(HeaderID, request.ID)
+	req.Header.Set(HeaderEvent, request.EventType)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp.Unix(), 10))
+	req.Header.Set(HeaderSignature, Sign(request.Secret, request.ID, timestamp, request.Body))
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook endpoint responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// FailureReason describes a failed Send for the delivery log that subscribers
+// read. Transport errors are reduced to a generic reason so the log does not
+// reveal how the service's network answered.
+func FailureReason(statusCode int, err error) string {
+	var netErr net.Error
+	switch {
+	case statusCode != 0:
+		return fmt.Sprintf("endpoint responded with status %d", statusCode)
+	case errors.Is(err, ErrForbiddenAddress):
+		return "address not allowed"
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	default:
+		return "connection failed"
+	}
+}
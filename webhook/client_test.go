@@ -0,0 +1,194 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loopbackAllowed lets tests deliver to httptest servers
+var loopbackAllowed = NewAddressPolicy(netip.MustParsePrefix("127.0.0.0/8"), netip.MustParsePrefix("::1/128"))
+
+func TestSignAndVerify(t *testing.T) {
+	timestamp := time.Unix(1700000000, 0)
+	body := []byte(`{"id":"event-1"}`)
+	signature := Sign("secret", "delivery-1", timestamp, body)
+	assert.Regexp(t, `^v1=[0-9a-f]{64}$`, signature)
+
+	assert.NoError(t, Verify("secret", "delivery-1", timestamp, body, signature))
+	assert.ErrorIs(t, Verify("other", "delivery-1", timestamp, body, signature), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify("secret", "delivery-2", timestamp, body, signature), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify("secret", "delivery-1", timestamp.Add(time.Second), body, signature), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify("secret", "delivery-1", timestamp, []byte(`{"id":"event-2"}`), signature), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify("secret", "delivery-1", timestamp, body, signature[3:]), ErrInvalidSignature)
+
+	secret, err := NewSecret()
+	require.NoError(t, err)
+	other, err := NewSecret()
+	require.NoError(t, err)
+	assert.NotEqual(t, secret, other)
+}
+
+func TestClientSendsSignedRequest(t *testing.T) {
+	var received *http.Request
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+
+	client := NewClient(time.Second, loopbackAllowed)
+	statusCode, err := client.Send(context.Background(), Request{
+		ID:        "delivery-1",
+		URL:       server.URL + "/hooks",
+		Secret:    "secret",
+		EventType: "booking.confirmed",
+		Body:      []byte(`{"id":"event-1"}`),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, statusCode)
+
+	assert.Equal(t, "/hooks", received.URL.Path)
+	assert.Equal(t, "application/json", received.Header.Get("Content-Type"))
+	assert.Equal(t, "delivery-1", received.Header.Get(HeaderID))
+	assert.Equal(t, "booking.confirmed", received.Header.Get(HeaderEvent))
+	seconds, err := strconv.ParseInt(received.Header.Get(HeaderTimestamp), 10, 64)
+	require.NoError(t, err)
+	assert.NoError(t, Verify("secret", "delivery-1", time.Unix(seconds, 0), body, received.Header.Get(HeaderSignature)))
+}
+
+func TestClientReportsFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	t.Cleanup(server.Close)
+
+	client := NewClient(time.Second, loopbackAllowed)
+	statusCode, err := client.Send(context.Background(), Request{ID: "delivery-1", URL: server.URL, Secret: "secret"})
+	assert.ErrorContains(t, err, "status 502")
+	assert.Equal(t, http.StatusBadGateway, statusCode)
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(slow.Close)
+
+	client = NewClient(20*time.Millisecond, loopbackAllowed)
+	statusCode, err = client.Send(context.Background(), Request{ID: "delivery-1", URL: slow.URL, Secret: "secret"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 0, statusCode)
+}
+
+func TestClientRefusesInternalAddresses(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	t.Cleanup(server.Close)
+
+	// The default policy refuses the loopback server, and localhost is
+	// checked after it resolves
+	client := NewClient(time.Second, nil)
+	for _, url := range []string{server.URL, strings.Replace(server.URL, "127.0.0.1", "localhost", 1)} {
+		statusCode, err := client.Send(context.Background(), Request{ID: "delivery-1", URL: url, Secret: "secret"})
+		assert.ErrorIs(t, err, ErrForbiddenAddress, url)
+		assert.Equal(t, 0, statusCode)
+	}
+	assert.Zero(t, calls.Load())
+}
+
+func TestClientDoesNotFollowRedirects(t *testing.T) {
+	var followed atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/internal" {
+			followed.Store(true)
+			return
+		}
+		http.Redirect(w, r, "/internal", http.StatusTemporaryRedirect)
+	}))
+	t.Cleanup(server.Close)
+
+	client := NewClient(time.Second, loopbackAllowed)
+	statusCode, err := client.Send(context.Background(), Request{ID: "delivery-1", URL: server.URL + "/hooks", Secret: "secret"})
+	assert.ErrorContains(t, err, "status 307")
+	assert.Equal(t, http.StatusTemporaryRedirect, statusCode)
+	assert.False(t, followed.Load())
+}
+
+func TestAddressPolicy(t *testing.T) {
+	tests := []struct {
+		address string
+		allowed bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"100.64.0.1", false},
+		{"::ffff:127.0.0.1", false},
+		{"224.0.0.1", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.allowed, DefaultAddressPolicy.Allows(netip.MustParseAddr(tt.address)), tt.address)
+	}
+
+	internal := NewAddressPolicy(netip.MustParsePrefix("10.0.0.0/16"))
+	assert.True(t, internal.Allows(netip.MustParseAddr("10.0.1.2")))
+	assert.False(t, internal.Allows(netip.MustParseAddr("10.1.0.1")))
+}
+
+func TestFailureReason(t *testing.T) {
+	assert.Equal(t, "endpoint responded with status 502", FailureReason(502, errors.New("webhook endpoint responded with status 502")))
+	assert.Equal(t, "address not allowed", FailureReason(0, fmt.Errorf("dial: %w", ErrForbiddenAddress)))
+	assert.Equal(t, "timeout", FailureReason(0, context.DeadlineExceeded))
+	assert.Equal(t, "connection failed", FailureReason(0, errors.New("dial tcp 10.0.0.1:22: connect: connection refused")))
+}
+
+func TestAddressPolicyCheckURL(t *testing.T) {
+	policy := NewAddressPolicy()
+	policy.lookup = func(ctx context.Context, host string) ([]netip.Addr, error) {
+		switch host {
+		case "hooks.example.com":
+			return []netip.Addr{netip.MustParseAddr("93.184.216.34")}, nil
+		case "rebind.example.com":
+			return []netip.Addr{netip.MustParseAddr("93.184.216.34"), netip.MustParseAddr("10.0.0.1")}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+
+	assert.NoError(t, policy.CheckURL(context.Background(), "https://hooks.example.com/bookings"))
+	assert.NoError(t, policy.CheckURL(context.Background(), "https://93.184.216.34:8443/bookings"))
+	assert.NoError(t, policy.CheckURL(context.Background(), "https://unknown.example.com/"), "checked again when dialing")
+	for _, url := range []string{
+		"http://127.0.0.1:8080/",
+		"http://[::1]/",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://0.0.0.0/",
+		"https://rebind.example.com/",
+	} {
+		assert.ErrorIs(t, policy.CheckURL(context.Background(), url), ErrForbiddenAddress, url)
+	}
+	assert.Error(t, policy.CheckURL(context.Background(), "https:///no-host"))
+}
@@ -0,0 +1,60 @@
+// Package webhook signs and sends webhook callbacks.
+//
+// Every request carries Webhook-Id, Webhook-Timestamp (Unix seconds) and
+// Webhook-Signature headers. The signature is "v1=" followed by the hex
+// HMAC-SHA256, keyed with the subscription secret, of
+// "{Webhook-Id}.{Webhook-Timestamp}.{body}". Receivers should recompute it with
+// Verify and reject old timestamps to stop replays.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Request headers
+const (
+	HeaderID        = "Webhook-Id"
+	HeaderTimestamp = "Webhook-Timestamp"
+	HeaderSignature = "Webhook-Signature"
+	HeaderEvent     = "Webhook-Event"
+)
+
+const signatureVersion = "v1="
+
+// ErrInvalidSignature is returned by Verify for a missing, malformed or wrong signature
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Sign returns the Webhook-Signature header value for a request
+func Sign(secret, id string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(id + "." + strconv.FormatInt(timestamp.Unix(), 10) + "."))
+	mac.Write(body)
+	return signatureVersion + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a received signature in constant time
+func Verify(secret, id string, timestamp time.Time, body []byte, signature string) error {
+	if !strings.HasPrefix(signature, signatureVersion) {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(signature), []byte(Sign(secret, id, timestamp, body))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// NewSecret returns a random signing secret
+func NewSecret() (string, error) {
+	key := make([]byte, 24)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(key), nil
+}